@@ -0,0 +1,178 @@
+// Package actionref parses the action references used by Resource.ActionID,
+// Resource.Requires, Workflow.TargetActionID and Workflow.Workflows.
+//
+// A reference is either a local ActionID such as "llmChat", or a qualified
+// reference "@agent", "@agent/action" or "@agent/action:version".
+package actionref
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+var (
+	localPattern     = regexp.MustCompile(`^\w+$`)
+	qualifiedPattern = regexp.MustCompile(`^@([\w-]+)(?:/([\w-]+))?(?::(.+))?$`)
+)
+
+// ActionRef is a parsed action reference.
+type ActionRef struct {
+	// Agent is the AgentID of the owning workflow. It is empty for a local reference.
+	Agent string
+	// Action is the ActionID. It is empty for a reference to a whole agent,
+	// as allowed in Workflow.Workflows.
+	Action string
+	// Version is the agent version or version constraint, if any.
+	Version string
+}
+
+// ParseError is returned for a malformed reference.
+type ParseError struct {
+	Input  string
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid action reference %q: %s", e.Input, e.Reason)
+}
+
+// Parse parses a local or qualified reference. The version of a qualified
+// reference may be any constraint accepted by ParseConstraint, although the
+// Pkl modules only accept plain versions such as "1.0.0" or "1.2".
+func Parse(s string) (ActionRef, error) {
+	if localPattern.MatchString(s) {
+		return ActionRef{Action: s}, nil
+	}
+	m := qualifiedPattern.FindStringSubmatch(s)
+	if m == nil {
+		return ActionRef{}, &ParseError{Input: s, Reason: "expected an alphanumeric ID or @agent[/action][:version]"}
+	}
+	ref := ActionRef{Agent: m[1], Action: m[2], Version: m[3]}
+	if ref.Version != "" {
+		if _, err := ParseConstraint(ref.Version); err != nil {
+			return ActionRef{}, &ParseError{Input: s, Reason: err.Error()}
+		}
+	}
+	return ref, nil
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(s string) ActionRef {
+	ref, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return ref
+}
+
+// IsLocal reports whether the reference has no agent.
+func (r ActionRef) IsLocal() bool {
+	return r.Agent == ""
+}
+
+// String returns the reference in the syntax accepted by Parse.
+func (r ActionRef) String() string {
+	if r.IsLocal() {
+		return r.Action
+	}
+	s := "@" + r.Agent
+	if r.Action != "" {
+		s += "/" + r.Action
+	}
+	if r.Version != "" {
+		s += ":" + r.Version
+	}
+	return s
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r ActionRef) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *ActionRef) UnmarshalText(text []byte) error {
+	ref, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*r = ref
+	return nil
+}
+
+// Canonicalize returns the fully qualified form of r for a workflow with the
+// given AgentID and Version: a local reference becomes @agentID/action:version,
+// and a reference to the same agent without a version gets version.
+// References to other agents are returned unchanged.
+func (r ActionRef) Canonicalize(agentID, version string) ActionRef {
+	if r.IsLocal() {
+		return ActionRef{Agent: agentID, Action: r.Action, Version: version}
+	}
+	if r.Agent == agentID && r.Version == "" {
+		r.Version = version
+	}
+	return r
+}
+
+// Canonical parses id and canonicalizes it with the AgentID and Version of wf.
+func Canonical(id string, wf workflow.Workflow) (ActionRef, error) {
+	ref, err := Parse(id)
+	if err != nil {
+		return ActionRef{}, err
+	}
+	return ref.Canonicalize(wf.GetAgentID(), wf.GetVersion()), nil
+}
+
+// MatchesVersion reports whether version satisfies the version of r, read as
+// a constraint. A reference without a version matches every version
+// without a prerelease.
+func (r ActionRef) MatchesVersion(version string) (bool, error) {
+	c, err := ParseConstraint(r.Version)
+	if err != nil {
+		return false, err
+	}
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
+
+// Matches reports whether other, typically a canonical reference to an
+// existing action, is selected by r: the agents and actions are equal and the
+// version of other satisfies the version constraint of r.
+func (r ActionRef) Matches(other ActionRef) bool {
+	if r.Agent != other.Agent || r.Action != other.Action {
+		return false
+	}
+	if other.Version == "" {
+		return r.Version == ""
+	}
+	ok, err := r.MatchesVersion(other.Version)
+	return err == nil && ok
+}
+
+// Resolve returns the candidate matched by r with the highest version. It
+// reports false if no candidate matches.
+func (r ActionRef) Resolve(candidates []ActionRef) (ActionRef, bool) {
+	var (
+		best        ActionRef
+		bestVersion Version
+		found       bool
+	)
+	for _, c := range candidates {
+		if !r.Matches(c) {
+			continue
+		}
+		v, err := ParseVersion(c.Version)
+		if c.Version != "" && err != nil {
+			continue
+		}
+		if !found || v.Compare(bestVersion) > 0 {
+			best, bestVersion, found = c, v, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,128 @@
+package actionref
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ActionRef
+	}{
+		{"llmChat", ActionRef{Action: "llmChat"}},
+		{"@agent/action", ActionRef{Agent: "agent", Action: "action"}},
+		{"@agent/my-action:1.0.0", ActionRef{Agent: "agent", Action: "my-action", Version: "1.0.0"}},
+		{"@my-agent", ActionRef{Agent: "my-agent"}},
+		{"@my-agent:2", ActionRef{Agent: "my-agent", Version: "2"}},
+		{"@agent/action:^1.2", ActionRef{Agent: "agent", Action: "action", Version: "^1.2"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if got.String() != tt.in {
+			t.Errorf("String() = %q, want %q", got.String(), tt.in)
+		}
+	}
+
+	for _, in := range []string{"", "my-action", "@", "@agent/", "@agent/action:", "@agent/action:not a version", "agent/action"} {
+		var parseErr *ParseError
+		if _, err := Parse(in); !errors.As(err, &parseErr) {
+			t.Errorf("Parse(%q): expected *ParseError, got %v", in, err)
+		}
+	}
+}
+
+func TestTextMarshalling(t *testing.T) {
+	type doc struct {
+		Target   ActionRef   `json:"target"`
+		Requires []ActionRef `json:"requires"`
+	}
+	in := `{"target":"@agent/respond:1.0.0","requires":["fetch","@other/search"]}`
+
+	var d doc
+	if err := json.Unmarshal([]byte(in), &d); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if d.Target.Agent != "agent" || d.Requires[0].Action != "fetch" || d.Requires[1].Agent != "other" {
+		t.Errorf("Unexpected result %+v", d)
+	}
+	out, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(out) != in {
+		t.Errorf("Round trip produced %s", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"target":"bad id"}`), &d); err == nil {
+		t.Error("Expected an error for an invalid reference")
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	wf := workflow.WorkflowImpl{AgentID: "chatbot", Version: "1.2.0"}
+
+	tests := map[string]string{
+		"llmChat":              "@chatbot/llmChat:1.2.0",
+		"@chatbot/llmChat":     "@chatbot/llmChat:1.2.0",
+		"@chatbot/llmChat:1.0": "@chatbot/llmChat:1.0",
+		"@other/search":        "@other/search",
+	}
+	for in, want := range tests {
+		ref, err := Canonical(in, wf)
+		if err != nil {
+			t.Errorf("Canonical(%q) failed: %v", in, err)
+			continue
+		}
+		if ref.String() != want {
+			t.Errorf("Canonical(%q) = %s, want %s", in, ref, want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	available := []ActionRef{
+		MustParse("@agent/search:1.0.0"),
+		MustParse("@agent/search:1.4.2"),
+		MustParse("@agent/search:2.0.0"),
+		MustParse("@agent/search:2.1.0-beta.1"),
+		MustParse("@agent/fetch:1.9.0"),
+	}
+
+	tests := map[string]string{
+		"@agent/search":                "@agent/search:2.0.0",
+		"@agent/search:1":              "@agent/search:1.4.2",
+		"@agent/search:1.0":            "@agent/search:1.0.0",
+		"@agent/search:1.0.0":          "@agent/search:1.0.0",
+		"@agent/search:^1.1":           "@agent/search:1.4.2",
+		"@agent/search:~1.4.0":         "@agent/search:1.4.2",
+		"@agent/search:>=2.1.0-beta.0": "@agent/search:2.1.0-beta.1",
+	}
+	for in, want := range tests {
+		got, ok := MustParse(in).Resolve(available)
+		if !ok {
+			t.Errorf("%s: no match", in)
+			continue
+		}
+		if got.String() != want {
+			t.Errorf("%s resolved to %s, want %s", in, got, want)
+		}
+	}
+
+	if _, ok := MustParse("@agent/search:3").Resolve(available); ok {
+		t.Error("@agent/search:3 should not match")
+	}
+	if MustParse("@agent/search:1").Matches(MustParse("@agent/fetch:1.9.0")) {
+		t.Error("Different actions must not match")
+	}
+}
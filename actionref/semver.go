@@ -0,0 +1,374 @@
+package actionref
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. Versions with fewer than three components,
+// such as the "1.0" accepted by Workflow.Version, are completed with zeros.
+type Version struct {
+	Major, Minor, Patch uint64
+	// Prerelease holds the dot-separated identifiers after "-", if any.
+	Prerelease []string
+	// Build is the metadata after "+", which does not affect precedence.
+	Build string
+}
+
+// ParseVersion parses a version such as "1.2.3", "1.2", "v1.2.3-rc.1+build".
+func ParseVersion(s string) (Version, error) {
+	p, err := parsePartial(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if p.wild {
+		return Version{}, fmt.Errorf("invalid version %q: wildcards are only allowed in constraints", s)
+	}
+	return p.version(), nil
+}
+
+// String returns the version in major.minor.patch[-prerelease][+build] form.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v has lower, equal or higher precedence than o.
+func (v Version) Compare(o Version) int {
+	for _, d := range [][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		an, aErr := strconv.ParseUint(a[i], 10, 64)
+		bn, bErr := strconv.ParseUint(b[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case a[i] != b[i]:
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// partial is a version whose trailing components may be missing or wildcards.
+type partial struct {
+	parts      []uint64
+	n          int // number of numeric components, 0 to 3
+	wild       bool
+	prerelease []string
+	build      string
+}
+
+func (p partial) wildcard() bool {
+	return p.n < 3
+}
+
+func (p partial) version() Version {
+	return Version{Major: p.parts[0], Minor: p.parts[1], Patch: p.parts[2], Prerelease: p.prerelease, Build: p.build}
+}
+
+func parsePartial(s string) (partial, error) {
+	p := partial{parts: make([]uint64, 3)}
+	rest := strings.TrimPrefix(s, "v")
+	if rest == "" {
+		return p, fmt.Errorf("invalid version %q", s)
+	}
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		p.build = rest[i+1:]
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		p.prerelease = strings.Split(rest[i+1:], ".")
+		rest = rest[:i]
+		for _, id := range p.prerelease {
+			if id == "" {
+				return p, fmt.Errorf("invalid version %q: empty prerelease identifier", s)
+			}
+		}
+	}
+
+	fields := strings.Split(rest, ".")
+	if len(fields) > 3 {
+		return p, fmt.Errorf("invalid version %q: too many components", s)
+	}
+	for i, f := range fields {
+		if f == "*" || f == "x" || f == "X" {
+			p.wild = true
+			continue
+		}
+		if p.wild {
+			return p, fmt.Errorf("invalid version %q: number after wildcard", s)
+		}
+		n, err := strconv.ParseUint(f, 10, 64)
+		if err != nil || (len(f) > 1 && f[0] == '0') {
+			return p, fmt.Errorf("invalid version %q: bad component %q", s, f)
+		}
+		p.parts[i] = n
+		p.n = i + 1
+	}
+	if p.wildcard() && p.prerelease != nil {
+		return p, fmt.Errorf("invalid version %q: prerelease on a partial version", s)
+	}
+	return p, nil
+}
+
+// Constraint is a semver range in the syntax used by npm and Cargo:
+// comparators (=, !=, >, >=, <, <=), caret (^1.2) and tilde (~1.2) ranges,
+// partial and wildcard versions (1, 1.2, 1.2.*, *), hyphen ranges
+// (1.2 - 1.4) and alternatives separated by "||". Comparators separated by
+// spaces or commas must all hold.
+//
+// A bare partial version is a range: "1.2" matches any 1.2.x. A prerelease
+// version only satisfies a range if a comparator of the range names a
+// prerelease of the same major.minor.patch.
+type Constraint struct {
+	raw  string
+	sets [][]comparator
+}
+
+type comparator struct {
+	op string
+	v  Version
+}
+
+func (c comparator) check(v Version) bool {
+	cmp := v.Compare(c.v)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// ParseConstraint parses a constraint. An empty string or "*" matches every
+// version without a prerelease.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: s}
+	for _, alt := range strings.Split(s, "||") {
+		set, err := parseSet(strings.TrimSpace(alt))
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint %q: %w", s, err)
+		}
+		c.sets = append(c.sets, set)
+	}
+	return c, nil
+}
+
+// MustParseConstraint is like ParseConstraint but panics on error.
+func MustParseConstraint(s string) *Constraint {
+	c, err := ParseConstraint(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// String returns the constraint as written.
+func (c *Constraint) String() string {
+	return c.raw
+}
+
+// Check reports whether v satisfies the constraint.
+func (c *Constraint) Check(v Version) bool {
+	for _, set := range c.sets {
+		if checkSet(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkSet(set []comparator, v Version) bool {
+	for _, c := range set {
+		if !c.check(v) {
+			return false
+		}
+	}
+	if len(v.Prerelease) == 0 {
+		return true
+	}
+	for _, c := range set {
+		if len(c.v.Prerelease) > 0 && c.v.Major == v.Major && c.v.Minor == v.Minor && c.v.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSet(s string) ([]comparator, error) {
+	if lo, hi, ok := strings.Cut(s, " - "); ok {
+		return hyphenRange(strings.TrimSpace(lo), strings.TrimSpace(hi))
+	}
+
+	var set []comparator
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' })
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		// Allow a space between operator and version, as in ">= 1.2".
+		if strings.Trim(f, "<>=!^~") == "" && i+1 < len(fields) {
+			i++
+			f += fields[i]
+		}
+		cs, err := parseComparator(f)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cs...)
+	}
+	return set, nil
+}
+
+func hyphenRange(lo, hi string) ([]comparator, error) {
+	from, err := parsePartial(lo)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parsePartial(hi)
+	if err != nil {
+		return nil, err
+	}
+	set := []comparator{{">=", from.version()}}
+	if to.wildcard() {
+		if to.n > 0 {
+			set = append(set, comparator{"<", bump(to)})
+		}
+	} else {
+		set = append(set, comparator{"<=", to.version()})
+	}
+	return set, nil
+}
+
+func parseComparator(s string) ([]comparator, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", "!=", "~>", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(s, candidate) {
+			op = candidate
+			break
+		}
+	}
+	p, err := parsePartial(strings.TrimPrefix(s, op))
+	if err != nil {
+		return nil, err
+	}
+	v := p.version()
+
+	switch op {
+	case "", "=":
+		if !p.wildcard() {
+			return []comparator{{"=", v}}, nil
+		}
+		if p.n == 0 {
+			return nil, nil
+		}
+		return []comparator{{">=", v}, {"<", bump(p)}}, nil
+	case "!=":
+		if p.wildcard() {
+			return nil, fmt.Errorf("%q: != requires a full version", s)
+		}
+		return []comparator{{"!=", v}}, nil
+	case ">":
+		if p.n == 0 {
+			// Nothing is greater than every version.
+			return []comparator{{"<", Version{}}}, nil
+		}
+		if p.wildcard() {
+			return []comparator{{">=", bump(p)}}, nil
+		}
+		return []comparator{{">", v}}, nil
+	case ">=":
+		return []comparator{{">=", v}}, nil
+	case "<":
+		return []comparator{{"<", v}}, nil
+	case "<=":
+		if p.wildcard() {
+			if p.n == 0 {
+				return nil, nil
+			}
+			return []comparator{{"<", bump(p)}}, nil
+		}
+		return []comparator{{"<=", v}}, nil
+	case "~", "~>":
+		if p.n == 0 {
+			return nil, nil
+		}
+		upper := partial{parts: p.parts, n: min(p.n, 2)}
+		return []comparator{{">=", v}, {"<", bump(upper)}}, nil
+	case "^":
+		if p.n == 0 {
+			return nil, nil
+		}
+		// Bump the first non-zero component, or the last given one.
+		n := 1
+		for n < p.n && p.parts[n-1] == 0 {
+			n++
+		}
+		return []comparator{{">=", v}, {"<", bump(partial{parts: p.parts, n: n})}}, nil
+	}
+	return nil, fmt.Errorf("unknown operator in %q", s)
+}
+
+// bump returns the smallest version above every version matching the first
+// p.n components of p.
+func bump(p partial) Version {
+	switch p.n {
+	case 1:
+		return Version{Major: p.parts[0] + 1}
+	case 2:
+		return Version{Major: p.parts[0], Minor: p.parts[1] + 1}
+	default:
+		return Version{Major: p.parts[0], Minor: p.parts[1], Patch: p.parts[2] + 1}
+	}
+}
@@ -0,0 +1,99 @@
+package actionref
+
+import "testing"
+
+func TestVersionCompare(t *testing.T) {
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1", "2",
+	}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, err := ParseVersion(ordered[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseVersion(ordered[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Compare(b) >= 0 || b.Compare(a) <= 0 {
+			t.Errorf("Expected %s < %s", a, b)
+		}
+	}
+
+	v, err := ParseVersion("v1.2+build.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != "1.2.0+build.5" {
+		t.Errorf("Unexpected version %s", v)
+	}
+
+	for _, s := range []string{"", "1.2.3.4", "01.2", "1.*", "a.b", "1.0.0-"} {
+		if _, err := ParseVersion(s); err == nil {
+			t.Errorf("ParseVersion(%q): expected error", s)
+		}
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{"", []string{"0.0.1", "5.0.0"}, []string{"1.0.0-rc.1"}},
+		{"*", []string{"1.0.0"}, nil},
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{"1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0", "1.1.9"}},
+		{"1.x", []string{"1.0.0", "1.9.9"}, []string{"2.0.0"}},
+		{"1.2.*", []string{"1.2.5"}, []string{"1.3.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"1.2.2", "2.0.0", "2.0.0-rc.1"}},
+		{"^0.2.3", []string{"0.2.5"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^0.0", []string{"0.0.9"}, []string{"0.1.0"}},
+		{"~1.2.3", []string{"1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"~1", []string{"1.9.0"}, []string{"2.0.0"}},
+		{">=1.2, <2", []string{"1.2.0", "1.99.0"}, []string{"1.1.0", "2.0.0"}},
+		{">= 1.2 < 2", []string{"1.5.0"}, []string{"2.1.0"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{"!=1.0.0", []string{"1.0.1"}, []string{"1.0.0"}},
+		{"1.0 - 1.2", []string{"1.0.0", "1.2.7"}, []string{"1.3.0"}},
+		{"1.0.0 - 1.2.0", []string{"1.2.0"}, []string{"1.2.1"}},
+		{"<1.0.0 || >=2.0.0", []string{"0.9.0", "2.0.0"}, []string{"1.5.0"}},
+		{">=1.0.0-beta", []string{"1.0.0-beta.2", "1.0.0", "1.2.0"}, []string{"1.1.0-beta"}},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q) failed: %v", tt.constraint, err)
+			continue
+		}
+		for _, s := range tt.match {
+			if !c.Check(mustVersion(t, s)) {
+				t.Errorf("%q should match %s", tt.constraint, s)
+			}
+		}
+		for _, s := range tt.noMatch {
+			if c.Check(mustVersion(t, s)) {
+				t.Errorf("%q should not match %s", tt.constraint, s)
+			}
+		}
+	}
+
+	for _, s := range []string{">=", "1.2.3.4", "!=1.x", "^a", "1.2 -"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q): expected error", s)
+		}
+	}
+}
+
+func mustVersion(t *testing.T, s string) Version {
+	t.Helper()
+	v, err := ParseVersion(s)
+	if err != nil {
+		t.Fatalf("ParseVersion(%q) failed: %v", s, err)
+	}
+	return v
+}
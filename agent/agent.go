@@ -0,0 +1,218 @@
+// Package agent loads a kdeps agent project directory into typed values.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kdeps/schema"
+	"github.com/kdeps/schema/clientip"
+	"github.com/kdeps/schema/gen/project"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+const (
+	// WorkflowFile is the name of the workflow module in a project directory.
+	WorkflowFile = "workflow.pkl"
+	// ResourcesDir is the directory holding the resource modules.
+	ResourcesDir = "resources"
+)
+
+// Agent is a loaded agent project.
+type Agent struct {
+	// Dir is the project directory.
+	Dir string
+	// Workflow is the evaluated workflow.pkl.
+	Workflow workflow.Workflow
+	// Resources holds every resource, indexed by ActionID.
+	Resources map[string]*resource.Resource
+	// ResourceFiles maps each ActionID to the file that defines it.
+	ResourceFiles map[string]string
+	// Settings are the project settings of the workflow, with Pkl defaults applied.
+	Settings project.Settings
+}
+
+// ActionIDs returns the ActionIDs of all resources, sorted.
+func (a *Agent) ActionIDs() []string {
+	ids := make([]string, 0, len(a.Resources))
+	for id := range a.Resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Resource returns the resource with the given ActionID.
+func (a *Agent) Resource(actionID string) (*resource.Resource, bool) {
+	r, ok := a.Resources[actionID]
+	return r, ok
+}
+
+// FileError is a failure to load one file of the project.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// DuplicateActionIDError reports two resource files that define the same ActionID.
+type DuplicateActionIDError struct {
+	ActionID string
+	// First is the file that defines ActionID first in lexical order.
+	First string
+	// Second is the file that defines it again.
+	Second string
+}
+
+func (e *DuplicateActionIDError) Error() string {
+	return fmt.Sprintf("duplicate ActionID %q defined in %s and %s", e.ActionID, e.First, e.Second)
+}
+
+// LoadError collects every error found while loading a project.
+type LoadError struct {
+	Dir    string
+	Errors []error
+}
+
+func (e *LoadError) Error() string {
+	if len(e.Errors) == 1 {
+		return fmt.Sprintf("failed to load agent %s: %v", e.Dir, e.Errors[0])
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "failed to load agent %s: %d errors:", e.Dir, len(e.Errors))
+	for _, err := range e.Errors {
+		b.WriteString("\n  ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the collected errors, so errors.Is and errors.As look at each.
+func (e *LoadError) Unwrap() []error {
+	return e.Errors
+}
+
+type options struct {
+	loader *schema.Loader
+}
+
+// Option configures Load.
+type Option func(*options)
+
+// WithLoader evaluates the project with loader instead of schema.Default().
+func WithLoader(loader *schema.Loader) Option {
+	return func(o *options) {
+		o.loader = loader
+	}
+}
+
+// Load evaluates dir/workflow.pkl and every dir/resources/*.pkl. All files
+// are evaluated even if some fail; the failures, together with any duplicate
+// ActionIDs and invalid settings, are returned in a *LoadError.
+func Load(ctx context.Context, dir string, opts ...Option) (*Agent, error) {
+	o := options{loader: schema.Default()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, ResourcesDir, "*.pkl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resources: %w", err)
+	}
+	sort.Strings(paths)
+
+	var (
+		wg        sync.WaitGroup
+		wf        workflow.Workflow
+		wfErr     error
+		resources = make([]resource.Resource, len(paths))
+		resErrs   = make([]error, len(paths))
+	)
+	wfPath := filepath.Join(dir, WorkflowFile)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		wf, wfErr = loadWorkflow(ctx, o.loader, wfPath)
+	}()
+	for i, path := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resources[i], resErrs[i] = schema.LoadFromPath(ctx, o.loader, path, resource.Load)
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	if wfErr != nil {
+		errs = append(errs, &FileError{Path: wfPath, Err: wfErr})
+	} else {
+		for _, err := range checkSettings(wf.GetSettings()) {
+			errs = append(errs, &FileError{Path: wfPath, Err: err})
+		}
+	}
+
+	a := &Agent{
+		Dir:           dir,
+		Workflow:      wf,
+		Resources:     make(map[string]*resource.Resource, len(paths)),
+		ResourceFiles: make(map[string]string, len(paths)),
+	}
+	for i, path := range paths {
+		if resErrs[i] != nil {
+			errs = append(errs, &FileError{Path: path, Err: resErrs[i]})
+			continue
+		}
+		id := resources[i].ActionID
+		if first, ok := a.ResourceFiles[id]; ok {
+			errs = append(errs, &DuplicateActionIDError{ActionID: id, First: first, Second: path})
+			continue
+		}
+		a.Resources[id] = &resources[i]
+		a.ResourceFiles[id] = path
+	}
+
+	if len(errs) > 0 {
+		return nil, &LoadError{Dir: dir, Errors: errs}
+	}
+	a.Settings = wf.GetSettings()
+	return a, nil
+}
+
+// checkSettings returns the errors of the settings Pkl cannot check itself:
+// the TrustedProxies of the API and web servers must be IP addresses or CIDR
+// ranges.
+func checkSettings(settings project.Settings) []error {
+	var errs []error
+	if settings.APIServer != nil {
+		if _, err := clientip.FromAPIServer(*settings.APIServer); err != nil {
+			errs = append(errs, fmt.Errorf("APIServer.TrustedProxies: %w", err))
+		}
+	}
+	if settings.WebServer != nil {
+		if _, err := clientip.FromWebServer(*settings.WebServer); err != nil {
+			errs = append(errs, fmt.Errorf("WebServer.TrustedProxies: %w", err))
+		}
+	}
+	return errs
+}
+
+func loadWorkflow(ctx context.Context, loader *schema.Loader, path string) (workflow.Workflow, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return schema.LoadFromPath(ctx, loader, path, workflow.Load)
+}
@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema"
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/web_server"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+// fakeManager hands out fakeEvaluators.
+type fakeManager struct{}
+
+func (fakeManager) Close() error                { return nil }
+func (fakeManager) GetVersion() (string, error) { return "0.28.0", nil }
+
+func (fakeManager) NewEvaluator(context.Context, ...func(*pkl.EvaluatorOptions)) (pkl.Evaluator, error) {
+	return &fakeEvaluator{}, nil
+}
+
+func (m fakeManager) NewProjectEvaluator(ctx context.Context, _ *url.URL, opts ...func(*pkl.EvaluatorOptions)) (pkl.Evaluator, error) {
+	return m.NewEvaluator(ctx, opts...)
+}
+
+// fakeEvaluator reads `Key = "value"` lines from the module file instead of
+// evaluating Pkl. A module containing "throw" fails to evaluate.
+type fakeEvaluator struct {
+	pkl.Evaluator
+	closed bool
+}
+
+func (e *fakeEvaluator) EvaluateModule(_ context.Context, source *pkl.ModuleSource, out any) error {
+	data, err := os.ReadFile(source.Uri.Path)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(data), "throw") {
+		return &pkl.EvalError{ErrorOutput: "–– Pkl Error ––\n" + string(data)}
+	}
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if ok {
+			props[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	switch out := out.(type) {
+	case *workflow.WorkflowImpl:
+		out.AgentID = props["AgentID"]
+		out.TargetActionID = props["TargetActionID"]
+		out.Settings.APIServerMode = props["APIServerMode"] == "true"
+		if proxies, ok := props["APIServerTrustedProxies"]; ok {
+			list := strings.Split(proxies, ",")
+			out.Settings.APIServer = &apiserver.APIServerSettings{TrustedProxies: &list}
+		}
+		if proxies, ok := props["WebServerTrustedProxies"]; ok {
+			list := strings.Split(proxies, ",")
+			out.Settings.WebServer = &webserver.WebServerSettings{TrustedProxies: &list}
+		}
+	case *resource.Resource:
+		out.ActionID = props["ActionID"]
+		out.Name = props["Name"]
+	}
+	return nil
+}
+
+func (e *fakeEvaluator) Close() error { e.closed = true; return nil }
+func (e *fakeEvaluator) Closed() bool { return e.closed }
+
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func newTestLoader(t *testing.T) *schema.Loader {
+	t.Helper()
+	loader := schema.NewLoader(schema.WithEvaluatorManager(fakeManager{}))
+	t.Cleanup(func() { loader.Close() })
+	return loader
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"workflow.pkl":           "AgentID = \"demo\"\nTargetActionID = \"respond\"\nAPIServerMode = true\n",
+		"resources/fetch.pkl":    "ActionID = \"fetch\"\nName = \"Fetch\"\n",
+		"resources/respond.pkl":  "ActionID = \"respond\"\nName = \"Respond\"\n",
+		"resources/notes.txt":    "ignored",
+		"resources/nested/x.pkl": "ActionID = \"nested\"\n",
+	})
+
+	a, err := Load(context.Background(), dir, WithLoader(newTestLoader(t)))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if a.Workflow.GetAgentID() != "demo" {
+		t.Errorf("Unexpected AgentID %q", a.Workflow.GetAgentID())
+	}
+	if !a.Settings.APIServerMode {
+		t.Error("Settings were not taken from the workflow")
+	}
+	if ids := a.ActionIDs(); len(ids) != 2 || ids[0] != "fetch" || ids[1] != "respond" {
+		t.Errorf("Unexpected ActionIDs %v", ids)
+	}
+	if r, ok := a.Resource("fetch"); !ok || r.Name != "Fetch" {
+		t.Errorf("Unexpected fetch resource %+v", r)
+	}
+	if got := a.ResourceFiles["respond"]; got != filepath.Join(dir, "resources", "respond.pkl") {
+		t.Errorf("Unexpected file for respond: %s", got)
+	}
+}
+
+func TestLoadWithoutResources(t *testing.T) {
+	dir := writeProject(t, map[string]string{"workflow.pkl": "AgentID = \"demo\"\n"})
+	a, err := Load(context.Background(), dir, WithLoader(newTestLoader(t)))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(a.Resources) != 0 {
+		t.Errorf("Expected no resources, got %v", a.ActionIDs())
+	}
+}
+
+func TestLoadCollectsErrors(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"resources/a.pkl":      "ActionID = \"same\"\n",
+		"resources/b.pkl":      "throw(\"broken\")\n",
+		"resources/c.pkl":      "ActionID = \"same\"\n",
+		"resources/d.pkl":      "throw(\"also broken\")\n",
+		"resources/unique.pkl": "ActionID = \"unique\"\n",
+	})
+
+	_, err := Load(context.Background(), dir, WithLoader(newTestLoader(t)))
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("Expected *LoadError, got %v", err)
+	}
+	if len(loadErr.Errors) != 4 {
+		t.Fatalf("Expected 4 errors, got %d:\n%v", len(loadErr.Errors), err)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error("Missing workflow.pkl was not reported")
+	}
+
+	var evalErrs []string
+	for _, e := range loadErr.Errors {
+		var fileErr *FileError
+		var evalErr *pkl.EvalError
+		if errors.As(e, &fileErr) && errors.As(e, &evalErr) {
+			evalErrs = append(evalErrs, filepath.Base(fileErr.Path))
+		}
+	}
+	if strings.Join(evalErrs, ",") != "b.pkl,d.pkl" {
+		t.Errorf("Expected evaluation errors for b.pkl and d.pkl, got %v", evalErrs)
+	}
+
+	var dup *DuplicateActionIDError
+	if !errors.As(err, &dup) {
+		t.Fatal("Duplicate ActionID was not reported")
+	}
+	if dup.ActionID != "same" || filepath.Base(dup.First) != "a.pkl" || filepath.Base(dup.Second) != "c.pkl" {
+		t.Errorf("Unexpected duplicate %+v", dup)
+	}
+	if !strings.Contains(err.Error(), dup.First) || !strings.Contains(err.Error(), dup.Second) {
+		t.Errorf("Error message does not name both files:\n%v", err)
+	}
+}
+
+func TestLoadRejectsInvalidTrustedProxies(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"workflow.pkl": "AgentID = \"demo\"\nAPIServerTrustedProxies = \"10.0.0.0/8,proxy.local\"\nWebServerTrustedProxies = \"::1,10.0.0.0/33\"\n",
+	})
+	_, err := Load(context.Background(), dir, WithLoader(newTestLoader(t)))
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("Expected *LoadError, got %v", err)
+	}
+	if len(loadErr.Errors) != 2 {
+		t.Fatalf("Expected 2 errors, got %d:\n%v", len(loadErr.Errors), err)
+	}
+	for _, want := range []string{"APIServer.TrustedProxies", "proxy.local", "WebServer.TrustedProxies", "10.0.0.0/33"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Error does not mention %q:\n%v", want, err)
+		}
+	}
+
+	dir = writeProject(t, map[string]string{
+		"workflow.pkl": "AgentID = \"demo\"\nAPIServerTrustedProxies = \"10.0.0.0/8,::1\"\n",
+	})
+	if _, err := Load(context.Background(), dir, WithLoader(newTestLoader(t))); err != nil {
+		t.Errorf("Load failed for valid proxies: %v", err)
+	}
+}
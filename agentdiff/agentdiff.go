@@ -0,0 +1,448 @@
+// Package agentdiff compares two versions of an agent and reports what
+// changed in terms of the agent rather than of its Pkl source: resources
+// added, removed or renamed, dependency edges, API routes and methods,
+// Docker models, and every other property that changed, such as timeouts.
+//
+//	d := agentdiff.Compare(before, after)
+//	fmt.Print(d.Text())
+//
+// prints, for example,
+//
+//	Routes
+//	  + /api/v1/files POST
+//	Models
+//	  + llama3.3
+//	Resources
+//	  + upload
+//	  > fetch -> fetchData
+//	  ~ fetchData.Run.HTTPClient.TimeoutDuration: "60.s" -> "120.s"
+//	Dependencies
+//	  + respond -> upload
+package agentdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/agent"
+	"github.com/kdeps/schema/codec"
+	"github.com/kdeps/schema/gen/project"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/graph"
+)
+
+// Section groups related changes.
+type Section string
+
+const (
+	// SectionWorkflow holds changes to workflow properties other than Settings.
+	SectionWorkflow Section = "workflow"
+	// SectionSettings holds changes to the project settings, except routes
+	// and models.
+	SectionSettings Section = "settings"
+	// SectionRoutes holds the API server routes and methods added or removed.
+	SectionRoutes Section = "routes"
+	// SectionModels holds the Docker models added or removed.
+	SectionModels Section = "models"
+	// SectionResources holds resources added, removed or renamed, and changes
+	// to the properties of resources.
+	SectionResources Section = "resources"
+	// SectionDependencies holds the dependency edges added or removed.
+	SectionDependencies Section = "dependencies"
+)
+
+// sections lists the sections in the order Text prints them.
+var sections = []Section{SectionWorkflow, SectionSettings, SectionRoutes, SectionModels, SectionResources, SectionDependencies}
+
+// Kind is the kind of a change.
+type Kind string
+
+// The kinds of change. Renamed only applies to resources.
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Renamed Kind = "renamed"
+	Changed Kind = "changed"
+)
+
+// Change is one difference between two agents.
+type Change struct {
+	Section Section `json:"section"`
+	Kind    Kind    `json:"kind"`
+	// Path identifies what changed: a property path such as
+	// "APIServer.PortNum" or "fetch.Run.Exec.TimeoutDuration", an ActionID,
+	// a dependency edge "a -> b" (a requires b), a route path optionally
+	// followed by a method, or a model name.
+	Path string `json:"path"`
+	// Old and New are the values before and after, as JSON, or the old and
+	// new ActionID of a renamed resource. They are empty when not applicable.
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// Diff is the list of changes between two agents.
+type Diff struct {
+	Changes []Change `json:"changes"`
+}
+
+// Empty reports whether the agents are equivalent.
+func (d *Diff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Section returns the changes in section s.
+func (d *Diff) Section(s Section) []Change {
+	var ret []Change
+	for _, c := range d.Changes {
+		if c.Section == s {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// Compare returns the changes from old to new.
+func Compare(old, new *agent.Agent) *Diff {
+	c := &comparer{}
+	c.workflow(old, new)
+	c.settings(old.Settings, new.Settings)
+	renames := c.resources(old, new)
+	c.dependencies(old, new, renames)
+	if c.changes == nil {
+		c.changes = []Change{}
+	}
+	sort.SliceStable(c.changes, func(i, j int) bool {
+		return sectionIndex(c.changes[i].Section) < sectionIndex(c.changes[j].Section)
+	})
+	return &Diff{Changes: c.changes}
+}
+
+func sectionIndex(s Section) int {
+	for i, section := range sections {
+		if section == s {
+			return i
+		}
+	}
+	return len(sections)
+}
+
+type comparer struct {
+	changes []Change
+}
+
+func (c *comparer) add(section Section, kind Kind, path, old, new string) {
+	c.changes = append(c.changes, Change{Section: section, Kind: kind, Path: path, Old: old, New: new})
+}
+
+func (c *comparer) workflow(old, new *agent.Agent) {
+	// Settings are compared from Agent.Settings, which has defaults applied.
+	skip := map[string]bool{"Settings": true}
+	c.values(SectionWorkflow, "", reflect.ValueOf(old.Workflow), reflect.ValueOf(new.Workflow), skip)
+}
+
+func (c *comparer) settings(old, new project.Settings) {
+	skip := map[string]bool{"APIServer.Routes": true, "AgentSettings.Models": true}
+	c.values(SectionSettings, "", reflect.ValueOf(old), reflect.ValueOf(new), skip)
+
+	// Routes, keyed by path, with upper-cased methods.
+	oldRoutes, newRoutes := routes(old), routes(new)
+	for _, path := range union(oldRoutes, newRoutes) {
+		before, inOld := oldRoutes[path]
+		after, inNew := newRoutes[path]
+		switch {
+		case !inOld:
+			c.add(SectionRoutes, Added, path, "", strings.Join(sortedSet(after), ", "))
+		case !inNew:
+			c.add(SectionRoutes, Removed, path, strings.Join(sortedSet(before), ", "), "")
+		default:
+			for _, m := range union(before, after) {
+				if !before[m] {
+					c.add(SectionRoutes, Added, path+" "+m, "", "")
+				} else if !after[m] {
+					c.add(SectionRoutes, Removed, path+" "+m, "", "")
+				}
+			}
+		}
+	}
+
+	oldModels, newModels := set(old.AgentSettings.Models), set(new.AgentSettings.Models)
+	for _, m := range union(oldModels, newModels) {
+		if !oldModels[m] {
+			c.add(SectionModels, Added, m, "", "")
+		} else if !newModels[m] {
+			c.add(SectionModels, Removed, m, "", "")
+		}
+	}
+}
+
+func routes(s project.Settings) map[string]map[string]bool {
+	ret := make(map[string]map[string]bool)
+	if s.APIServer == nil {
+		return ret
+	}
+	for _, r := range s.APIServer.Routes {
+		methods := ret[r.Path]
+		if methods == nil {
+			methods = make(map[string]bool)
+			ret[r.Path] = methods
+		}
+		for _, m := range r.Methods {
+			methods[strings.ToUpper(m)] = true
+		}
+	}
+	return ret
+}
+
+// resources reports resources added, removed, renamed and changed, and
+// returns the renames from old to new ActionID.
+func (c *comparer) resources(old, new *agent.Agent) map[string]string {
+	var added, removed []string
+	for _, id := range new.ActionIDs() {
+		if _, ok := old.Resources[id]; !ok {
+			added = append(added, id)
+		}
+	}
+	for _, id := range old.ActionIDs() {
+		if _, ok := new.Resources[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+
+	// A removed and an added resource that differ only in their ActionID and
+	// Requires are a rename.
+	renames := make(map[string]string)
+	matched := make(map[string]bool)
+	for _, from := range removed {
+		for _, to := range added {
+			if !matched[to] && sameContent(old.Resources[from], new.Resources[to]) {
+				renames[from] = to
+				matched[to] = true
+				break
+			}
+		}
+	}
+
+	for _, id := range added {
+		if !matched[id] {
+			c.add(SectionResources, Added, id, "", "")
+		}
+	}
+	for _, id := range removed {
+		if to, ok := renames[id]; ok {
+			c.add(SectionResources, Renamed, id, id, to)
+		} else {
+			c.add(SectionResources, Removed, id, "", "")
+		}
+	}
+	for _, id := range new.ActionIDs() {
+		if before, ok := old.Resources[id]; ok {
+			c.values(SectionResources, id, reflect.ValueOf(before), reflect.ValueOf(new.Resources[id]), resourceSkip)
+		}
+	}
+	return renames
+}
+
+// resourceSkip are the resource properties reported in other ways: the
+// ActionID as renames and Requires as dependency edges.
+var resourceSkip = map[string]bool{"ActionID": true, "Requires": true}
+
+func sameContent(a, b *resource.Resource) bool {
+	c := &comparer{}
+	c.values(SectionResources, "", reflect.ValueOf(a), reflect.ValueOf(b), resourceSkip)
+	return len(c.changes) == 0
+}
+
+// dependencies reports the dependency edges added and removed, following
+// renames so that a renamed resource does not show all its edges as changed.
+func (c *comparer) dependencies(old, new *agent.Agent, renames map[string]string) {
+	rename := func(id string) string {
+		if to, ok := renames[id]; ok {
+			return to
+		}
+		return id
+	}
+	oldEdges, newEdges := make(map[string]bool), make(map[string]bool)
+	g := graph.New(old.Resources)
+	for _, id := range g.ActionIDs() {
+		for _, dep := range g.Dependencies(id) {
+			oldEdges[rename(id)+" -> "+rename(dep)] = true
+		}
+	}
+	g = graph.New(new.Resources)
+	for _, id := range g.ActionIDs() {
+		for _, dep := range g.Dependencies(id) {
+			newEdges[id+" -> "+dep] = true
+		}
+	}
+	for _, edge := range union(oldEdges, newEdges) {
+		if !oldEdges[edge] {
+			c.add(SectionDependencies, Added, edge, "", "")
+		} else if !newEdges[edge] {
+			c.add(SectionDependencies, Removed, edge, "", "")
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(pkl.Duration{})
+
+// values reports the differences between old and new under path. Paths in
+// skip, relative to the root of the comparison, are ignored.
+func (c *comparer) values(section Section, path string, old, new reflect.Value, skip map[string]bool) {
+	c.walk(section, path, "", old, new, skip)
+}
+
+func (c *comparer) walk(section Section, prefix, rel string, old, new reflect.Value, skip map[string]bool) {
+	if skip[rel] {
+		return
+	}
+	path := join(prefix, rel)
+	old, new = indirect(old), indirect(new)
+
+	switch {
+	case !old.IsValid() && !new.IsValid():
+		return
+	case !old.IsValid():
+		c.present(section, prefix, rel, Added, new, skip)
+		return
+	case !new.IsValid():
+		c.present(section, prefix, rel, Removed, old, skip)
+		return
+	}
+
+	switch {
+	case old.Type() == durationType && new.Type() == durationType:
+		a, b := old.Interface().(pkl.Duration), new.Interface().(pkl.Duration)
+		if a.GoDuration() != b.GoDuration() {
+			c.add(section, Changed, path, format(old), format(new))
+		}
+	case old.Kind() == reflect.Struct && old.Type() == new.Type():
+		t := old.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := f.Tag.Get("pkl")
+			if !f.IsExported() || name == "" {
+				continue
+			}
+			c.walk(section, prefix, join(rel, name), old.Field(i), new.Field(i), skip)
+		}
+	case old.Kind() == reflect.Map && old.Type() == new.Type() && old.Type().Key().Kind() == reflect.String:
+		keys := make(map[string]bool)
+		for _, m := range []reflect.Value{old, new} {
+			for _, k := range m.MapKeys() {
+				keys[k.String()] = true
+			}
+		}
+		for _, k := range union(keys, nil) {
+			key := reflect.ValueOf(k).Convert(old.Type().Key())
+			c.walk(section, prefix, rel+"["+k+"]", old.MapIndex(key), new.MapIndex(key), skip)
+		}
+	default:
+		if a, b := format(old), format(new); a != b {
+			c.add(section, Changed, path, a, b)
+		}
+	}
+}
+
+// present reports v, which is only present on one side, as added or
+// removed. An object is reported, followed by each of its properties that is
+// set.
+func (c *comparer) present(section Section, prefix, rel string, kind Kind, v reflect.Value, skip map[string]bool) {
+	if skip[rel] {
+		return
+	}
+	path := join(prefix, rel)
+	v = indirect(v)
+	switch {
+	case !v.IsValid():
+		return
+	case v.Kind() == reflect.Struct && v.Type() != durationType:
+		if rel != "" {
+			c.add(section, kind, path, "", "")
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if name := f.Tag.Get("pkl"); f.IsExported() && name != "" {
+				c.present(section, prefix, join(rel, name), kind, v.Field(i), skip)
+			}
+		}
+		return
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Map:
+		if v.Len() == 0 {
+			return
+		}
+	case v.IsZero():
+		return
+	}
+	if kind == Added {
+		c.add(section, kind, path, "", format(v))
+	} else {
+		c.add(section, kind, path, format(v), "")
+	}
+}
+
+// indirect dereferences pointers and interfaces, returning the zero Value
+// for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func join(prefix, name string) string {
+	switch {
+	case prefix == "":
+		return name
+	case name == "" || strings.HasPrefix(name, "["):
+		return prefix + name
+	}
+	return prefix + "." + name
+}
+
+// format returns v as JSON, with durations as strings such as "60.s".
+func format(v reflect.Value) string {
+	if !v.IsValid() {
+		return "null"
+	}
+	data, err := codec.MarshalJSON(v.Interface())
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v.Interface()))
+	}
+	return string(data)
+}
+
+func set(list []string) map[string]bool {
+	ret := make(map[string]bool, len(list))
+	for _, s := range list {
+		ret[s] = true
+	}
+	return ret
+}
+
+func sortedSet(s map[string]bool) []string {
+	return union(s, nil)
+}
+
+// union returns the keys of a and b, sorted.
+func union[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var keys []string
+	for _, m := range []map[string]V{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+package agentdiff
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/agent"
+	"github.com/kdeps/schema/defaults"
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/http"
+	"github.com/kdeps/schema/gen/resource"
+)
+
+func list(s ...string) *[]string {
+	return &s
+}
+
+func newAgent(resources ...*resource.Resource) *agent.Agent {
+	wf := defaults.NewWorkflow()
+	wf.AgentID = "demo"
+	wf.TargetActionID = "respond"
+	wf.Settings.AgentSettings.Models = []string{"llama3.2"}
+	api := defaults.NewAPIServerSettings()
+	api.Routes = []apiserver.APIServerRoutes{{Path: "/api/v1/whois", Methods: []string{"GET"}}}
+	wf.Settings.APIServer = &api
+
+	a := &agent.Agent{Workflow: wf, Settings: wf.Settings, Resources: make(map[string]*resource.Resource)}
+	for _, r := range resources {
+		a.Resources[r.ActionID] = r
+	}
+	return a
+}
+
+func fetch(id string, timeout float64) *resource.Resource {
+	client := defaults.NewResourceHTTPClient()
+	client.Method = "GET"
+	client.Url = "https://example.com"
+	client.TimeoutDuration = &pkl.Duration{Value: timeout, Unit: pkl.Second}
+	return &resource.Resource{ActionID: id, Name: "Fetch", Run: resource.ResourceAction{HTTPClient: &client}}
+}
+
+func TestCompare(t *testing.T) {
+	old := newAgent(
+		fetch("fetch", 60),
+		&resource.Resource{ActionID: "legacy"},
+		&resource.Resource{ActionID: "respond", Requires: list("fetch", "legacy")},
+	)
+
+	new := newAgent(
+		fetch("fetchData", 60),
+		&resource.Resource{ActionID: "upload", Name: "Upload"},
+		&resource.Resource{ActionID: "respond", Requires: list("fetchData", "upload"), Description: "Responds."},
+	)
+	// A rename is detected on content, comparing durations by length.
+	new.Resources["fetchData"].Run.HTTPClient.TimeoutDuration = &pkl.Duration{Value: 1, Unit: pkl.Minute}
+	settings := new.Settings
+	api := *settings.APIServer
+	api.PortNum = 8080
+	api.Routes = []apiserver.APIServerRoutes{
+		{Path: "/api/v1/whois", Methods: []string{"get", "POST"}},
+		{Path: "/api/v1/files", Methods: []string{"POST"}},
+	}
+	settings.APIServer = &api
+	settings.AgentSettings.Models = []string{"llama3.3"}
+	new.Settings = settings
+
+	want := `Settings
+  ~ APIServer.PortNum: 3000 -> 8080
+Routes
+  + /api/v1/files: POST
+  + /api/v1/whois POST
+Models
+  - llama3.2
+  + llama3.3
+Resources
+  + upload
+  > fetch -> fetchData
+  - legacy
+  ~ respond.Description: "" -> "Responds."
+Dependencies
+  - respond -> legacy
+  + respond -> upload
+`
+	d := Compare(old, new)
+	if got := d.Text(); got != want {
+		t.Errorf("Unexpected diff:\n%s\nwant\n%s", got, want)
+	}
+	if len(d.Section(SectionResources)) != 4 {
+		t.Errorf("Expected 4 resource changes, got %v", d.Section(SectionResources))
+	}
+	if _, err := json.Marshal(d); err != nil {
+		t.Errorf("Failed to encode the diff: %v", err)
+	}
+}
+
+func TestCompareTimeouts(t *testing.T) {
+	old := newAgent(fetch("fetch", 60))
+	new := newAgent(fetch("fetch", 90))
+	new.Resources["fetch"].Run.HTTPClient.Headers = &map[string]string{"X-Token": "t"}
+
+	changes := Compare(old, new).Changes
+	want := []Change{
+		{Section: SectionResources, Kind: Added, Path: "fetch.Run.HTTPClient.Headers", New: `{"X-Token":"t"}`},
+		{Section: SectionResources, Kind: Changed, Path: "fetch.Run.HTTPClient.TimeoutDuration", Old: `"60.s"`, New: `"90.s"`},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Unexpected changes %+v", changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestCompareEquivalent(t *testing.T) {
+	old := newAgent(fetch("fetch", 60))
+	new := newAgent(fetch("fetch", 60))
+	// Equal durations in different units are not a change.
+	new.Resources["fetch"].Run.HTTPClient.TimeoutDuration = &pkl.Duration{Value: 1, Unit: pkl.Minute}
+
+	d := Compare(old, new)
+	if !d.Empty() || d.Text() != "No changes.\n" {
+		t.Errorf("Expected no changes, got %s", d)
+	}
+}
+
+func TestCompareOptionalObjects(t *testing.T) {
+	old := newAgent(&resource.Resource{ActionID: "a"})
+	new := newAgent(&resource.Resource{ActionID: "a", Run: resource.ResourceAction{HTTPClient: &http.ResourceHTTPClient{Method: "GET"}}})
+	new.Settings.APIServer = nil
+
+	want := `Settings
+  - APIServer
+  - APIServer.HostIP: "127.0.0.1"
+  - APIServer.PortNum: 3000
+  - APIServer.CORS
+  - APIServer.CORS.AllowCredentials: true
+  - APIServer.CORS.MaxAge: "12.h"
+Routes
+  - /api/v1/whois: GET
+Resources
+  + a.Run.HTTPClient
+  + a.Run.HTTPClient.Method: "GET"
+`
+	if got := Compare(old, new).Text(); got != want {
+		t.Errorf("Unexpected diff:\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+package agentdiff
+
+import "strings"
+
+// titles are the headings of the sections in Text.
+var titles = map[Section]string{
+	SectionWorkflow:     "Workflow",
+	SectionSettings:     "Settings",
+	SectionRoutes:       "Routes",
+	SectionModels:       "Models",
+	SectionResources:    "Resources",
+	SectionDependencies: "Dependencies",
+}
+
+// Text returns the diff as readable text: a heading per section, then one
+// line per change, marked + for added, - for removed, > for renamed and ~
+// for changed.
+func (d *Diff) Text() string {
+	if d.Empty() {
+		return "No changes.\n"
+	}
+	var b strings.Builder
+	for _, s := range sections {
+		changes := d.Section(s)
+		if len(changes) == 0 {
+			continue
+		}
+		b.WriteString(titles[s] + "\n")
+		for _, c := range changes {
+			b.WriteString("  " + c.line() + "\n")
+		}
+	}
+	return b.String()
+}
+
+// String returns Text.
+func (d *Diff) String() string {
+	return d.Text()
+}
+
+func (c Change) line() string {
+	switch c.Kind {
+	case Added:
+		if c.New != "" {
+			return "+ " + c.Path + ": " + c.New
+		}
+		return "+ " + c.Path
+	case Removed:
+		if c.Old != "" {
+			return "- " + c.Path + ": " + c.Old
+		}
+		return "- " + c.Path
+	case Renamed:
+		return "> " + c.Old + " -> " + c.New
+	}
+	return "~ " + c.Path + ": " + c.Old + " -> " + c.New
+}
@@ -1,6 +1,7 @@
 package assets
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -269,8 +270,8 @@ func TestConvertPackageURLsToLocalPaths(t *testing.T) {
 		},
 		{
 			name:     "multiple package URLs",
-			input:    `import "package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl"\nimport "package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/URI.pkl"`,
-			expected: `import "external/pkl-go/codegen/src/go.pkl"\nimport "external/pkl-pantry/packages/pkl.experimental.uri/URI.pkl"`,
+			input:    "import \"package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl\"\nimport \"package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/URI.pkl\"",
+			expected: "import \"external/pkl-go/codegen/src/go.pkl\"\nimport \"external/pkl-pantry/packages/pkl.experimental.uri/URI.pkl\"",
 		},
 		{
 			name:     "no package URLs",
@@ -307,8 +308,8 @@ func TestConvertImportStatements(t *testing.T) {
 		},
 		{
 			name:     "mixed import and amends",
-			input:    `import "package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl"\namends "package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/URI.pkl"`,
-			expected: `import "external/pkl-go/codegen/src/go.pkl"\namends "external/pkl-pantry/packages/pkl.experimental.uri/URI.pkl"`,
+			input:    "import \"package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl\"\namends \"package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/URI.pkl\"",
+			expected: "import \"external/pkl-go/codegen/src/go.pkl\"\namends \"external/pkl-pantry/packages/pkl.experimental.uri/URI.pkl\"",
 		},
 	}
 
@@ -1006,9 +1007,9 @@ workflow {
 			expected string
 		}{
 			{
-				name:     "nested_quotes",
+				name:     "url_in_string_is_untouched",
 				input:    `description = "Uses package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl"`,
-				expected: `description = "Uses external/pkl-go/codegen/src/go.pkl"`,
+				expected: `description = "Uses package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl"`,
 			},
 			{
 				name:     "different_versions",
@@ -1021,9 +1022,14 @@ workflow {
 				expected: `import "external/pkl-go/codegen/src/go.pkl"`,
 			},
 			{
-				name:     "multiple_same_line",
+				name:     "urls_in_comment_are_untouched",
 				input:    `// Both package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl and package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/URI.pkl`,
-				expected: `// Both external/pkl-go/codegen/src/go.pkl and external/pkl-pantry/packages/pkl.experimental.uri/URI.pkl`,
+				expected: `// Both package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl and package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/URI.pkl`,
+			},
+			{
+				name:     "multiple_same_line",
+				input:    `import "package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl" import "package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/URI.pkl"`,
+				expected: `import "external/pkl-go/codegen/src/go.pkl" import "external/pkl-pantry/packages/pkl.experimental.uri/URI.pkl"`,
 			},
 			{
 				name:     "schema_kdeps_core_url",
@@ -1037,8 +1043,8 @@ workflow {
 			},
 			{
 				name:     "mixed_schema_and_pkl_urls",
-				input:    `import "package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl"\namends "package://schema.kdeps.com/core@1.0.0#/Workflow.pkl"`,
-				expected: `import "external/pkl-go/codegen/src/go.pkl"\namends "Workflow.pkl"`,
+				input:    "import \"package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.11.1#/go.pkl\"\namends \"package://schema.kdeps.com/core@1.0.0#/Workflow.pkl\"",
+				expected: "import \"external/pkl-go/codegen/src/go.pkl\"\namends \"Workflow.pkl\"",
 			},
 		}
 
@@ -1146,3 +1152,73 @@ workflow {
 		}
 	})
 }
+
+func TestConvertPackageURLsForFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "root module",
+			file:     "Workflow.pkl",
+			input:    `import "package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/URI.pkl"`,
+			expected: `import "external/pkl-pantry/packages/pkl.experimental.uri/URI.pkl"`,
+		},
+		{
+			name:     "nested pantry package importing another package",
+			file:     "external/pkl-pantry/packages/org.openapis.v3/Schema.pkl",
+			input:    `import "package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/URI.pkl"`,
+			expected: `import "../pkl.experimental.uri/URI.pkl"`,
+		},
+		{
+			name:     "nested pantry package importing pkl-go",
+			file:     "external/pkl-pantry/packages/org.openapis.v3/Schema.pkl",
+			input:    `import "package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.12.1#/go.pkl"`,
+			expected: `import "../../../pkl-go/codegen/src/go.pkl"`,
+		},
+		{
+			name:     "nested module importing the core schema",
+			file:     "external/pkl-go/codegen/src/go.pkl",
+			input:    `amends "package://schema.kdeps.com/core@1.0.0#/Workflow.pkl"`,
+			expected: `amends "../../../../Workflow.pkl"`,
+		},
+		{
+			name:     "same package",
+			file:     "external/pkl-pantry/packages/org.openapis.v3/Schema.pkl",
+			input:    `import "package://pkg.pkl-lang.org/pkl-pantry/org.openapis.v3@2.1.2#/Reference.pkl"`,
+			expected: `import "Reference.pkl"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ConvertPackageURLsForFile(tc.file, tc.input)
+			if err != nil {
+				t.Fatalf("Conversion failed: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tc.expected, result)
+			}
+		})
+	}
+
+	t.Run("missing target", func(t *testing.T) {
+		input := "import \"package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/DoesNotExist.pkl\"\nimport \"Project.pkl\""
+		result, err := ConvertPackageURLsForFile("Workflow.pkl", input)
+		var missingErr *MissingTargetError
+		if !errors.As(err, &missingErr) {
+			t.Fatalf("Expected MissingTargetError, got %v", err)
+		}
+		if len(missingErr.Missing) != 1 || missingErr.Missing[0].Target != "external/pkl-pantry/packages/pkl.experimental.uri/DoesNotExist.pkl" {
+			t.Errorf("Unexpected missing targets: %+v", missingErr.Missing)
+		}
+		if missingErr.Missing[0].Pos.Line != 1 {
+			t.Errorf("Expected missing target on line 1, got %s", missingErr.Missing[0].Pos)
+		}
+		if !strings.Contains(result, `import "external/pkl-pantry/packages/pkl.experimental.uri/DoesNotExist.pkl"`) {
+			t.Errorf("Expected content to be converted anyway, got:\n%s", result)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package assets
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+// ConvertedFS is a read-only fs.FS view of the embedded pkl directory in which
+// every .pkl file has its package URLs rewritten to local paths on read.
+//
+// The tree is rooted the same way as the directories produced by
+// CopyAssetsToTempDirWithConversion, so "Workflow.pkl" and
+// "external/pkl-go/codegen/src/go.pkl" are valid names. Conversion results are
+// cached per file, so a single ConvertedFS should be shared and reused.
+type ConvertedFS struct {
+	root fs.FS
+
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+var (
+	_ fs.ReadFileFS = (*ConvertedFS)(nil)
+	_ fs.ReadDirFS  = (*ConvertedFS)(nil)
+	_ fs.StatFS     = (*ConvertedFS)(nil)
+	_ fs.GlobFS     = (*ConvertedFS)(nil)
+)
+
+// NewConvertedFS returns a ConvertedFS over PKLFS.
+func NewConvertedFS() *ConvertedFS {
+	root, err := fs.Sub(PKLFS, "pkl")
+	if err != nil {
+		// fs.Sub only fails for invalid directory names.
+		panic(err)
+	}
+	return &ConvertedFS{root: root, cache: make(map[string][]byte)}
+}
+
+// Open opens the named file. Directories are returned as-is; .pkl files are
+// returned with their converted content.
+func (c *ConvertedFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := c.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return &convertedDir{File: f, fsys: c, dir: name}, nil
+	}
+	if !isPKLFile(name) {
+		return f, nil
+	}
+	f.Close()
+
+	data, err := c.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return &convertedFile{
+		Reader: bytes.NewReader(data),
+		info:   convertedInfo{FileInfo: info, size: int64(len(data))},
+	}, nil
+}
+
+// ReadFile returns the content of the named file, converted if it is a .pkl file.
+func (c *ConvertedFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	if !isPKLFile(name) {
+		return fs.ReadFile(c.root, name)
+	}
+
+	c.mu.Lock()
+	data, ok := c.cache[name]
+	c.mu.Unlock()
+	if ok {
+		return bytes.Clone(data), nil
+	}
+
+	raw, err := fs.ReadFile(c.root, name)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := ConvertPackageURLsForFile(name, string(raw))
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	data = []byte(converted)
+
+	c.mu.Lock()
+	c.cache[name] = data
+	c.mu.Unlock()
+	return bytes.Clone(data), nil
+}
+
+// ReadDir reads the named directory. Entries for .pkl files report the size of
+// the converted content.
+func (c *ConvertedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(c.root, name)
+	if err != nil {
+		return nil, err
+	}
+	return c.wrapEntries(name, entries), nil
+}
+
+// Stat returns a FileInfo describing the named file. For .pkl files the
+// reported size is that of the converted content.
+func (c *ConvertedFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(c.root, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() || !isPKLFile(name) {
+		return info, nil
+	}
+	data, err := c.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return convertedInfo{FileInfo: info, size: int64(len(data))}, nil
+}
+
+// Glob returns the names of all files matching pattern.
+func (c *ConvertedFS) Glob(pattern string) ([]string, error) {
+	return fs.Glob(c.root, pattern)
+}
+
+func (c *ConvertedFS) wrapEntries(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	wrapped := make([]fs.DirEntry, len(entries))
+	for i, entry := range entries {
+		if entry.IsDir() || !isPKLFile(entry.Name()) {
+			wrapped[i] = entry
+			continue
+		}
+		wrapped[i] = convertedEntry{DirEntry: entry, fsys: c, name: path.Join(dir, entry.Name())}
+	}
+	return wrapped
+}
+
+func isPKLFile(name string) bool {
+	return path.Ext(name) == ".pkl"
+}
+
+// convertedFile is an open converted .pkl file.
+type convertedFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *convertedFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *convertedFile) Close() error               { return nil }
+
+// convertedDir is an open directory whose entries report converted sizes.
+type convertedDir struct {
+	fs.File
+	fsys *ConvertedFS
+	dir  string
+}
+
+func (d *convertedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rd, ok := d.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: d.dir, Err: fs.ErrInvalid}
+	}
+	entries, err := rd.ReadDir(n)
+	return d.fsys.wrapEntries(d.dir, entries), err
+}
+
+// convertedEntry is a directory entry for a converted .pkl file.
+type convertedEntry struct {
+	fs.DirEntry
+	fsys *ConvertedFS
+	name string
+}
+
+func (e convertedEntry) Info() (fs.FileInfo, error) {
+	return e.fsys.Stat(e.name)
+}
+
+// convertedInfo overrides the size of an embedded file with its converted size.
+type convertedInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i convertedInfo) Size() int64 { return i.size }
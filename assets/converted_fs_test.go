@@ -0,0 +1,88 @@
+package assets
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestConvertedFS(t *testing.T) {
+	fsys := NewConvertedFS()
+
+	if err := fstest.TestFS(fsys, "Workflow.pkl", "Resource.pkl", "external/pkl-go/codegen/src/go.pkl"); err != nil {
+		t.Fatalf("ConvertedFS does not behave like an fs.FS: %v", err)
+	}
+
+	t.Run("matches_temp_dir_conversion", func(t *testing.T) {
+		tempDir, err := CopyAssetsToTempDirWithConversion()
+		if err != nil {
+			t.Fatalf("Failed to copy assets with conversion: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		for _, name := range []string{"Workflow.pkl", "Resource.pkl", "external/pkl-go/codegen/src/go.pkl"} {
+			want, err := os.ReadFile(filepath.Join(tempDir, filepath.FromSlash(name)))
+			if err != nil {
+				t.Fatalf("Failed to read %s from temp dir: %v", name, err)
+			}
+			got, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				t.Fatalf("Failed to read %s from ConvertedFS: %v", name, err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s differs between ConvertedFS and temp dir extraction", name)
+			}
+		}
+	})
+
+	t.Run("stat_reports_converted_size", func(t *testing.T) {
+		data, err := fsys.ReadFile("Workflow.pkl")
+		if err != nil {
+			t.Fatalf("Failed to read Workflow.pkl: %v", err)
+		}
+		info, err := fsys.Stat("Workflow.pkl")
+		if err != nil {
+			t.Fatalf("Failed to stat Workflow.pkl: %v", err)
+		}
+		if info.Size() != int64(len(data)) {
+			t.Errorf("Expected size %d, got %d", len(data), info.Size())
+		}
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		matches, err := fs.Glob(fsys, "*.pkl")
+		if err != nil {
+			t.Fatalf("Glob failed: %v", err)
+		}
+		files, err := ListPKLFiles()
+		if err != nil {
+			t.Fatalf("Failed to list PKL files: %v", err)
+		}
+		if len(matches) != len(files) {
+			t.Errorf("Expected %d matches, got %d: %v", len(files), len(matches), matches)
+		}
+	})
+
+	t.Run("cache_is_not_shared_with_callers", func(t *testing.T) {
+		data, err := fsys.ReadFile("Tool.pkl")
+		if err != nil {
+			t.Fatalf("Failed to read Tool.pkl: %v", err)
+		}
+		data[0] = 0
+		again, err := fsys.ReadFile("Tool.pkl")
+		if err != nil {
+			t.Fatalf("Failed to read Tool.pkl: %v", err)
+		}
+		if again[0] == 0 {
+			t.Error("Mutating a returned slice changed the cached content")
+		}
+	})
+
+	t.Run("missing_file", func(t *testing.T) {
+		if _, err := fsys.Open("DoesNotExist.pkl"); err == nil {
+			t.Error("Expected error opening missing file")
+		}
+	})
+}
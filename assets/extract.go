@@ -0,0 +1,235 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// errExchangeUnsupported is returned by exchangeDirs where directories cannot
+// be swapped atomically.
+var errExchangeUnsupported = errors.New("atomic directory exchange not supported")
+
+// ExtractAssets writes all embedded PKL assets to targetDir so that concurrent
+// processes can share it safely.
+//
+// Writers are serialized by an advisory lock file next to targetDir
+// (targetDir + ".lock"); a lock left behind by a crashed process is recovered.
+// The tree is written to a staging directory and swapped into place, so
+// readers see either the previous complete tree or the new one, never a
+// partially written file; files that are already up to date are hard-linked
+// into the new tree rather than rewritten. If targetDir already matches the
+// embedded assets it is left untouched. See swapDir for the platforms on
+// which readers may briefly find targetDir missing.
+//
+// targetDir must be dedicated to the assets: anything else in it is removed
+// by the swap. Use WriteAssetsToDir to add the assets to a directory that
+// holds other files.
+func ExtractAssets(targetDir string) error {
+	root, err := fs.Sub(PKLFS, "pkl")
+	if err != nil {
+		return err
+	}
+	return extractAtomically(targetDir, root)
+}
+
+// ExtractAssetsWithConversion is like ExtractAssets but writes the assets with
+// package URLs converted to local paths, as served by NewConvertedFS. Like
+// ExtractAssets, it replaces the whole of targetDir.
+func ExtractAssetsWithConversion(targetDir string) error {
+	return extractAtomically(targetDir, NewConvertedFS())
+}
+
+func extractAtomically(targetDir string, fsys fs.FS) error {
+	targetDir = filepath.Clean(targetDir)
+	parent := filepath.Dir(targetDir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	lock, err := acquireLock(targetDir + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	// Another process may have finished the same extraction while we waited.
+	if matches, err := treeMatches(targetDir, fsys); err != nil {
+		return err
+	} else if matches {
+		return nil
+	}
+
+	staging, err := os.MkdirTemp(parent, filepath.Base(targetDir)+".staging-*")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := stageTree(fsys, staging, targetDir); err != nil {
+		return fmt.Errorf("failed to stage assets: %w", err)
+	}
+	if err := os.Chmod(staging, 0755); err != nil {
+		return fmt.Errorf("failed to set staging directory permissions: %w", err)
+	}
+	return swapDir(staging, targetDir)
+}
+
+// swapDir replaces targetDir with staging. On Linux an existing targetDir is
+// exchanged with staging in a single renameat2(RENAME_EXCHANGE) call, so
+// targetDir always exists. Elsewhere, or on file systems without exchange
+// support, it is moved aside before staging is renamed into place, and a
+// reader may find targetDir missing in between; readers that get
+// fs.ErrNotExist for a file that should exist can retry after a moment. The
+// previous tree is removed once the new one is in place.
+func swapDir(staging, targetDir string) error {
+	if _, err := os.Lstat(targetDir); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(staging, targetDir); err != nil {
+			return fmt.Errorf("failed to move assets into place: %w", err)
+		}
+		return nil
+	}
+
+	err := exchangeDirs(staging, targetDir)
+	if err == nil {
+		// staging now holds the previous tree.
+		os.RemoveAll(staging)
+		return nil
+	}
+	if !errors.Is(err, errExchangeUnsupported) {
+		return fmt.Errorf("failed to move assets into place: %w", err)
+	}
+
+	old, err := os.MkdirTemp(filepath.Dir(targetDir), filepath.Base(targetDir)+".old-*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	defer os.RemoveAll(old)
+
+	previous := filepath.Join(old, "assets")
+	if err := os.Rename(targetDir, previous); err != nil {
+		return fmt.Errorf("failed to move previous assets aside: %w", err)
+	}
+	if err := os.Rename(staging, targetDir); err != nil {
+		// Put the previous tree back so the target is never left empty.
+		if rerr := os.Rename(previous, targetDir); rerr != nil {
+			return fmt.Errorf("failed to move assets into place: %w (restoring previous assets also failed: %v)", err, rerr)
+		}
+		return fmt.Errorf("failed to move assets into place: %w", err)
+	}
+	return nil
+}
+
+// writeTree writes every file of fsys below dir.
+func writeTree(fsys fs.FS, dir string) error {
+	return stageTree(fsys, dir, "")
+}
+
+// stageTree writes every file of fsys below dir. Files of reuse, if set, whose
+// content is already that of fsys are hard-linked instead, so that they keep
+// their identity and modification time.
+func stageTree(fsys fs.FS, dir, reuse string) error {
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		destPath := filepath.Join(dir, filepath.FromSlash(path))
+		if d.IsDir() {
+			return os.MkdirAll(destPath, 0755)
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return fmt.Errorf("failed to read embedded file %s: %w", path, err)
+		}
+		if reuse != "" {
+			existing := filepath.Join(reuse, filepath.FromSlash(path))
+			if current, err := os.ReadFile(existing); err == nil && bytes.Equal(current, data) {
+				if os.Link(existing, destPath) == nil {
+					return nil
+				}
+			}
+		}
+		if err := os.WriteFile(destPath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", destPath, err)
+		}
+		return nil
+	})
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// over path, so readers see either the old or the new content.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// treeMatches reports whether dir holds exactly the files of fsys with the
+// same content.
+func treeMatches(dir string, fsys fs.FS) (bool, error) {
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	expected := 0
+	matches := true
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		expected++
+		want, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil || !bytes.Equal(got, want) {
+			matches = false
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to compare %s with embedded assets: %w", dir, err)
+	}
+	if !matches {
+		return false, nil
+	}
+
+	actual := 0
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			actual++
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to compare %s with embedded assets: %w", dir, err)
+	}
+	return actual == expected, nil
+}
@@ -0,0 +1,297 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExtractAssetsConcurrently(t *testing.T) {
+	parent := t.TempDir()
+	target := filepath.Join(parent, "assets")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- ExtractAssets(target)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("ExtractAssets failed: %v", err)
+		}
+	}
+
+	report, err := Verify(target)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Extracted tree does not match the manifest: %+v", report)
+	}
+
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "assets" {
+			t.Errorf("Unexpected leftover %s", e.Name())
+		}
+	}
+}
+
+func TestExtractAssetsReplacesModifiedTree(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "assets")
+	if err := ExtractAssets(target); err != nil {
+		t.Fatalf("ExtractAssets failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "Workflow.pkl"), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "Extra.pkl"), []byte("extra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExtractAssets(target); err != nil {
+		t.Fatalf("ExtractAssets failed: %v", err)
+	}
+	report, err := Verify(target)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Modified tree was not replaced: %+v", report)
+	}
+}
+
+func TestExtractAssetsSkipsUpToDateTree(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "assets")
+	if err := ExtractAssets(target); err != nil {
+		t.Fatalf("ExtractAssets failed: %v", err)
+	}
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tool := filepath.Join(target, "Tool.pkl")
+	if err := os.Chtimes(tool, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExtractAssets(target); err != nil {
+		t.Fatalf("ExtractAssets failed: %v", err)
+	}
+	info, err := os.Stat(tool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Error("Up-to-date tree was rewritten")
+	}
+}
+
+func TestExtractAssetsWithConversion(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "assets")
+	if err := ExtractAssetsWithConversion(target); err != nil {
+		t.Fatalf("ExtractAssetsWithConversion failed: %v", err)
+	}
+
+	converted := NewConvertedFS()
+	err := fs.WalkDir(converted, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		want, err := fs.ReadFile(converted, path)
+		if err != nil {
+			return err
+		}
+		got, err := os.ReadFile(filepath.Join(target, filepath.FromSlash(path)))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from the converted asset", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to compare extracted tree: %v", err)
+	}
+
+	workflow, err := os.ReadFile(filepath.Join(target, "Workflow.pkl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(workflow), "package://") {
+		t.Error("Workflow.pkl still contains package URLs")
+	}
+}
+
+func TestExtractAssetsRecoversStaleLock(t *testing.T) {
+	parent := t.TempDir()
+	target := filepath.Join(parent, "assets")
+	lockPath := target + ".lock"
+
+	// A lock file whose owner PID does not exist, as left by a crashed process.
+	if err := os.WriteFile(lockPath, []byte("2147483646\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- ExtractAssets(target) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ExtractAssets failed: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("ExtractAssets did not recover the stale lock")
+	}
+
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("Lock file was not released: %v", err)
+	}
+}
+
+func TestWriteAssetsToDirWithConversionConcurrently(t *testing.T) {
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- WriteAssetsToDirWithConversion(dir)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("WriteAssetsToDirWithConversion failed: %v", err)
+		}
+	}
+
+	want, err := NewConvertedFS().ReadFile("Resource.pkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "Resource.pkl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("Resource.pkl was not written completely")
+	}
+	if _, err := os.Stat(filepath.Clean(dir) + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("Lock file was not released: %v", err)
+	}
+}
+
+func TestLockHandoff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "assets.lock")
+	first, err := acquireLock(path)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	acquired := make(chan *fileLock, 1)
+	go func() {
+		l, err := acquireLock(path)
+		if err != nil {
+			t.Errorf("Failed to acquire lock: %v", err)
+		}
+		acquired <- l
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Lock acquired while held")
+	case <-time.After(4 * lockPollInterval):
+	}
+
+	if err := first.release(); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	second := <-acquired
+	if second == nil {
+		t.FailNow()
+	}
+	// The waiter must hold the lock file now at path, not the removed one.
+	if !isLockedFile(second.f, path) {
+		t.Error("Waiter holds a lock on a removed file")
+	}
+	// Releasing the first lock again must not remove the second one's file.
+	first.release()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Lock file of the new owner was removed: %v", err)
+	}
+	if err := second.release(); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+}
+
+func TestExchangeDirs(t *testing.T) {
+	parent := t.TempDir()
+	a, b := filepath.Join(parent, "a"), filepath.Join(parent, "b")
+	for _, dir := range []string{a, b} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "name"), []byte(filepath.Base(dir)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := exchangeDirs(a, b)
+	if errors.Is(err, errExchangeUnsupported) {
+		t.Skip("atomic directory exchange not supported")
+	}
+	if err != nil {
+		t.Fatalf("Failed to exchange directories: %v", err)
+	}
+	for dir, want := range map[string]string{a: "b", b: "a"} {
+		if got, _ := os.ReadFile(filepath.Join(dir, "name")); string(got) != want {
+			t.Errorf("%s holds %q, want %q", dir, got, want)
+		}
+	}
+}
+
+func TestWriteAssetsToDirKeepsForeignFiles(t *testing.T) {
+	for name, write := range map[string]func(string) error{
+		"WriteAssetsToDir":               WriteAssetsToDir,
+		"WriteAssetsToDirWithConversion": WriteAssetsToDirWithConversion,
+	} {
+		dir := t.TempDir()
+		foreign := filepath.Join(dir, "agent.pkl")
+		nested := filepath.Join(dir, "external", "notes.txt")
+		if err := os.MkdirAll(filepath.Dir(nested), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range []string{foreign, nested} {
+			if err := os.WriteFile(path, []byte("mine"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := write(dir); err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		for _, path := range []string{foreign, nested} {
+			if data, err := os.ReadFile(path); err != nil || string(data) != "mine" {
+				t.Errorf("%s: %s was not preserved: %q, %v", name, path, data, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, "Workflow.pkl")); err != nil {
+			t.Errorf("%s: assets were not written: %v", name, err)
+		}
+	}
+}
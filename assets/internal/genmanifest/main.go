@@ -0,0 +1,71 @@
+// Command genmanifest writes manifest.json, the integrity manifest of the
+// embedded PKL assets. It is run by go generate in the assets package.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kdeps/schema/assets"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "genmanifest: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	// Hash the files on disk rather than assets.PKLFS, so that the manifest is
+	// correct even when the compiled-in assets are stale.
+	root := "pkl"
+	manifest := assets.Manifest{SchemaVersion: assets.SchemaVersion}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// go:embed skips files and directories whose names begin with '.' or '_'.
+		if path != root && (strings.HasPrefix(d.Name(), ".") || strings.HasPrefix(d.Name(), "_")) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, assets.ManifestEntry{
+			Path:   filepath.ToSlash(rel),
+			Size:   int64(len(data)),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to hash assets: %w", err)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile("manifest.json", []byte(strings.TrimSpace(string(data))+"\n"), 0644)
+}
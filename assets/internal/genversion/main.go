@@ -0,0 +1,119 @@
+// Command genversion writes version.go, the versions of the embedded
+// packages. The schema version is read from the VERSION file at the root of
+// the repository, which the release workflow checks against the release tag
+// that PklProject reads from env:VERSION; the pkl-go and pkl-pantry versions
+// are read from versions.json. It is run by go generate in the assets package.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "genversion: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// versions is the part of versions.json genversion reads.
+type versions struct {
+	Dependencies struct {
+		PklGo struct {
+			Version string `json:"version"`
+		} `json:"pkl-go"`
+		PklPantry struct {
+			Packages map[string]struct {
+				Version string `json:"version"`
+			} `json:"packages"`
+		} `json:"pkl-pantry"`
+	} `json:"dependencies"`
+}
+
+func run() error {
+	src, err := generate("../VERSION", "../versions.json")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile("version.go", src, 0644)
+}
+
+// generate returns the source of version.go for the VERSION file and the
+// versions.json file at the given paths.
+func generate(versionPath, versionsPath string) ([]byte, error) {
+	schema, err := readSchemaVersion(versionPath)
+	if err != nil {
+		return nil, err
+	}
+	v, err := readVersions(versionsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by genversion from VERSION and versions.json; DO NOT EDIT.\n\n")
+	b.WriteString("package assets\n\n")
+	b.WriteString("// SchemaVersion is the version of the package://schema.kdeps.com/core package\n")
+	b.WriteString("// embedded in PKLFS.\n")
+	fmt.Fprintf(&b, "const SchemaVersion = %q\n\n", schema)
+	b.WriteString("// PklGoVersion is the version of the pkl-go package embedded under\n")
+	b.WriteString("// pkl/external/pkl-go.\n")
+	fmt.Fprintf(&b, "const PklGoVersion = %q\n\n", v.Dependencies.PklGo.Version)
+	b.WriteString("// PantryVersions maps each pkl-pantry package embedded under\n")
+	b.WriteString("// pkl/external/pkl-pantry/packages to its version.\n")
+	b.WriteString("var PantryVersions = map[string]string{\n")
+	names := make([]string, 0, len(v.Dependencies.PklPantry.Packages))
+	for name := range v.Dependencies.PklPantry.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%q: %q,\n", name, v.Dependencies.PklPantry.Packages[name].Version)
+	}
+	b.WriteString("}\n")
+
+	return format.Source(b.Bytes())
+}
+
+// readSchemaVersion reads the schema version from the VERSION file at path.
+func readSchemaVersion(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema version: %w", err)
+	}
+	version := normalizeVersion(strings.TrimSpace(string(data)))
+	if version == "" {
+		return "", fmt.Errorf("failed to read schema version: %s is empty", path)
+	}
+	return version, nil
+}
+
+func readVersions(path string) (versions, error) {
+	var v versions
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return v, fmt.Errorf("failed to read versions: %w", err)
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return v, nil
+}
+
+var fourPartVersion = regexp.MustCompile(`^(\d+\.\d+\.\d+)\.\d+$`)
+
+// normalizeVersion cleans up a version the way the package version
+// expression of deps/pkl/PklProject does: a "core@v" or "v" prefix is
+// removed, and a 4-part version is cut to 3 parts.
+func normalizeVersion(version string) string {
+	version = strings.Replace(version, "core@v", "", 1)
+	version = strings.TrimPrefix(version, "v")
+	return fourPartVersion.ReplaceAllString(version, "$1")
+}
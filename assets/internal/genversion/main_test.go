@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/kdeps/schema/assets"
+)
+
+// TestVersionGoUpToDate fails when version.go disagrees with VERSION or
+// versions.json; run go generate ./assets to fix it.
+func TestVersionGoUpToDate(t *testing.T) {
+	want, err := generate("../../../VERSION", "../../../versions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../../version.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("assets/version.go is out of date with VERSION and versions.json; run go generate ./assets")
+	}
+
+	schema, err := readSchemaVersion("../../../VERSION")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assets.SchemaVersion != schema {
+		t.Errorf("SchemaVersion = %q, VERSION holds %q", assets.SchemaVersion, schema)
+	}
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := map[string]string{
+		"0.5.0":           "0.5.0",
+		"v0.5.0":          "0.5.0",
+		"core@v0.5.0":     "0.5.0",
+		"1.2.3.4":         "1.2.3",
+		"v1.0.0-rc.1+abc": "1.0.0-rc.1+abc",
+	}
+	for in, want := range tests {
+		if got := normalizeVersion(in); got != want {
+			t.Errorf("normalizeVersion(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// lockPollInterval is how often a waiting writer retries a held lock.
+	lockPollInterval = 50 * time.Millisecond
+	// lockTimeout is how long a writer waits for a lock held by a live process.
+	lockTimeout = 2 * time.Minute
+)
+
+// ErrLockTimeout is returned when an extraction lock could not be acquired in time.
+var ErrLockTimeout = errors.New("timed out waiting for assets lock")
+
+// errLockBusy is returned by tryLock when another process holds the lock.
+var errLockBusy = errors.New("lock is held by another process")
+
+// fileLock is an advisory lock on a file that records the owner's PID for
+// diagnostics. The lock itself is held with flock on Unix and LockFileEx on
+// Windows, so the operating system releases it when its owner exits and a
+// lock file left behind by a crashed process is simply taken over.
+type fileLock struct {
+	path string
+	f    *os.File
+}
+
+// acquireLock locks the file at path, creating it if needed and waiting while
+// another process holds it.
+func acquireLock(path string) (*fileLock, error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open lock file %s: %w", path, err)
+		}
+		err = tryLock(f)
+		if errors.Is(err, errLockBusy) {
+			f.Close()
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("%w: %s", ErrLockTimeout, path)
+			}
+			time.Sleep(lockPollInterval)
+			continue
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+
+		// The previous owner removes the lock file on release, so the file
+		// locked here may no longer be the one at path. Start over then, or
+		// two processes could hold locks on different files.
+		if !isLockedFile(f, path) {
+			unlock(f)
+			f.Close()
+			continue
+		}
+
+		if err := writePID(f); err != nil {
+			unlock(f)
+			f.Close()
+			return nil, fmt.Errorf("failed to write lock file %s: %w", path, err)
+		}
+		return &fileLock{path: path, f: f}, nil
+	}
+}
+
+// isLockedFile reports whether f is still the file at path.
+func isLockedFile(f *os.File, path string) bool {
+	held, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(held, current)
+}
+
+func writePID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0)
+	return err
+}
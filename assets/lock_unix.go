@@ -0,0 +1,55 @@
+//go:build !windows
+
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// tryLock takes an exclusive flock on f without blocking.
+func tryLock(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLockBusy
+	}
+	return err
+}
+
+func unlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// release removes the lock file and unlocks it. The file is removed while
+// still locked, and only if it is the one this lock holds, so that a lock
+// file taken over by another process is never removed from under it.
+// Waiters that opened the removed file notice it is gone once they lock it.
+func (l *fileLock) release() error {
+	var errs []error
+	if isLockedFile(l.f, l.path) {
+		if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	if err := unlock(l.f); err != nil {
+		errs = append(errs, err)
+	}
+	if err := l.f.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", l.path, err)
+	}
+	return nil
+}
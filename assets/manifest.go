@@ -0,0 +1,160 @@
+package assets
+
+//go:generate go run ./internal/genversion
+//go:generate go run ./internal/genmanifest
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+//go:embed manifest.json
+var manifestJSON []byte
+
+// ManifestEntry describes one embedded file.
+type ManifestEntry struct {
+	// Path is the location of the file relative to the pkl directory.
+	Path string `json:"path"`
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex-encoded SHA-256 checksum of the file content.
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest lists every file embedded in PKLFS with its size and checksum. It
+// is generated by go generate and embedded alongside PKLFS.
+type Manifest struct {
+	// SchemaVersion is the version of the core schema the manifest was generated for.
+	SchemaVersion string          `json:"schemaVersion"`
+	Files         []ManifestEntry `json:"files"`
+
+	index map[string]ManifestEntry
+}
+
+var (
+	manifestOnce sync.Once
+	manifest     *Manifest
+	manifestErr  error
+)
+
+// LoadManifest returns the embedded integrity manifest.
+func LoadManifest() (*Manifest, error) {
+	manifestOnce.Do(func() {
+		var m Manifest
+		if err := json.Unmarshal(manifestJSON, &m); err != nil {
+			manifestErr = fmt.Errorf("failed to parse embedded manifest: %w", err)
+			return
+		}
+		m.index = make(map[string]ManifestEntry, len(m.Files))
+		for _, entry := range m.Files {
+			m.index[entry.Path] = entry
+		}
+		manifest = &m
+	})
+	return manifest, manifestErr
+}
+
+// Lookup returns the entry for the file at the given path, relative to the
+// pkl directory.
+func (m *Manifest) Lookup(path string) (ManifestEntry, bool) {
+	entry, ok := m.index[path]
+	return entry, ok
+}
+
+// VerifyReport is the result of comparing a directory with the manifest.
+// Paths are slash-separated and relative to the verified directory.
+type VerifyReport struct {
+	// Missing are files in the manifest that do not exist in the directory.
+	Missing []string
+	// Modified are files whose size or checksum differs from the manifest.
+	Modified []string
+	// Extra are files in the directory that are not in the manifest.
+	Extra []string
+}
+
+// OK reports whether the directory matches the manifest exactly.
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Modified) == 0 && len(r.Extra) == 0
+}
+
+// Verify compares a directory written by WriteAssetsToDir with the embedded
+// manifest and reports missing, modified and extra files.
+func Verify(dir string) (*VerifyReport, error) {
+	m, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{}
+	seen := make(map[string]bool, len(m.Files))
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		entry, ok := m.Lookup(rel)
+		if !ok {
+			report.Extra = append(report.Extra, rel)
+			return nil
+		}
+		seen[rel] = true
+		matches, err := fileMatches(path, entry)
+		if err != nil {
+			return err
+		}
+		if !matches {
+			report.Modified = append(report.Modified, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %w", dir, err)
+	}
+
+	for _, entry := range m.Files {
+		if !seen[entry.Path] {
+			report.Missing = append(report.Missing, entry.Path)
+		}
+	}
+	sort.Strings(report.Extra)
+	sort.Strings(report.Modified)
+	return report, nil
+}
+
+// fileMatches reports whether the file at path has the size and checksum of entry.
+func fileMatches(path string, entry ManifestEntry) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() != entry.Size {
+		return false, nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)) == entry.SHA256, nil
+}
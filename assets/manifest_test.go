@@ -0,0 +1,134 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifestMatchesEmbeddedAssets(t *testing.T) {
+	m, err := LoadManifest()
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	if m.SchemaVersion != SchemaVersion {
+		t.Errorf("Manifest is for schema %s, expected %s", m.SchemaVersion, SchemaVersion)
+	}
+
+	count := 0
+	err = fs.WalkDir(PKLFS, "pkl", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		count++
+		rel := strings.TrimPrefix(path, "pkl/")
+		entry, ok := m.Lookup(rel)
+		if !ok {
+			t.Errorf("%s is not in the manifest; run go generate ./assets", rel)
+			return nil
+		}
+		data, err := PKLFS.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		if entry.Size != int64(len(data)) || entry.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("Manifest entry for %s is stale; run go generate ./assets", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk embedded assets: %v", err)
+	}
+	if count != len(m.Files) {
+		t.Errorf("Manifest lists %d files, %d are embedded; run go generate ./assets", len(m.Files), count)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteAssetsToDir(dir); err != nil {
+		t.Fatalf("Failed to write assets: %v", err)
+	}
+
+	report, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("Fresh extraction does not verify: %+v", report)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "Workflow.pkl"), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "Resource.pkl")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "external", "Extra.pkl"), []byte("extra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !slices.Equal(report.Modified, []string{"Workflow.pkl"}) {
+		t.Errorf("Expected Workflow.pkl to be modified, got %v", report.Modified)
+	}
+	if !slices.Equal(report.Missing, []string{"Resource.pkl"}) {
+		t.Errorf("Expected Resource.pkl to be missing, got %v", report.Missing)
+	}
+	if !slices.Equal(report.Extra, []string{"external/Extra.pkl"}) {
+		t.Errorf("Expected external/Extra.pkl to be extra, got %v", report.Extra)
+	}
+}
+
+func TestWriteAssetsToDirSkipsMatchingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteAssetsToDir(dir); err != nil {
+		t.Fatalf("Failed to write assets: %v", err)
+	}
+
+	unchanged := filepath.Join(dir, "Tool.pkl")
+	edited := filepath.Join(dir, "Workflow.pkl")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, path := range []string{unchanged, edited} {
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(edited, []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(unchanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteAssetsToDir(dir); err != nil {
+		t.Fatalf("Failed to rewrite assets: %v", err)
+	}
+
+	info, err := os.Stat(unchanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(past) || !os.SameFile(before, info) {
+		t.Error("Tool.pkl matched the manifest but was rewritten")
+	}
+
+	report, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Edited file was not restored: %+v", report)
+	}
+}
@@ -0,0 +1,394 @@
+package assets
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kdeps/schema/assets/pklsyntax"
+)
+
+// Module is a node of the ModuleGraph: one embedded .pkl file.
+type Module struct {
+	// Path is the location of the module relative to the pkl directory, e.g.
+	// "Resource.pkl" or "external/pkl-go/codegen/src/go.pkl".
+	Path string
+	// Imports are the embedded modules this module references directly through
+	// import, import*, amends or extends, sorted and without duplicates.
+	Imports []string
+	// Unresolved are the references that could not be mapped to an embedded file.
+	Unresolved []UnresolvedImport
+}
+
+// UnresolvedImport is a module reference that does not resolve to an embedded file.
+type UnresolvedImport struct {
+	// Module is the path of the importing module.
+	Module string
+	// Kind is the clause that holds the reference.
+	Kind pklsyntax.ClauseKind
+	// URI is the referenced URI as written in the source.
+	URI string
+	// Pos is the position of the URI in the importing module.
+	Pos pklsyntax.Position
+	// Reason explains why the reference could not be resolved.
+	Reason string
+}
+
+func (u UnresolvedImport) String() string {
+	return fmt.Sprintf("%s:%s: %s %q: %s", u.Module, u.Pos, u.Kind, u.URI, u.Reason)
+}
+
+// CycleError is returned by ModuleGraph.TopologicalOrder when modules import
+// each other.
+type CycleError struct {
+	Cycles [][]string
+}
+
+func (e *CycleError) Error() string {
+	parts := make([]string, len(e.Cycles))
+	for i, cycle := range e.Cycles {
+		parts[i] = strings.Join(cycle, " -> ")
+	}
+	return fmt.Sprintf("import cycle(s) detected: %s", strings.Join(parts, "; "))
+}
+
+// ModuleGraph is the import graph of every .pkl file embedded in PKLFS.
+//
+// Imports of the standard library (pkl:), of non-embedded packages and of
+// project dependencies (@name/...) are recorded as unresolved rather than as
+// edges. A module that imports itself, which Pkl allows, does not count as a cycle.
+type ModuleGraph struct {
+	modules map[string]*Module
+}
+
+// BuildModuleGraph parses every embedded .pkl file and returns its import graph.
+func BuildModuleGraph() (*ModuleGraph, error) {
+	g := &ModuleGraph{modules: make(map[string]*Module)}
+
+	err := fs.WalkDir(PKLFS, "pkl", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isPKLFile(p) {
+			return nil
+		}
+		data, err := PKLFS.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read embedded file %s: %w", p, err)
+		}
+		name := strings.TrimPrefix(p, "pkl/")
+		module, err := parseModule(name, string(data))
+		if err != nil {
+			return err
+		}
+		g.modules[name] = module
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build module graph: %w", err)
+	}
+	return g, nil
+}
+
+// parseModule extracts the resolved and unresolved imports of one module.
+func parseModule(name, content string) (*Module, error) {
+	clauses, err := pklsyntax.Imports(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	module := &Module{Path: name}
+	seen := make(map[string]bool)
+	for _, clause := range clauses {
+		targets, reason := resolveImport(name, clause)
+		if reason != "" {
+			module.Unresolved = append(module.Unresolved, UnresolvedImport{
+				Module: name,
+				Kind:   clause.Kind,
+				URI:    clause.URI,
+				Pos:    clause.Literal.Pos,
+				Reason: reason,
+			})
+			continue
+		}
+		for _, target := range targets {
+			if !seen[target] {
+				seen[target] = true
+				module.Imports = append(module.Imports, target)
+			}
+		}
+	}
+	sort.Strings(module.Imports)
+	return module, nil
+}
+
+// resolveImport maps a clause of the module at name to embedded paths. It
+// returns a non-empty reason if the clause does not resolve. Standard library
+// imports resolve to no paths.
+func resolveImport(name string, clause pklsyntax.Clause) ([]string, string) {
+	uri := clause.URI
+	dir := path.Dir(name)
+
+	switch {
+	case strings.HasPrefix(uri, "pkl:"):
+		return nil, ""
+	case strings.HasPrefix(uri, "package://"):
+		target, ok := localPathForPackageURL(uri)
+		if !ok {
+			return nil, "package is not embedded"
+		}
+		return existingTarget(target)
+	case strings.HasPrefix(uri, "@"):
+		return nil, "project dependency notation cannot be resolved without a PklProject"
+	case strings.Contains(uri, ":"):
+		return nil, "absolute URI is not embedded"
+	case clause.Kind == pklsyntax.ImportGlob:
+		matches, err := fs.Glob(PKLFS, path.Join("pkl", dir, uri))
+		if err != nil {
+			return nil, fmt.Sprintf("invalid glob: %v", err)
+		}
+		targets := make([]string, 0, len(matches))
+		for _, match := range matches {
+			if isPKLFile(match) {
+				targets = append(targets, strings.TrimPrefix(match, "pkl/"))
+			}
+		}
+		return targets, ""
+	case strings.HasPrefix(uri, ".../"):
+		// Triple-dot imports resolve against the nearest ancestor directory
+		// that contains the file.
+		rest := strings.TrimPrefix(uri, ".../")
+		for d := path.Dir(dir); ; d = path.Dir(d) {
+			target := path.Join(d, rest)
+			if _, err := fs.Stat(PKLFS, "pkl/"+target); err == nil {
+				return []string{target}, ""
+			}
+			if d == "." {
+				return nil, "file not found in any parent directory"
+			}
+		}
+	}
+
+	target := path.Join(dir, uri)
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return nil, "path escapes the embedded assets"
+	}
+	return existingTarget(target)
+}
+
+func existingTarget(target string) ([]string, string) {
+	if _, err := fs.Stat(PKLFS, "pkl/"+target); err != nil {
+		return nil, fmt.Sprintf("%s is not embedded", target)
+	}
+	return []string{target}, ""
+}
+
+// Modules returns the paths of all modules, sorted.
+func (g *ModuleGraph) Modules() []string {
+	paths := make([]string, 0, len(g.modules))
+	for p := range g.modules {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Module returns the module at the given path.
+func (g *ModuleGraph) Module(p string) (*Module, bool) {
+	m, ok := g.modules[p]
+	return m, ok
+}
+
+// CoreModules returns the paths of the kdeps core modules (the .pkl files at
+// the root of the pkl directory), sorted.
+func (g *ModuleGraph) CoreModules() []string {
+	var core []string
+	for _, p := range g.Modules() {
+		if !strings.Contains(p, "/") {
+			core = append(core, p)
+		}
+	}
+	return core
+}
+
+// Imports returns the direct imports of the module at p.
+func (g *ModuleGraph) Imports(p string) []string {
+	if m, ok := g.modules[p]; ok {
+		return append([]string(nil), m.Imports...)
+	}
+	return nil
+}
+
+// TransitiveImports returns every module reachable from p, excluding p
+// itself, sorted.
+func (g *ModuleGraph) TransitiveImports(p string) []string {
+	reached := g.reach([]string{p})
+	delete(reached, p)
+	return sortedKeys(reached)
+}
+
+// Closure returns the given modules together with everything they import
+// transitively, sorted. Extracting the closure of "Workflow.pkl" yields the
+// minimal set of files needed to evaluate it.
+func (g *ModuleGraph) Closure(roots ...string) []string {
+	return sortedKeys(g.reach(roots))
+}
+
+// Unresolved returns the unresolved imports of the given modules and of
+// everything they import, sorted by module and position.
+func (g *ModuleGraph) Unresolved(roots ...string) []UnresolvedImport {
+	var unresolved []UnresolvedImport
+	for _, p := range g.Closure(roots...) {
+		if m, ok := g.modules[p]; ok {
+			unresolved = append(unresolved, m.Unresolved...)
+		}
+	}
+	return unresolved
+}
+
+// UnreachableExternals returns the external files that no core module
+// reaches, sorted.
+func (g *ModuleGraph) UnreachableExternals() []string {
+	reached := g.reach(g.CoreModules())
+	var unreachable []string
+	for _, p := range g.Modules() {
+		if strings.HasPrefix(p, "external/") && !reached[p] {
+			unreachable = append(unreachable, p)
+		}
+	}
+	return unreachable
+}
+
+// Cycles returns every group of modules that import each other, directly or
+// transitively. Each cycle is sorted and the cycles are sorted by their first
+// module. Self-imports are ignored.
+func (g *ModuleGraph) Cycles() [][]string {
+	t := &tarjan{g: g, index: make(map[string]int), low: make(map[string]int), onStack: make(map[string]bool)}
+	for _, p := range g.Modules() {
+		if _, visited := t.index[p]; !visited {
+			t.visit(p)
+		}
+	}
+	sort.Slice(t.cycles, func(i, j int) bool { return t.cycles[i][0] < t.cycles[j][0] })
+	return t.cycles
+}
+
+// TopologicalOrder returns modules ordered so that every module comes after
+// the modules it imports. With no roots it orders every module; otherwise it
+// orders the closure of roots. It returns a *CycleError if those modules
+// import each other.
+func (g *ModuleGraph) TopologicalOrder(roots ...string) ([]string, error) {
+	modules := g.Modules()
+	if len(roots) > 0 {
+		modules = g.Closure(roots...)
+	}
+	included := make(map[string]bool, len(modules))
+	for _, p := range modules {
+		included[p] = true
+	}
+
+	// A cycle through a module of a closure lies entirely within the closure.
+	var cycles [][]string
+	for _, cycle := range g.Cycles() {
+		if included[cycle[0]] {
+			cycles = append(cycles, cycle)
+		}
+	}
+	if len(cycles) > 0 {
+		return nil, &CycleError{Cycles: cycles}
+	}
+
+	order := make([]string, 0, len(modules))
+	done := make(map[string]bool, len(modules))
+	var visit func(p string)
+	visit = func(p string) {
+		if done[p] {
+			return
+		}
+		done[p] = true
+		for _, dep := range g.modules[p].Imports {
+			visit(dep)
+		}
+		order = append(order, p)
+	}
+	for _, p := range modules {
+		visit(p)
+	}
+	return order, nil
+}
+
+func (g *ModuleGraph) reach(roots []string) map[string]bool {
+	reached := make(map[string]bool)
+	stack := append([]string(nil), roots...)
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		m, ok := g.modules[p]
+		if !ok || reached[p] {
+			continue
+		}
+		reached[p] = true
+		stack = append(stack, m.Imports...)
+	}
+	return reached
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tarjan finds strongly connected components with Tarjan's algorithm.
+type tarjan struct {
+	g       *ModuleGraph
+	next    int
+	index   map[string]int
+	low     map[string]int
+	stack   []string
+	onStack map[string]bool
+	cycles  [][]string
+}
+
+func (t *tarjan) visit(p string) {
+	t.index[p] = t.next
+	t.low[p] = t.next
+	t.next++
+	t.stack = append(t.stack, p)
+	t.onStack[p] = true
+
+	for _, dep := range t.g.modules[p].Imports {
+		if dep == p {
+			continue
+		}
+		if _, visited := t.index[dep]; !visited {
+			t.visit(dep)
+			t.low[p] = min(t.low[p], t.low[dep])
+		} else if t.onStack[dep] {
+			t.low[p] = min(t.low[p], t.index[dep])
+		}
+	}
+
+	if t.low[p] != t.index[p] {
+		return
+	}
+	var component []string
+	for {
+		top := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		t.onStack[top] = false
+		component = append(component, top)
+		if top == p {
+			break
+		}
+	}
+	if len(component) > 1 {
+		sort.Strings(component)
+		t.cycles = append(t.cycles, component)
+	}
+}
@@ -0,0 +1,117 @@
+package assets
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestModuleGraph(t *testing.T) {
+	g, err := BuildModuleGraph()
+	if err != nil {
+		t.Fatalf("Failed to build module graph: %v", err)
+	}
+
+	files, err := ListPKLFiles()
+	if err != nil {
+		t.Fatalf("Failed to list PKL files: %v", err)
+	}
+	if core := g.CoreModules(); !slices.Equal(core, files) {
+		t.Errorf("Expected core modules %v, got %v", files, core)
+	}
+
+	t.Run("direct_imports", func(t *testing.T) {
+		imports := g.Imports("Resource.pkl")
+		for _, expected := range []string{"LLM.pkl", "Exec.pkl", "HTTP.pkl", "Python.pkl", "external/pkl-go/codegen/src/go.pkl"} {
+			if !slices.Contains(imports, expected) {
+				t.Errorf("Expected Resource.pkl to import %s, got %v", expected, imports)
+			}
+		}
+	})
+
+	t.Run("transitive_imports", func(t *testing.T) {
+		imports := g.TransitiveImports("Workflow.pkl")
+		for _, expected := range []string{"Project.pkl", "APIServer.pkl", "Docker.pkl", "external/pkl-go/codegen/src/internal/utils.pkl"} {
+			if !slices.Contains(imports, expected) {
+				t.Errorf("Expected Workflow.pkl to reach %s, got %v", expected, imports)
+			}
+		}
+		if slices.Contains(imports, "Workflow.pkl") {
+			t.Error("TransitiveImports should not include the module itself")
+		}
+		if slices.Contains(imports, "Resource.pkl") {
+			t.Error("Workflow.pkl should not reach Resource.pkl")
+		}
+	})
+
+	t.Run("closure_topological_order", func(t *testing.T) {
+		order, err := g.TopologicalOrder("Workflow.pkl")
+		if err != nil {
+			t.Fatalf("Failed to order Workflow.pkl closure: %v", err)
+		}
+		if !slices.Equal(sortedCopy(order), g.Closure("Workflow.pkl")) {
+			t.Errorf("Order %v does not cover the closure", order)
+		}
+		position := make(map[string]int)
+		for i, p := range order {
+			position[p] = i
+		}
+		for _, p := range order {
+			for _, dep := range g.Imports(p) {
+				if dep != p && position[dep] > position[p] {
+					t.Errorf("%s is ordered before its import %s", p, dep)
+				}
+			}
+		}
+	})
+
+	t.Run("core_modules_have_no_missing_imports", func(t *testing.T) {
+		for _, u := range g.Unresolved(g.CoreModules()...) {
+			t.Errorf("Unresolved import reachable from core: %s", u)
+		}
+	})
+
+	t.Run("unreachable_externals", func(t *testing.T) {
+		unreachable := g.UnreachableExternals()
+		if slices.Contains(unreachable, "external/pkl-go/codegen/src/go.pkl") {
+			t.Error("go.pkl is imported by core modules and should be reachable")
+		}
+		if !slices.Contains(unreachable, "external/pkl-go/codegen/src/Generator.pkl") {
+			t.Error("Generator.pkl is not imported by core modules and should be unreachable")
+		}
+	})
+}
+
+func TestModuleGraphCycles(t *testing.T) {
+	g := &ModuleGraph{modules: map[string]*Module{
+		"A.pkl": {Path: "A.pkl", Imports: []string{"A.pkl", "B.pkl"}},
+		"B.pkl": {Path: "B.pkl", Imports: []string{"C.pkl"}},
+		"C.pkl": {Path: "C.pkl", Imports: []string{"B.pkl"}},
+		"D.pkl": {Path: "D.pkl", Imports: []string{"D.pkl"}},
+	}}
+
+	cycles := g.Cycles()
+	if len(cycles) != 1 || !slices.Equal(cycles[0], []string{"B.pkl", "C.pkl"}) {
+		t.Errorf("Expected one cycle [B.pkl C.pkl], got %v", cycles)
+	}
+
+	_, err := g.TopologicalOrder()
+	var cycleErr *CycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("Expected CycleError, got %v", err)
+	}
+
+	order, err := g.TopologicalOrder("D.pkl")
+	if err != nil {
+		t.Fatalf("Self-imports should not be reported as cycles: %v", err)
+	}
+	if !slices.Equal(order, []string{"D.pkl"}) {
+		t.Errorf("Unexpected order %v", order)
+	}
+}
+
+func sortedCopy(s []string) []string {
+	c := slices.Clone(s)
+	slices.Sort(c)
+	return c
+}
@@ -0,0 +1,191 @@
+package assets
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+const (
+	schemaHost  = "schema.kdeps.com"
+	pklLangHost = "pkg.pkl-lang.org"
+)
+
+// VersionMismatchError is returned by ModuleReader when a package URI asks for
+// a different version than the one embedded in PKLFS.
+type VersionMismatchError struct {
+	// URI is the package URI that was requested.
+	URI string
+	// Package is the package name, e.g. "core" or "pkl.experimental.uri".
+	Package string
+	// Requested is the version in the URI.
+	Requested string
+	// Embedded is the version available in PKLFS.
+	Embedded string
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("%s: requested %s@%s but the embedded assets provide %s@%s",
+		e.URI, e.Package, e.Requested, e.Package, e.Embedded)
+}
+
+// ModuleReader is a pkl.ModuleReader for the "package" scheme that answers
+// package://schema.kdeps.com/core, package://pkg.pkl-lang.org/pkl-go and
+// package://pkg.pkl-lang.org/pkl-pantry URIs from the embedded assets, so that
+// agent files can be evaluated without network access or a temp directory.
+// Modules are served as embedded, without package URL conversion, so their
+// imports resolve against the package URI they were read from.
+//
+// Any other package URI is rejected, because registering a reader for the
+// "package" scheme replaces Pkl's own package resolution.
+type ModuleReader struct {
+	fsys fs.FS
+}
+
+var _ pkl.ModuleReader = (*ModuleReader)(nil)
+
+// NewModuleReader returns a ModuleReader backed by the pkl directory of PKLFS.
+func NewModuleReader() *ModuleReader {
+	root, err := fs.Sub(PKLFS, "pkl")
+	if err != nil {
+		// fs.Sub only fails for invalid directory names.
+		panic(err)
+	}
+	return &ModuleReader{fsys: root}
+}
+
+// WithEmbeddedPackages configures an evaluator to resolve the embedded package
+// URIs through a ModuleReader.
+//
+//	evaluator, err := pkl.NewEvaluator(ctx, pkl.PreconfiguredOptions, assets.WithEmbeddedPackages)
+var WithEmbeddedPackages = func(opts *pkl.EvaluatorOptions) {
+	pkl.WithModuleReader(NewModuleReader())(opts)
+}
+
+// Scheme returns "package".
+func (r *ModuleReader) Scheme() string {
+	return "package"
+}
+
+// IsGlobbable reports false; package URIs cannot be globbed.
+func (r *ModuleReader) IsGlobbable() bool {
+	return false
+}
+
+// HasHierarchicalUris reports true.
+func (r *ModuleReader) HasHierarchicalUris() bool {
+	return true
+}
+
+// IsLocal reports false; packages are not local to the runtime.
+func (r *ModuleReader) IsLocal() bool {
+	return false
+}
+
+// ListElements lists the embedded directory addressed by u. Pkl does not call
+// it because the reader is not globbable.
+func (r *ModuleReader) ListElements(u url.URL) ([]pkl.PathElement, error) {
+	if strings.Trim(u.Fragment, "/") == "" {
+		// The root of the package.
+		u.Fragment = "/."
+	}
+	dir, err := ResolvePackageURI(u)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(r.fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: directory not found in embedded assets: %w", u.String(), err)
+	}
+	elements := make([]pkl.PathElement, len(entries))
+	for i, entry := range entries {
+		elements[i] = pkl.NewPathElement(entry.Name(), entry.IsDir())
+	}
+	return elements, nil
+}
+
+// Read returns the content of the embedded module addressed by u.
+func (r *ModuleReader) Read(u url.URL) (string, error) {
+	name, err := ResolvePackageURI(u)
+	if err != nil {
+		return "", err
+	}
+	data, err := fs.ReadFile(r.fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("%s: module not found in embedded assets: %w", u.String(), err)
+	}
+	return string(data), nil
+}
+
+// ResolvePackageURI maps a package URI to the path of the embedded file it
+// refers to, relative to the pkl directory of PKLFS. It returns a
+// *VersionMismatchError if the URI asks for a version that is not embedded.
+func ResolvePackageURI(u url.URL) (string, error) {
+	ref, err := parsePackageURI(u)
+	if err != nil {
+		return "", err
+	}
+
+	embedded := SchemaVersion
+	switch ref.name {
+	case "core":
+	case "pkl.golang":
+		embedded = PklGoVersion
+	default:
+		var ok bool
+		if embedded, ok = PantryVersions[ref.name]; !ok {
+			return "", fmt.Errorf("%s: pkl-pantry package %q is not embedded", u.String(), ref.name)
+		}
+	}
+	if ref.version != embedded {
+		return "", &VersionMismatchError{URI: u.String(), Package: ref.name, Requested: ref.version, Embedded: embedded}
+	}
+	return ref.path, nil
+}
+
+// packageRef is a package URI that points into the embedded assets.
+type packageRef struct {
+	// name is "core", "pkl.golang" or the name of a pkl-pantry package.
+	name    string
+	version string
+	// path is the embedded path relative to the pkl directory.
+	path string
+}
+
+// parsePackageURI splits a package URI for one of the embedded packages into
+// its parts, without checking the version or whether the file exists.
+func parsePackageURI(u url.URL) (packageRef, error) {
+	if u.Scheme != "package" {
+		return packageRef{}, fmt.Errorf("%s: not a package URI", u.String())
+	}
+	pkg, version, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "@")
+	if !ok || version == "" {
+		return packageRef{}, fmt.Errorf("%s: package URI has no version", u.String())
+	}
+	asset := strings.TrimPrefix(u.Fragment, "/")
+	if asset == "" {
+		return packageRef{}, fmt.Errorf("%s: package URI has no module path", u.String())
+	}
+	asset = path.Clean(asset)
+	if !fs.ValidPath(asset) {
+		return packageRef{}, fmt.Errorf("%s: invalid module path %q", u.String(), asset)
+	}
+
+	switch {
+	case u.Host == schemaHost && pkg == "core":
+		return packageRef{name: "core", version: version, path: asset}, nil
+	case u.Host == pklLangHost && pkg == "pkl-go/pkl.golang":
+		// The pkl.golang package is rooted at codegen/src in the pkl-go repository.
+		asset = strings.TrimPrefix(asset, "codegen/src/")
+		return packageRef{name: "pkl.golang", version: version, path: path.Join("external/pkl-go/codegen/src", asset)}, nil
+	case u.Host == pklLangHost && strings.HasPrefix(pkg, "pkl-pantry/"):
+		name := strings.TrimPrefix(pkg, "pkl-pantry/")
+		return packageRef{name: name, version: version, path: path.Join("external/pkl-pantry/packages", name, asset)}, nil
+	}
+	return packageRef{}, fmt.Errorf("%s: package is not embedded; only %s/core, %s/pkl-go and %s/pkl-pantry are available offline",
+		u.String(), schemaHost, pklLangHost, pklLangHost)
+}
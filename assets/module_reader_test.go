@@ -0,0 +1,278 @@
+package assets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets/pklsyntax"
+)
+
+func TestResolvePackageURI(t *testing.T) {
+	tests := []struct {
+		name     string
+		uri      string
+		expected string
+	}{
+		{
+			name:     "schema core",
+			uri:      "package://schema.kdeps.com/core@" + SchemaVersion + "#/Workflow.pkl",
+			expected: "Workflow.pkl",
+		},
+		{
+			name:     "pkl-go",
+			uri:      "package://pkg.pkl-lang.org/pkl-go/pkl.golang@" + PklGoVersion + "#/go.pkl",
+			expected: "external/pkl-go/codegen/src/go.pkl",
+		},
+		{
+			name:     "pkl-go with codegen path",
+			uri:      "package://pkg.pkl-lang.org/pkl-go/pkl.golang@" + PklGoVersion + "#/codegen/src/go.pkl",
+			expected: "external/pkl-go/codegen/src/go.pkl",
+		},
+		{
+			name:     "pkl-pantry",
+			uri:      "package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@" + PantryVersions["pkl.experimental.uri"] + "#/URI.pkl",
+			expected: "external/pkl-pantry/packages/pkl.experimental.uri/URI.pkl",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse(tc.uri)
+			if err != nil {
+				t.Fatalf("Failed to parse %s: %v", tc.uri, err)
+			}
+			got, err := ResolvePackageURI(*u)
+			if err != nil {
+				t.Fatalf("Failed to resolve %s: %v", tc.uri, err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestModuleReaderRead(t *testing.T) {
+	reader := NewModuleReader()
+
+	u, _ := url.Parse("package://schema.kdeps.com/core@" + SchemaVersion + "#/Workflow.pkl")
+	content, err := reader.Read(*u)
+	if err != nil {
+		t.Fatalf("Failed to read Workflow.pkl: %v", err)
+	}
+	if !strings.Contains(content, "module org.kdeps.pkl.Workflow") {
+		t.Error("Workflow.pkl doesn't contain expected content")
+	}
+	if raw, _ := PKLFS.ReadFile("pkl/Workflow.pkl"); content != string(raw) {
+		t.Error("Workflow.pkl is not served as embedded")
+	}
+
+	t.Run("version_mismatch", func(t *testing.T) {
+		u, _ := url.Parse("package://schema.kdeps.com/core@0.0.1#/Workflow.pkl")
+		_, err := reader.Read(*u)
+		var mismatch *VersionMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("Expected VersionMismatchError, got %v", err)
+		}
+		if mismatch.Requested != "0.0.1" || mismatch.Embedded != SchemaVersion {
+			t.Errorf("Unexpected mismatch details: %+v", mismatch)
+		}
+	})
+
+	t.Run("unknown_package", func(t *testing.T) {
+		for _, raw := range []string{
+			"package://example.com/other@1.0.0#/Other.pkl",
+			"package://pkg.pkl-lang.org/pkl-pantry/does.not.exist@1.0.0#/X.pkl",
+			"package://schema.kdeps.com/core@" + SchemaVersion + "#/Missing.pkl",
+			"package://schema.kdeps.com/core#/Workflow.pkl",
+		} {
+			u, _ := url.Parse(raw)
+			if _, err := reader.Read(*u); err == nil {
+				t.Errorf("Expected error reading %s", raw)
+			}
+		}
+	})
+}
+
+// TestModuleReaderImportsResolve follows the imports of every module the
+// reader serves from the core and pkl-go package roots, and checks that each
+// one resolves to a module the reader lists and reads.
+func TestModuleReaderImportsResolve(t *testing.T) {
+	reader := NewModuleReader()
+
+	var queue []url.URL
+	for _, root := range []string{
+		"package://schema.kdeps.com/core@" + SchemaVersion + "#/",
+		"package://pkg.pkl-lang.org/pkl-go/pkl.golang@" + PklGoVersion + "#/",
+	} {
+		u, _ := url.Parse(root)
+		elements, err := reader.ListElements(*u)
+		if err != nil {
+			t.Fatalf("Failed to list %s: %v", root, err)
+		}
+		for _, e := range elements {
+			if !e.IsDirectory() && strings.HasSuffix(e.Name(), ".pkl") {
+				module := *u
+				module.Fragment = "/" + e.Name()
+				queue = append(queue, module)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		if seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+
+		content, err := reader.Read(u)
+		if err != nil {
+			t.Errorf("Failed to read %s: %v", u.String(), err)
+			continue
+		}
+		clauses, err := pklsyntax.Imports(content)
+		if err != nil {
+			t.Errorf("Failed to parse %s: %v", u.String(), err)
+			continue
+		}
+		for _, clause := range clauses {
+			target, ok := resolveServedImport(t, u, clause.URI)
+			if !ok {
+				continue
+			}
+			dir := target
+			dir.Fragment = path.Dir(target.Fragment)
+			elements, err := reader.ListElements(dir)
+			if err != nil {
+				t.Errorf("%s: %s %q: %v", u.String(), clause.Kind, clause.URI, err)
+				continue
+			}
+			found := false
+			for _, e := range elements {
+				found = found || (!e.IsDirectory() && e.Name() == path.Base(target.Fragment))
+			}
+			if !found {
+				t.Errorf("%s: %s %q: %s is not listed by the reader", u.String(), clause.Kind, clause.URI, target.String())
+				continue
+			}
+			queue = append(queue, target)
+		}
+	}
+	if len(seen) < 20 {
+		t.Errorf("Only %d modules were checked", len(seen))
+	}
+}
+
+// resolveServedImport resolves uri, imported by the module at base, the way
+// Pkl does for a module read from a package. It reports false for standard
+// library modules.
+func resolveServedImport(t *testing.T, base url.URL, uri string) (url.URL, bool) {
+	t.Helper()
+	switch {
+	case strings.HasPrefix(uri, "pkl:"):
+		return url.URL{}, false
+	case strings.HasPrefix(uri, "package://"):
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Errorf("%s: invalid import %q: %v", base.String(), uri, err)
+			return url.URL{}, false
+		}
+		return *u, true
+	case strings.HasPrefix(uri, "@"), strings.HasPrefix(uri, "..."), strings.Contains(uri, ":"):
+		t.Errorf("%s: import %q cannot be resolved by the reader", base.String(), uri)
+		return url.URL{}, false
+	}
+	fragment := path.Join(path.Dir(base.Fragment), uri)
+	if !strings.HasPrefix(fragment, "/") {
+		t.Errorf("%s: import %q escapes the package", base.String(), uri)
+		return url.URL{}, false
+	}
+	target := base
+	target.Fragment = fragment
+	return target, true
+}
+
+func TestVersionsMatchVersionsJSON(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "versions.json"))
+	if err != nil {
+		t.Skipf("versions.json not available: %v", err)
+	}
+	var versions struct {
+		Dependencies struct {
+			PklGo struct {
+				Version string `json:"version"`
+			} `json:"pkl-go"`
+			PklPantry struct {
+				Packages map[string]struct {
+					Version string `json:"version"`
+				} `json:"packages"`
+			} `json:"pkl-pantry"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &versions); err != nil {
+		t.Fatalf("Failed to parse versions.json: %v", err)
+	}
+
+	if versions.Dependencies.PklGo.Version != PklGoVersion {
+		t.Errorf("PklGoVersion is %s, versions.json has %s", PklGoVersion, versions.Dependencies.PklGo.Version)
+	}
+	if len(versions.Dependencies.PklPantry.Packages) != len(PantryVersions) {
+		t.Errorf("PantryVersions has %d packages, versions.json has %d", len(PantryVersions), len(versions.Dependencies.PklPantry.Packages))
+	}
+	for name, pkg := range versions.Dependencies.PklPantry.Packages {
+		if PantryVersions[name] != pkg.Version {
+			t.Errorf("PantryVersions[%s] is %q, versions.json has %q", name, PantryVersions[name], pkg.Version)
+		}
+	}
+}
+
+func TestWithEmbeddedPackagesEvaluation(t *testing.T) {
+	if _, err := exec.LookPath("pkl"); err != nil && os.Getenv("PKL_EXEC") == "" {
+		t.Skip("pkl binary not available")
+	}
+
+	dir := t.TempDir()
+	agent := filepath.Join(dir, "workflow.pkl")
+	source := `amends "package://schema.kdeps.com/core@` + SchemaVersion + `#/Workflow.pkl"
+
+AgentID = "offlineAgent"
+Description = "offline evaluation"
+TargetActionID = "responseResource"
+Workflows {}
+Settings {
+  AgentSettings {
+    Models {}
+  }
+}
+`
+	if err := os.WriteFile(agent, []byte(source), 0644); err != nil {
+		t.Fatalf("Failed to write agent file: %v", err)
+	}
+
+	ctx := context.Background()
+	evaluator, err := pkl.NewEvaluator(ctx, pkl.PreconfiguredOptions, WithEmbeddedPackages)
+	if err != nil {
+		t.Fatalf("Failed to create evaluator: %v", err)
+	}
+	defer evaluator.Close()
+
+	output, err := evaluator.EvaluateOutputText(ctx, pkl.FileSource(agent))
+	if err != nil {
+		t.Fatalf("Failed to evaluate agent offline: %v", err)
+	}
+	if !strings.Contains(output, "offlineAgent") {
+		t.Errorf("Evaluated output doesn't contain the agent ID:\n%s", output)
+	}
+}
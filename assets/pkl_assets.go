@@ -2,13 +2,19 @@
 package assets
 
 import (
+	"bytes"
 	"embed"
 	"fmt"
 	"io/fs"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+
+	"github.com/kdeps/schema/assets/pklsyntax"
 )
 
 //go:embed pkl
@@ -102,75 +108,120 @@ func walkDir(dir string, files *[]string) error {
 	return nil
 }
 
-// ConvertPackageURLsToLocalPaths converts package:// URLs to local relative paths
+// ConvertPackageURLsToLocalPaths converts the package:// URLs of the embedded packages
+// in import, import*, amends and extends clauses to local relative paths.
+// URLs in comments and ordinary strings are left untouched.
 func ConvertPackageURLsToLocalPaths(content string) string {
-	// Convert pkl-go package URLs
-	pklGoPattern := regexp.MustCompile(`package://pkg\.pkl-lang\.org/pkl-go/pkl\.golang@[^"#]*#/(?:codegen/src/)?([^"]+)`)
-	content = pklGoPattern.ReplaceAllString(content, `external/pkl-go/codegen/src/$1`)
+	converted, _ := ConvertPackageURLsWithEdits(content)
+	return converted
+}
 
-	// Convert pkl-pantry package URLs
-	pklPantryPattern := regexp.MustCompile(`package://pkg\.pkl-lang\.org/pkl-pantry/([^@"]+)@[^"#]*#/([^"]+)`)
-	content = pklPantryPattern.ReplaceAllString(content, `external/pkl-pantry/packages/$1/$2`)
+// ConvertPackageURLsWithEdits is like ConvertPackageURLsToLocalPaths but also
+// returns the edits that were applied, with their positions in content.
+// Malformed source is converted up to the first syntax error.
+func ConvertPackageURLsWithEdits(content string) (string, []pklsyntax.Edit) {
+	converted, edits, _ := pklsyntax.RewriteImports(content, func(clause pklsyntax.Clause) (string, bool) {
+		return localPathForPackageURL(clause.URI)
+	})
+	return converted, edits
+}
 
-	// Convert schema.kdeps.com core package URLs to local paths
-	// This handles URLs like package://schema.kdeps.com/core@1.0.0#/Workflow.pkl
-	schemaCorePattern := regexp.MustCompile(`package://schema\.kdeps\.com/core@[^"#]*#/([^"]+)`)
-	content = schemaCorePattern.ReplaceAllString(content, `$1`)
+// MissingTarget is a package URL whose local path does not exist in PKLFS.
+type MissingTarget struct {
+	// URI is the package URL found in the source.
+	URI string
+	// Target is the embedded path the URL maps to, relative to the pkl directory.
+	Target string
+	// Pos is the position of the URL in the source.
+	Pos pklsyntax.Position
+}
 
-	return content
+// MissingTargetError is returned by ConvertPackageURLsForFile when one or more
+// rewritten imports point at files that are not embedded.
+type MissingTargetError struct {
+	// File is the path of the converted file, relative to the pkl directory.
+	File    string
+	Missing []MissingTarget
 }
 
-// ConvertImportStatements converts import and amends statements from package URLs to local paths
-func ConvertImportStatements(content string) string {
-	// Convert import statements for pkg.pkl-lang.org
-	importPattern := regexp.MustCompile(`(import\s+)"(package://pkg\.pkl-lang\.org/[^"]+)"`)
-	content = importPattern.ReplaceAllStringFunc(content, func(match string) string {
-		parts := importPattern.FindStringSubmatch(match)
-		if len(parts) == 3 {
-			localPath := ConvertPackageURLsToLocalPaths(`"` + parts[2] + `"`)
-			localPath = strings.Trim(localPath, `"`)
-			return parts[1] + `"` + localPath + `"`
-		}
-		return match
-	})
+func (e *MissingTargetError) Error() string {
+	var details strings.Builder
+	fmt.Fprintf(&details, "%s: %d import(s) point at files that are not embedded:", e.File, len(e.Missing))
+	for _, m := range e.Missing {
+		fmt.Fprintf(&details, "\n  %s: %s -> %s", m.Pos, m.URI, m.Target)
+	}
+	return details.String()
+}
 
-	// Convert amends statements for pkg.pkl-lang.org
-	amendsPattern := regexp.MustCompile(`(amends\s+)"(package://pkg\.pkl-lang\.org/[^"]+)"`)
-	content = amendsPattern.ReplaceAllStringFunc(content, func(match string) string {
-		parts := amendsPattern.FindStringSubmatch(match)
-		if len(parts) == 3 {
-			localPath := ConvertPackageURLsToLocalPaths(`"` + parts[2] + `"`)
-			localPath = strings.Trim(localPath, `"`)
-			return parts[1] + `"` + localPath + `"`
-		}
-		return match
+// ConvertPackageURLsForFile converts the package:// URLs in the import, import*,
+// amends and extends clauses of content to paths relative to file, which is the
+// location of content relative to the pkl directory (for example
+// "external/pkl-pantry/packages/org.openapis.v3/Schema.pkl"). The converted
+// content is returned together with a *MissingTargetError if any rewritten
+// import points at a file that is not embedded in PKLFS.
+func ConvertPackageURLsForFile(file, content string) (string, error) {
+	dir := path.Dir(file)
+	var missing []MissingTarget
+	converted, _, err := pklsyntax.RewriteImports(content, func(clause pklsyntax.Clause) (string, bool) {
+		target, ok := localPathForPackageURL(clause.URI)
+		if !ok {
+			return "", false
+		}
+		if _, statErr := fs.Stat(PKLFS, "pkl/"+target); statErr != nil {
+			missing = append(missing, MissingTarget{URI: clause.URI, Target: target, Pos: clause.Literal.Pos})
+		}
+		return relativeSlashPath(dir, target), true
 	})
+	if err != nil {
+		return converted, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(missing) > 0 {
+		return converted, &MissingTargetError{File: file, Missing: missing}
+	}
+	return converted, nil
+}
 
-	// Convert import statements for schema.kdeps.com
-	schemaImportPattern := regexp.MustCompile(`(import\s+)"(package://schema\.kdeps\.com/[^"]+)"`)
-	content = schemaImportPattern.ReplaceAllStringFunc(content, func(match string) string {
-		parts := schemaImportPattern.FindStringSubmatch(match)
-		if len(parts) == 3 {
-			localPath := ConvertPackageURLsToLocalPaths(`"` + parts[2] + `"`)
-			localPath = strings.Trim(localPath, `"`)
-			return parts[1] + `"` + localPath + `"`
-		}
-		return match
-	})
+// relativeSlashPath returns the slash-separated path of target relative to the
+// directory dir. Both are relative to the same root.
+func relativeSlashPath(dir, target string) string {
+	var from []string
+	if dir = path.Clean(dir); dir != "." {
+		from = strings.Split(dir, "/")
+	}
+	to := strings.Split(path.Clean(target), "/")
 
-	// Convert amends statements for schema.kdeps.com
-	schemaAmendsPattern := regexp.MustCompile(`(amends\s+)"(package://schema\.kdeps\.com/[^"]+)"`)
-	content = schemaAmendsPattern.ReplaceAllStringFunc(content, func(match string) string {
-		parts := schemaAmendsPattern.FindStringSubmatch(match)
-		if len(parts) == 3 {
-			localPath := ConvertPackageURLsToLocalPaths(`"` + parts[2] + `"`)
-			localPath = strings.Trim(localPath, `"`)
-			return parts[1] + `"` + localPath + `"`
-		}
-		return match
-	})
+	common := 0
+	for common < len(from) && common < len(to)-1 && from[common] == to[common] {
+		common++
+	}
+	parts := make([]string, 0, len(from)-common+len(to)-common)
+	for range from[common:] {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, to[common:]...)
+	return strings.Join(parts, "/")
+}
 
-	return content
+// ConvertImportStatements converts import and amends statements from package URLs to local paths
+func ConvertImportStatements(content string) string {
+	return ConvertPackageURLsToLocalPaths(content)
+}
+
+// localPathForPackageURL returns the local relative path for a package:// URL
+// of one of the embedded packages.
+func localPathForPackageURL(uri string) (string, bool) {
+	if !strings.HasPrefix(uri, "package://") {
+		return "", false
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", false
+	}
+	ref, err := parsePackageURI(*u)
+	if err != nil {
+		return "", false
+	}
+	return ref.path, true
 }
 
 // GetPKLFileWithFullConversion reads a PKL file and applies all available conversion methods
@@ -179,11 +230,11 @@ func GetPKLFileWithFullConversion(filename string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	
+
 	// Apply redundant conversions to ensure all package URLs are converted
 	content = ConvertPackageURLsToLocalPaths(content)
 	content = ConvertImportStatements(content)
-	
+
 	return content, nil
 }
 
@@ -192,14 +243,14 @@ func ValidateLocalPaths(content string) (bool, []string) {
 	// Check for pkg.pkl-lang.org URLs
 	pklLangPattern := regexp.MustCompile(`package://pkg\.pkl-lang\.org/[^"\s]+`)
 	pklLangMatches := pklLangPattern.FindAllString(content, -1)
-	
-	// Check for schema.kdeps.com URLs  
+
+	// Check for schema.kdeps.com URLs
 	schemaPattern := regexp.MustCompile(`package://schema\.kdeps\.com/[^"\s]+`)
 	schemaMatches := schemaPattern.FindAllString(content, -1)
-	
+
 	// Combine all matches
 	allMatches := append(pklLangMatches, schemaMatches...)
-	
+
 	return len(allMatches) == 0, allMatches
 }
 
@@ -211,19 +262,19 @@ func ValidateAllPKLFiles() (map[string][]string, error) {
 	}
 
 	invalidFiles := make(map[string][]string)
-	
+
 	for _, filename := range files {
 		content, err := GetPKLFileAsString(filename)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
 		}
-		
+
 		isValid, matches := ValidateLocalPaths(content)
 		if !isValid {
 			invalidFiles[filename] = matches
 		}
 	}
-	
+
 	return invalidFiles, nil
 }
 
@@ -235,15 +286,19 @@ func ConvertAllPKLFiles() (map[string]string, error) {
 	}
 
 	convertedFiles := make(map[string]string)
-	
+
 	for _, filename := range files {
-		content, err := GetPKLFileWithFullConversion(filename)
+		content, err := GetPKLFileAsString(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		content, err = ConvertPackageURLsForFile(filename, content)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert %s: %w", filename, err)
 		}
 		convertedFiles[filename] = content
 	}
-	
+
 	return convertedFiles, nil
 }
 
@@ -273,7 +328,7 @@ func EnsureOfflineCompatibility() error {
 // and returns the complete path to the temporary directory.
 func CopyAssetsToTempDir() (string, error) {
 	// Create a temporary directory
-	tempDir, err := os.MkdirTemp("", "pkl-assets-*")
+	tempDir, err := os.MkdirTemp("", tempDirPattern())
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp directory: %w", err)
 	}
@@ -323,7 +378,7 @@ func CopyAssetsToTempDir() (string, error) {
 // with package URL to local path conversion applied, and returns the complete path.
 func CopyAssetsToTempDirWithConversion() (string, error) {
 	// Create a temporary directory
-	tempDir, err := os.MkdirTemp("", "pkl-assets-*")
+	tempDir, err := os.MkdirTemp("", tempDirPattern())
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp directory: %w", err)
 	}
@@ -355,8 +410,10 @@ func CopyAssetsToTempDirWithConversion() (string, error) {
 		// Apply conversion if it's a .pkl file
 		content := string(data)
 		if filepath.Ext(path) == ".pkl" {
-			content = ConvertPackageURLsToLocalPaths(content)
-			content = ConvertImportStatements(content)
+			content, err = ConvertPackageURLsForFile(filepath.ToSlash(relPath), content)
+			if err != nil {
+				return err
+			}
 		}
 
 		// Write the file to the temp directory
@@ -378,140 +435,110 @@ func CopyAssetsToTempDirWithConversion() (string, error) {
 
 // WriteAssetsToDir writes all embedded PKL assets to the specified directory.
 // If the directory doesn't exist, it will be created.
-// Returns the complete path to the directory.
+// The assets are merged into the directory: other files in it are left
+// alone, and files whose content is already up to date are not rewritten.
+// Concurrent writers are serialized and each file is replaced atomically; use
+// ExtractAssets for a directory dedicated to the assets when readers must
+// never observe a mix of old and new files.
 func WriteAssetsToDir(targetDir string) error {
-	// Create the target directory if it doesn't exist
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return fmt.Errorf("failed to create target directory: %w", err)
+	root, err := fs.Sub(PKLFS, "pkl")
+	if err != nil {
+		return err
 	}
-
-	// Copy all files from the embedded filesystem to the target directory
-	err := fs.WalkDir(PKLFS, "pkl", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Calculate the destination path in the target directory
-		relPath, err := filepath.Rel("pkl", path)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path for %s: %w", path, err)
-		}
-		destPath := filepath.Join(targetDir, relPath)
-
-		// If it's a directory, create it
-		if d.IsDir() {
-			return os.MkdirAll(destPath, 0755)
-		}
-
-		// Read the file from embedded FS
-		data, err := PKLFS.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read embedded file %s: %w", path, err)
-		}
-
-		// Write the file to the target directory
-		if err := os.WriteFile(destPath, data, 0644); err != nil {
-			return fmt.Errorf("failed to write file %s: %w", destPath, err)
-		}
-
-		return nil
-	})
-
+	m, err := LoadManifest()
 	if err != nil {
+		return err
+	}
+	// Skip files whose size and checksum already match the embedded manifest
+	matchesManifest := func(path, destPath string, _ []byte) bool {
+		entry, ok := m.Lookup(path)
+		if !ok {
+			return false
+		}
+		matches, _ := fileMatches(destPath, entry)
+		return matches
+	}
+	if err := writeAssetsInto(targetDir, root, matchesManifest); err != nil {
 		return fmt.Errorf("failed to write assets to directory: %w", err)
 	}
-
 	return nil
 }
 
 // WriteAssetsToDirWithConversion writes all embedded PKL assets to the specified directory
 // with package URL to local path conversion applied.
 // If the directory doesn't exist, it will be created.
+// Like WriteAssetsToDir, the assets are merged into the directory; see
+// ExtractAssetsWithConversion for whole-tree swaps.
 func WriteAssetsToDirWithConversion(targetDir string) error {
-	// Create the target directory if it doesn't exist
+	// The manifest describes the unconverted assets, so compare the content
+	sameContent := func(_, destPath string, data []byte) bool {
+		current, err := os.ReadFile(destPath)
+		return err == nil && bytes.Equal(current, data)
+	}
+	if err := writeAssetsInto(targetDir, NewConvertedFS(), sameContent); err != nil {
+		return fmt.Errorf("failed to write assets to directory with conversion: %w", err)
+	}
+	return nil
+}
+
+// writeAssetsInto writes every file of fsys below targetDir under the
+// targetDir + ".lock" lock, skipping files for which upToDate reports true.
+func writeAssetsInto(targetDir string, fsys fs.FS, upToDate func(path, destPath string, data []byte) bool) error {
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		return fmt.Errorf("failed to create target directory: %w", err)
 	}
 
-	// Copy all files from the embedded filesystem to the target directory
-	err := fs.WalkDir(PKLFS, "pkl", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	lock, err := acquireLock(filepath.Clean(targetDir) + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.release()
 
-		// Calculate the destination path in the target directory
-		relPath, err := filepath.Rel("pkl", path)
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return fmt.Errorf("failed to get relative path for %s: %w", path, err)
+			return err
 		}
-		destPath := filepath.Join(targetDir, relPath)
-
-		// If it's a directory, create it
+		destPath := filepath.Join(targetDir, filepath.FromSlash(path))
 		if d.IsDir() {
 			return os.MkdirAll(destPath, 0755)
 		}
-
-		// Read the file from embedded FS
-		data, err := PKLFS.ReadFile(path)
+		data, err := fs.ReadFile(fsys, path)
 		if err != nil {
 			return fmt.Errorf("failed to read embedded file %s: %w", path, err)
 		}
-
-		// Apply conversion if it's a .pkl file
-		content := string(data)
-		if filepath.Ext(path) == ".pkl" {
-			content = ConvertPackageURLsToLocalPaths(content)
-			content = ConvertImportStatements(content)
+		if upToDate(path, destPath, data) {
+			return nil
 		}
-
-		// Write the file to the target directory
-		if err := os.WriteFile(destPath, []byte(content), 0644); err != nil {
+		if err := writeFileAtomic(destPath, data); err != nil {
 			return fmt.Errorf("failed to write file %s: %w", destPath, err)
 		}
-
 		return nil
 	})
-
-	if err != nil {
-		return fmt.Errorf("failed to write assets to directory with conversion: %w", err)
-	}
-
-	return nil
 }
 
-// GetPKLFileFromTempDir is a helper function that combines CopyAssetsToTempDir
-// and reading a specific file. It returns the file content and a cleanup function.
-// The caller should defer the cleanup function to remove the temp directory.
+// GetPKLFileFromTempDir returns the content of a file from the shared
+// extraction of the assets (see SharedAssetDir) together with the directory
+// path and a cleanup function. The caller should defer the cleanup function,
+// which releases the directory; it is removed once no caller holds it.
 func GetPKLFileFromTempDir(filename string) (content string, tempDir string, cleanup func(), err error) {
-	tempDir, err = CopyAssetsToTempDir()
-	if err != nil {
-		return "", "", nil, fmt.Errorf("failed to copy assets to temp dir: %w", err)
-	}
-
-	cleanup = func() {
-		os.RemoveAll(tempDir)
-	}
-
-	filePath := filepath.Join(tempDir, filename)
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		cleanup()
-		return "", "", nil, fmt.Errorf("failed to read file %s: %w", filename, err)
-	}
-
-	return string(data), tempDir, cleanup, nil
+	return readFromAssetDir(SharedAssetDir(), filename)
 }
 
 // GetPKLFileFromTempDirWithConversion is similar to GetPKLFileFromTempDir but
-// applies package URL conversion to all files in the temp directory.
+// uses the shared extraction with package URL conversion applied.
 func GetPKLFileFromTempDirWithConversion(filename string) (content string, tempDir string, cleanup func(), err error) {
-	tempDir, err = CopyAssetsToTempDirWithConversion()
+	return readFromAssetDir(SharedConvertedAssetDir(), filename)
+}
+
+func readFromAssetDir(a *AssetDir, filename string) (string, string, func(), error) {
+	tempDir, err := a.Acquire()
 	if err != nil {
-		return "", "", nil, fmt.Errorf("failed to copy assets to temp dir with conversion: %w", err)
+		return "", "", nil, fmt.Errorf("failed to copy assets to temp dir: %w", err)
 	}
 
-	cleanup = func() {
-		os.RemoveAll(tempDir)
+	var once sync.Once
+	cleanup := func() {
+		once.Do(func() { a.Release() })
 	}
 
 	filePath := filepath.Join(tempDir, filename)
@@ -0,0 +1,206 @@
+package pklsyntax
+
+import (
+	"sort"
+	"strings"
+)
+
+// ClauseKind identifies the keyword that references a module.
+type ClauseKind int
+
+const (
+	// Import is an `import "uri"` clause or `import("uri")` expression.
+	Import ClauseKind = iota
+	// ImportGlob is an `import* "glob"` clause or `import*("glob")` expression.
+	ImportGlob
+	// Amends is an `amends "uri"` clause.
+	Amends
+	// Extends is an `extends "uri"` clause.
+	Extends
+)
+
+func (k ClauseKind) String() string {
+	switch k {
+	case Import:
+		return "import"
+	case ImportGlob:
+		return "import*"
+	case Amends:
+		return "amends"
+	case Extends:
+		return "extends"
+	}
+	return "unknown"
+}
+
+// Clause is a reference to another module found in Pkl source.
+type Clause struct {
+	Kind ClauseKind
+	// URI is the decoded module URI or glob pattern.
+	URI string
+	// Alias is the name given with `as`, if any.
+	Alias string
+	// Expression reports the import("uri") expression form.
+	Expression bool
+	// Pos is the position of the keyword.
+	Pos Position
+	// Literal is the string literal holding the URI.
+	Literal Token
+}
+
+// Imports returns every import, import*, amends and extends clause in src,
+// including import expressions. URIs inside comments and ordinary string
+// literals are ignored. On a syntax error it returns the clauses found before
+// the error together with a *SyntaxError.
+func Imports(src string) ([]Clause, error) {
+	tokens, err := Tokenize(src)
+	return importsFromTokens(tokens), err
+}
+
+func importsFromTokens(tokens []Token) []Clause {
+	var clauses []Clause
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.Kind != Ident || tok.Quoted {
+			continue
+		}
+		// A keyword used as a member name (foo.import) is not a clause.
+		if i > 0 && tokens[i-1].Kind == Punct && tokens[i-1].Text == "." {
+			continue
+		}
+
+		var kind ClauseKind
+		j := i + 1
+		switch tok.Text {
+		case "import":
+			kind = Import
+			if j < len(tokens) && tokens[j].Text == "*" && tokens[j].Pos.Offset == tok.End {
+				kind = ImportGlob
+				j++
+			}
+		case "amends":
+			kind = Amends
+		case "extends":
+			kind = Extends
+		default:
+			continue
+		}
+
+		clause := Clause{Kind: kind, Pos: tok.Pos}
+		if (kind == Import || kind == ImportGlob) && j+2 < len(tokens) && tokens[j].Text == "(" && tokens[j+2].Text == ")" {
+			clause.Expression = true
+			j++
+		}
+		if j >= len(tokens) || tokens[j].Kind != String || !tokens[j].Constant || tokens[j].Multiline {
+			continue
+		}
+		clause.Literal = tokens[j]
+		clause.URI = tokens[j].Value
+		if clause.Expression {
+			j++
+		} else if j+2 < len(tokens) && tokens[j+1].Kind == Ident && tokens[j+1].Text == "as" && !tokens[j+1].Quoted && tokens[j+2].Kind == Ident {
+			clause.Alias = strings.Trim(tokens[j+2].Text, "`")
+			j += 2
+		}
+		clauses = append(clauses, clause)
+		i = j
+	}
+	return clauses
+}
+
+// Edit replaces the source between Start and End (byte offsets) with New.
+type Edit struct {
+	Start, End int
+	// Pos is the position of Start.
+	Pos Position
+	// Old is the replaced source text.
+	Old string
+	// New is the replacement text.
+	New string
+}
+
+// RewriteImports calls fn for every clause in src and replaces the URI of each
+// clause for which fn returns ok. The returned edits describe each
+// replacement in source order, with positions relative to the original src.
+// On a syntax error, clauses found before the error are still rewritten.
+func RewriteImports(src string, fn func(Clause) (uri string, ok bool)) (string, []Edit, error) {
+	clauses, err := Imports(src)
+	var edits []Edit
+	for _, clause := range clauses {
+		uri, ok := fn(clause)
+		if !ok || uri == clause.URI {
+			continue
+		}
+		lit := clause.Literal
+		edits = append(edits, Edit{
+			Start: lit.ContentStart,
+			End:   lit.ContentEnd,
+			Pos:   contentPos(lit),
+			Old:   src[lit.ContentStart:lit.ContentEnd],
+			New:   quoteContent(uri, lit.Pounds),
+		})
+	}
+	return ApplyEdits(src, edits), edits, err
+}
+
+// ApplyEdits applies non-overlapping edits to src.
+func ApplyEdits(src string, edits []Edit) string {
+	if len(edits) == 0 {
+		return src
+	}
+	sorted := append([]Edit(nil), edits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var b strings.Builder
+	last := 0
+	for _, e := range sorted {
+		b.WriteString(src[last:e.Start])
+		b.WriteString(e.New)
+		last = e.End
+	}
+	b.WriteString(src[last:])
+	return b.String()
+}
+
+// contentPos returns the position of the first content byte of a
+// single-line string literal.
+func contentPos(lit Token) Position {
+	delim := lit.ContentStart - lit.Pos.Offset
+	return Position{
+		Offset: lit.ContentStart,
+		Line:   lit.Pos.Line,
+		Column: lit.Pos.Column + delim,
+	}
+}
+
+// quoteContent escapes s for use as the content of a string literal with the
+// given number of # delimiters.
+func quoteContent(s string, pounds int) string {
+	escape := `\` + strings.Repeat("#", pounds)
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			if pounds == 0 {
+				b.WriteString(`\\`)
+				continue
+			}
+		case '"':
+			if pounds == 0 {
+				b.WriteString(`\"`)
+				continue
+			}
+		case '\n':
+			b.WriteString(escape + "n")
+			continue
+		case '\r':
+			b.WriteString(escape + "r")
+			continue
+		case '\t':
+			b.WriteString(escape + "t")
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
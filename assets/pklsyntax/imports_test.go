@@ -0,0 +1,131 @@
+package pklsyntax
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestImports(t *testing.T) {
+	src := `/// Doc comment mentioning import "package://example.com/doc@1.0.0#/Doc.pkl"
+amends "package://schema.kdeps.com/core@1.0.0#/Workflow.pkl"
+
+import "Project.pkl"
+import
+  "external/pkl-go/codegen/src/go.pkl"
+  as go
+import* "resources/*.pkl" as resources
+extends #"Base.pkl"#
+
+/* import "commented.pkl" */
+// import "line-commented.pkl"
+local notes = """
+  import "inside-triple-quotes.pkl"
+  """
+local greeting = "import \("nested.pkl") \(import("Interpolated.pkl").name)"
+local dynamic = import("Dynamic.pkl")
+local globbed = import*("dir/*.pkl")
+` + "local `import` = \"QuotedIdentifier.pkl\"" + `
+local member = foo.import
+`
+
+	clauses, err := Imports(src)
+	if err != nil {
+		t.Fatalf("Imports failed: %v", err)
+	}
+
+	expected := []Clause{
+		{Kind: Amends, URI: "package://schema.kdeps.com/core@1.0.0#/Workflow.pkl"},
+		{Kind: Import, URI: "Project.pkl"},
+		{Kind: Import, URI: "external/pkl-go/codegen/src/go.pkl", Alias: "go"},
+		{Kind: ImportGlob, URI: "resources/*.pkl", Alias: "resources"},
+		{Kind: Extends, URI: "Base.pkl"},
+		{Kind: Import, URI: "Dynamic.pkl", Expression: true},
+		{Kind: ImportGlob, URI: "dir/*.pkl", Expression: true},
+	}
+	if len(clauses) != len(expected) {
+		for _, c := range clauses {
+			t.Logf("found %s %q", c.Kind, c.URI)
+		}
+		t.Fatalf("Expected %d clauses, got %d", len(expected), len(clauses))
+	}
+	for i, want := range expected {
+		got := clauses[i]
+		if got.Kind != want.Kind || got.URI != want.URI || got.Alias != want.Alias || got.Expression != want.Expression {
+			t.Errorf("Clause %d: expected %s %q as %q (expr %v), got %s %q as %q (expr %v)",
+				i, want.Kind, want.URI, want.Alias, want.Expression, got.Kind, got.URI, got.Alias, got.Expression)
+		}
+	}
+
+	if pos := clauses[2].Pos; pos.Line != 5 || pos.Column != 1 {
+		t.Errorf("Expected multi-line import at 5:1, got %s", pos)
+	}
+	if pos := clauses[2].Literal.Pos; pos.Line != 6 || pos.Column != 3 {
+		t.Errorf("Expected import literal at 6:3, got %s", pos)
+	}
+}
+
+func TestImportsEscapes(t *testing.T) {
+	clauses, err := Imports(`import "dir\\file \"x\".pkl"` + "\n" + `import "\u{48}ello.pkl"`)
+	if err != nil {
+		t.Fatalf("Imports failed: %v", err)
+	}
+	if len(clauses) != 2 {
+		t.Fatalf("Expected 2 clauses, got %d", len(clauses))
+	}
+	if clauses[0].URI != `dir\file "x".pkl` {
+		t.Errorf("Unexpected decoded URI %q", clauses[0].URI)
+	}
+	if clauses[1].URI != "Hello.pkl" {
+		t.Errorf("Unexpected decoded URI %q", clauses[1].URI)
+	}
+}
+
+func TestImportsSyntaxError(t *testing.T) {
+	clauses, err := Imports("import \"First.pkl\"\nlocal broken = \"unterminated\n")
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("Expected SyntaxError, got %v", err)
+	}
+	if syntaxErr.Pos.Line != 2 {
+		t.Errorf("Expected error on line 2, got %s", syntaxErr.Pos)
+	}
+	if len(clauses) != 1 || clauses[0].URI != "First.pkl" {
+		t.Errorf("Expected clauses before the error to be returned, got %v", clauses)
+	}
+}
+
+func TestRewriteImports(t *testing.T) {
+	src := `import "a.pkl" // see "a.pkl"
+local text = "a.pkl"
+import #"a.pkl"#
+`
+	out, edits, err := RewriteImports(src, func(c Clause) (string, bool) {
+		if c.URI == "a.pkl" {
+			return `sub/"b".pkl`, true
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatalf("RewriteImports failed: %v", err)
+	}
+
+	want := `import "sub/\"b\".pkl" // see "a.pkl"
+local text = "a.pkl"
+import #"sub/"b".pkl"#
+`
+	if out != want {
+		t.Errorf("Expected:\n%s\nGot:\n%s", want, out)
+	}
+	if len(edits) != 2 {
+		t.Fatalf("Expected 2 edits, got %d", len(edits))
+	}
+	if edits[0].Pos.Line != 1 || edits[0].Pos.Column != 9 || edits[0].Old != "a.pkl" {
+		t.Errorf("Unexpected first edit: %+v", edits[0])
+	}
+	if edits[1].Pos.Line != 3 || edits[1].Pos.Column != 10 {
+		t.Errorf("Unexpected second edit position: %s", edits[1].Pos)
+	}
+	if ApplyEdits(src, edits) != out {
+		t.Error("ApplyEdits does not reproduce the rewritten source")
+	}
+}
@@ -0,0 +1,365 @@
+// Package pklsyntax is a small lexer for the Pkl language, sufficient to find
+// the module URIs referenced by import, import*, amends and extends clauses and
+// to rewrite them without touching comments or ordinary string content. Doc
+// comments are attached to the token that follows them.
+package pklsyntax
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenKind identifies the kind of a Token.
+type TokenKind int
+
+const (
+	// Ident is an identifier or keyword. Backtick-quoted identifiers are
+	// reported with Quoted set and are never treated as keywords.
+	Ident TokenKind = iota
+	// String is a string literal, including multi-line and custom-delimited strings.
+	String
+	// Punct is any other single character, such as "*", "(" or ".".
+	Punct
+)
+
+// Position is a location in Pkl source. Offset is a byte offset; Line and
+// Column are 1-based, with Column counted in runes.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Token is a lexical token. Comments and whitespace are not reported.
+type Token struct {
+	Kind TokenKind
+	// Text is the raw source text of the token.
+	Text string
+	// Doc is the text of the /// doc comment directly preceding the token,
+	// without the slashes and one following space, or empty.
+	Doc string
+	// Pos is the position of the first byte of the token.
+	Pos Position
+	// End is the byte offset just past the token.
+	End int
+
+	// Quoted reports a backtick-quoted identifier.
+	Quoted bool
+
+	// Value is the decoded content of a constant string literal.
+	Value string
+	// Constant reports a string literal without interpolation.
+	Constant bool
+	// Multiline reports a triple-quoted string literal.
+	Multiline bool
+	// ContentStart and ContentEnd delimit the raw content of a string literal,
+	// excluding its delimiters.
+	ContentStart, ContentEnd int
+	// Pounds is the number of # characters in a custom string delimiter.
+	Pounds int
+}
+
+// SyntaxError is returned for malformed source, such as an unterminated
+// string or block comment.
+type SyntaxError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Tokenize splits src into tokens. On a syntax error it returns the tokens
+// read so far together with a *SyntaxError.
+func Tokenize(src string) ([]Token, error) {
+	l := &lexer{src: src, line: 1, col: 1}
+	var tokens []Token
+	for {
+		tok, ok, err := l.next()
+		if err != nil {
+			return tokens, err
+		}
+		if !ok {
+			return tokens, nil
+		}
+		tok.Doc = l.tokenDoc
+		tokens = append(tokens, tok)
+	}
+}
+
+type lexer struct {
+	src  string
+	off  int
+	line int
+	col  int
+
+	// doc collects the lines of the current doc comment; tokenDoc is the doc
+	// comment of the last token returned by next.
+	doc      []string
+	tokenDoc string
+}
+
+func (l *lexer) pos() Position {
+	return Position{Offset: l.off, Line: l.line, Column: l.col}
+}
+
+func (l *lexer) errorf(p Position, format string, args ...any) error {
+	return &SyntaxError{Pos: p, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) peek(n int) byte {
+	if l.off+n < len(l.src) {
+		return l.src[l.off+n]
+	}
+	return 0
+}
+
+// advance moves past n bytes, keeping track of lines and columns.
+func (l *lexer) advance(n int) {
+	for n > 0 && l.off < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.off:])
+		l.off += size
+		n -= size
+		if r == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+	}
+}
+
+// next returns the next token, skipping whitespace and comments.
+func (l *lexer) next() (Token, bool, error) {
+	if err := l.skipTrivia(); err != nil {
+		return Token{}, false, err
+	}
+	l.tokenDoc = strings.Join(l.doc, "\n")
+	l.doc = nil
+	if l.off >= len(l.src) {
+		return Token{}, false, nil
+	}
+
+	start := l.pos()
+	c := l.src[l.off]
+	switch {
+	case c == '"' || (c == '#' && l.startsCustomString()):
+		return l.lexString(start)
+	case c == '`':
+		return l.lexQuotedIdent(start)
+	}
+
+	r, size := utf8.DecodeRuneInString(l.src[l.off:])
+	if isIdentStart(r) {
+		for l.off < len(l.src) {
+			r, size = utf8.DecodeRuneInString(l.src[l.off:])
+			if !isIdentPart(r) {
+				break
+			}
+			l.advance(size)
+		}
+		return Token{Kind: Ident, Text: l.src[start.Offset:l.off], Pos: start, End: l.off}, true, nil
+	}
+
+	l.advance(size)
+	return Token{Kind: Punct, Text: l.src[start.Offset:l.off], Pos: start, End: l.off}, true, nil
+}
+
+func (l *lexer) skipTrivia() error {
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			l.advance(1)
+		case c == '/' && l.peek(1) == '/' && l.peek(2) == '/' && l.peek(3) != '/':
+			start := l.off + 3
+			l.skipLine()
+			line := strings.TrimRight(l.src[start:l.off], "\r\n")
+			l.doc = append(l.doc, strings.TrimPrefix(line, " "))
+		case c == '/' && l.peek(1) == '/':
+			l.doc = nil
+			l.skipLine()
+		case c == '#' && l.peek(1) == '!' && l.off == 0:
+			l.skipLine()
+		case c == '/' && l.peek(1) == '*':
+			start := l.pos()
+			end := strings.Index(l.src[l.off+2:], "*/")
+			if end < 0 {
+				return l.errorf(start, "unterminated block comment")
+			}
+			l.doc = nil
+			l.advance(end + 4)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) skipLine() {
+	end := strings.IndexByte(l.src[l.off:], '\n')
+	if end < 0 {
+		l.advance(len(l.src) - l.off)
+		return
+	}
+	l.advance(end + 1)
+}
+
+func (l *lexer) startsCustomString() bool {
+	i := l.off
+	for i < len(l.src) && l.src[i] == '#' {
+		i++
+	}
+	return i < len(l.src) && l.src[i] == '"'
+}
+
+func (l *lexer) lexQuotedIdent(start Position) (Token, bool, error) {
+	end := strings.IndexAny(l.src[l.off+1:], "`\n")
+	if end < 0 || l.src[l.off+1+end] != '`' {
+		return Token{}, false, l.errorf(start, "unterminated quoted identifier")
+	}
+	l.advance(end + 2)
+	return Token{Kind: Ident, Text: l.src[start.Offset:l.off], Pos: start, End: l.off, Quoted: true}, true, nil
+}
+
+// lexString reads a string literal starting at the current offset. It handles
+// custom delimiters (#"..."#), multi-line strings and nested interpolation.
+func (l *lexer) lexString(start Position) (Token, bool, error) {
+	pounds := 0
+	for l.peek(0) == '#' {
+		pounds++
+		l.advance(1)
+	}
+	delim := strings.Repeat("#", pounds)
+	multiline := strings.HasPrefix(l.src[l.off:], `"""`)
+	quote := `"`
+	if multiline {
+		quote = `"""`
+	}
+	l.advance(len(quote))
+	closing := quote + delim
+	escape := `\` + delim
+
+	tok := Token{
+		Kind:         String,
+		Pos:          start,
+		Multiline:    multiline,
+		Pounds:       pounds,
+		Constant:     true,
+		ContentStart: l.off,
+	}
+	var value strings.Builder
+	for {
+		if l.off >= len(l.src) {
+			return Token{}, false, l.errorf(start, "unterminated string literal")
+		}
+		rest := l.src[l.off:]
+		switch {
+		case strings.HasPrefix(rest, closing):
+			tok.ContentEnd = l.off
+			l.advance(len(closing))
+			tok.End = l.off
+			tok.Text = l.src[start.Offset:l.off]
+			if tok.Constant {
+				tok.Value = value.String()
+			}
+			return tok, true, nil
+		case rest[0] == '\n' && !multiline:
+			return Token{}, false, l.errorf(start, "unterminated string literal")
+		case strings.HasPrefix(rest, escape) && len(rest) > len(escape):
+			escPos := l.pos()
+			l.advance(len(escape))
+			c := l.src[l.off]
+			switch c {
+			case '(':
+				tok.Constant = false
+				l.advance(1)
+				if err := l.skipInterpolation(escPos); err != nil {
+					return Token{}, false, err
+				}
+			case 'n':
+				value.WriteByte('\n')
+				l.advance(1)
+			case 'r':
+				value.WriteByte('\r')
+				l.advance(1)
+			case 't':
+				value.WriteByte('\t')
+				l.advance(1)
+			case '"', '\\':
+				value.WriteByte(c)
+				l.advance(1)
+			case 'u':
+				r, n, ok := decodeUnicodeEscape(l.src[l.off:])
+				if !ok {
+					return Token{}, false, l.errorf(escPos, "invalid unicode escape")
+				}
+				value.WriteRune(r)
+				l.advance(n)
+			default:
+				return Token{}, false, l.errorf(escPos, "invalid escape sequence %s%c", escape, c)
+			}
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			value.WriteString(rest[:size])
+			l.advance(size)
+		}
+	}
+}
+
+// skipInterpolation skips the expression of a string interpolation up to and
+// including its closing parenthesis.
+func (l *lexer) skipInterpolation(start Position) error {
+	depth := 1
+	for depth > 0 {
+		tok, ok, err := l.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return l.errorf(start, "unterminated string interpolation")
+		}
+		if tok.Kind != Punct {
+			continue
+		}
+		switch tok.Text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+	}
+	return nil
+}
+
+// decodeUnicodeEscape decodes the u{XXXX} part of a unicode escape.
+func decodeUnicodeEscape(s string) (rune, int, bool) {
+	if len(s) < 3 || s[1] != '{' {
+		return 0, 0, false
+	}
+	end := strings.IndexByte(s, '}')
+	if end < 3 {
+		return 0, 0, false
+	}
+	code, err := strconv.ParseUint(s[2:end], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, 0, false
+	}
+	return rune(code), end + 1, true
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r)
+}
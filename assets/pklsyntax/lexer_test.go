@@ -0,0 +1,44 @@
+package pklsyntax
+
+import "testing"
+
+func TestTokenDoc(t *testing.T) {
+	src := `/// Module doc.
+///
+///   Indented example.
+@ModuleInfo { minPklVersion = "0.30.2" }
+module example
+
+// An ordinary comment.
+undocumented: String
+
+/// Property doc.
+documented: Int = 1
+
+/// Dropped by the comment below.
+/* block */
+dropped: Boolean
+`
+	tokens, err := Tokenize(src)
+	if err != nil {
+		t.Fatalf("Tokenize failed: %v", err)
+	}
+
+	docs := make(map[string]string)
+	for _, tok := range tokens {
+		if tok.Kind != Punct {
+			docs[tok.Text] += tok.Doc
+		}
+	}
+	if want := "Module doc.\n\n  Indented example."; tokens[0].Text != "@" || tokens[0].Doc != want {
+		t.Errorf("Expected %q on the annotation, got %q on %q", want, tokens[0].Doc, tokens[0].Text)
+	}
+	if docs["documented"] != "Property doc." {
+		t.Errorf("Unexpected doc %q", docs["documented"])
+	}
+	for _, name := range []string{"module", "undocumented", "dropped"} {
+		if docs[name] != "" {
+			t.Errorf("Expected no doc on %s, got %q", name, docs[name])
+		}
+	}
+}
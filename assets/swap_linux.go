@@ -0,0 +1,25 @@
+//go:build linux
+
+package assets
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// exchangeDirs atomically swaps the directories at a and b with
+// renameat2(RENAME_EXCHANGE).
+func exchangeDirs(a, b string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.ENOSYS), errors.Is(err, unix.EINVAL):
+		// Kernels before 3.15, and file systems without exchange support.
+		return errExchangeUnsupported
+	default:
+		return &os.LinkError{Op: "renameat2", Old: a, New: b, Err: err}
+	}
+}
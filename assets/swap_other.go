@@ -0,0 +1,8 @@
+//go:build !linux
+
+package assets
+
+// exchangeDirs is only supported on Linux.
+func exchangeDirs(a, b string) error {
+	return errExchangeUnsupported
+}
@@ -0,0 +1,190 @@
+package assets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tempDirPrefix is the name prefix of every temporary assets directory.
+// Directories are named pkl-assets-<pid>-<random> so that SweepTempDirs can
+// tell whether their owner is still running.
+const tempDirPrefix = "pkl-assets-"
+
+// ErrNotAcquired is returned by AssetDir.Release when the directory is not held.
+var ErrNotAcquired = errors.New("assets directory released more times than acquired")
+
+// tempDirPattern returns the os.MkdirTemp pattern for a directory owned by
+// the current process.
+func tempDirPattern() string {
+	return fmt.Sprintf("%s%d-*", tempDirPrefix, os.Getpid())
+}
+
+// AssetDir is a reference-counted temporary directory holding the embedded
+// assets. It is extracted on the first Acquire and removed when the last
+// holder calls Release, so callers within a process share one copy instead of
+// extracting the tree each time. An AssetDir is safe for concurrent use.
+type AssetDir struct {
+	withConversion bool
+
+	mu   sync.Mutex
+	dir  string
+	refs int
+}
+
+// NewAssetDir returns an AssetDir. If withConversion is set, package URLs are
+// converted to local paths as in CopyAssetsToTempDirWithConversion.
+func NewAssetDir(withConversion bool) *AssetDir {
+	return &AssetDir{withConversion: withConversion}
+}
+
+var (
+	sharedAssetDir          = NewAssetDir(false)
+	sharedConvertedAssetDir = NewAssetDir(true)
+)
+
+// SharedAssetDir returns the process-wide AssetDir holding the assets as embedded.
+func SharedAssetDir() *AssetDir {
+	return sharedAssetDir
+}
+
+// SharedConvertedAssetDir returns the process-wide AssetDir holding the
+// assets with package URLs converted to local paths.
+func SharedConvertedAssetDir() *AssetDir {
+	return sharedConvertedAssetDir
+}
+
+// Acquire returns the path of the extracted assets, extracting them if no one
+// holds the directory yet. Every successful Acquire must be paired with a Release.
+func (a *AssetDir) Acquire() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.refs == 0 {
+		dir, err := a.extract()
+		if err != nil {
+			return "", err
+		}
+		a.dir = dir
+	}
+	a.refs++
+	return a.dir, nil
+}
+
+// Release drops a reference taken by Acquire and removes the directory when
+// the last reference is released.
+func (a *AssetDir) Release() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.refs == 0 {
+		return ErrNotAcquired
+	}
+	a.refs--
+	if a.refs > 0 {
+		return nil
+	}
+	dir := a.dir
+	a.dir = ""
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove assets directory %s: %w", dir, err)
+	}
+	return nil
+}
+
+// Refs returns the number of outstanding references.
+func (a *AssetDir) Refs() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.refs
+}
+
+func (a *AssetDir) extract() (string, error) {
+	var fsys fs.FS = NewConvertedFS()
+	if !a.withConversion {
+		root, err := fs.Sub(PKLFS, "pkl")
+		if err != nil {
+			return "", err
+		}
+		fsys = root
+	}
+
+	dir, err := os.MkdirTemp("", tempDirPattern())
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	if err := writeTree(fsys, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to copy assets: %w", err)
+	}
+	return dir, nil
+}
+
+// SweepTempDirs removes pkl-assets-* directories in os.TempDir() that are
+// older than maxAge and whose owning process no longer exists. Directories
+// created before owners were recorded in the name are removed on age alone.
+// It returns the removed directories.
+func SweepTempDirs(maxAge time.Duration) ([]string, error) {
+	entries, err := os.ReadDir(os.TempDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list temp directory: %w", err)
+	}
+
+	var removed []string
+	var errs []error
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), tempDirPrefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		if pid, ok := tempDirOwner(e.Name()); ok && (pid == os.Getpid() || processAlive(pid)) {
+			continue
+		}
+		path := filepath.Join(os.TempDir(), e.Name())
+		if err := os.RemoveAll(path); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %w", path, err))
+			continue
+		}
+		removed = append(removed, path)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// StartTempDirSweeper runs SweepTempDirs every interval until ctx is done.
+func StartTempDirSweeper(ctx context.Context, interval, maxAge time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			SweepTempDirs(maxAge)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// tempDirOwner returns the PID recorded in a pkl-assets-<pid>-<random> name.
+func tempDirOwner(name string) (int, bool) {
+	pid, random, ok := strings.Cut(strings.TrimPrefix(name, tempDirPrefix), "-")
+	if !ok || random == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(pid)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
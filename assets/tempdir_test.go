@@ -0,0 +1,143 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssetDirAcquireRelease(t *testing.T) {
+	a := NewAssetDir(false)
+
+	dir1, err := a.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	dir2, err := a.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if dir1 != dir2 {
+		t.Errorf("Holders got different directories: %s and %s", dir1, dir2)
+	}
+	if !strings.HasPrefix(filepath.Base(dir1), fmt.Sprintf("pkl-assets-%d-", os.Getpid())) {
+		t.Errorf("Directory name %s does not record the owner PID", dir1)
+	}
+	if a.Refs() != 2 {
+		t.Errorf("Expected 2 references, got %d", a.Refs())
+	}
+
+	if err := a.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir1, "Workflow.pkl")); err != nil {
+		t.Errorf("Directory removed while still held: %v", err)
+	}
+
+	if err := a.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := os.Stat(dir1); !os.IsNotExist(err) {
+		t.Error("Directory was not removed on the last release")
+	}
+	if err := a.Release(); !errors.Is(err, ErrNotAcquired) {
+		t.Errorf("Expected ErrNotAcquired, got %v", err)
+	}
+
+	dir3, err := a.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire after release failed: %v", err)
+	}
+	defer a.Release()
+	if dir3 == dir1 {
+		t.Error("Expected a fresh directory after the last release")
+	}
+}
+
+func TestAssetDirWithConversion(t *testing.T) {
+	a := NewAssetDir(true)
+	dir, err := a.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer a.Release()
+
+	data, err := os.ReadFile(filepath.Join(dir, "Workflow.pkl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid, remaining := ValidateLocalPaths(string(data)); !valid {
+		t.Errorf("Workflow.pkl still contains package URLs: %v", remaining)
+	}
+}
+
+func TestGetPKLFileFromTempDirSharesExtraction(t *testing.T) {
+	_, dir1, cleanup1, err := GetPKLFileFromTempDir("Tool.pkl")
+	if err != nil {
+		t.Fatalf("GetPKLFileFromTempDir failed: %v", err)
+	}
+	_, dir2, cleanup2, err := GetPKLFileFromTempDir("Workflow.pkl")
+	if err != nil {
+		cleanup1()
+		t.Fatalf("GetPKLFileFromTempDir failed: %v", err)
+	}
+	if dir1 != dir2 {
+		t.Errorf("Expected a shared directory, got %s and %s", dir1, dir2)
+	}
+
+	cleanup1()
+	cleanup1()
+	if _, err := os.Stat(dir2); err != nil {
+		t.Errorf("Repeated cleanup released another holder's reference: %v", err)
+	}
+	cleanup2()
+	if _, err := os.Stat(dir2); !os.IsNotExist(err) {
+		t.Error("Shared directory was not removed after the last cleanup")
+	}
+}
+
+func TestSweepTempDirs(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	const deadPID = 2147483646
+	old := time.Now().Add(-2 * time.Hour)
+	mkdir := func(name string, mtime time.Time) string {
+		path := filepath.Join(tmp, name)
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	deadOld := mkdir(fmt.Sprintf("pkl-assets-%d-1", deadPID), old)
+	legacyOld := mkdir("pkl-assets-123456", old)
+	deadNew := mkdir(fmt.Sprintf("pkl-assets-%d-2", deadPID), time.Now())
+	ownOld := mkdir(fmt.Sprintf("pkl-assets-%d-3", os.Getpid()), old)
+	unrelated := mkdir("other-dir", old)
+
+	removed, err := SweepTempDirs(time.Hour)
+	if err != nil {
+		t.Fatalf("SweepTempDirs failed: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Expected 2 removed directories, got %v", removed)
+	}
+	for _, path := range []string{deadOld, legacyOld} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not swept", filepath.Base(path))
+		}
+	}
+	for _, path := range []string{deadNew, ownOld, unrelated} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was swept: %v", filepath.Base(path), err)
+		}
+	}
+}
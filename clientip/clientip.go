@@ -0,0 +1,233 @@
+// Package clientip resolves the address of the client of an HTTP request
+// behind reverse proxies, following the TrustedProxies of the API and web
+// server settings.
+//
+// The forwarding headers are read from right to left: each hop is appended by
+// the proxy that received the request from it, so an entry is only as
+// trustworthy as the proxy to its right. Starting from the peer address of
+// the connection, the resolver steps left while the current hop is a trusted
+// proxy, and returns the first hop that is not.
+//
+//	r, err := clientip.FromAPIServer(settings)
+//	ip := r.ClientIP(req)
+package clientip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/web_server"
+)
+
+// Resolver returns the client address of requests. The zero value, like a
+// Resolver built from unset TrustedProxies, trusts every proxy.
+type Resolver struct {
+	// trusted is nil when every proxy is trusted.
+	trusted []netip.Prefix
+}
+
+// New returns a Resolver trusting the proxies, given as IPv4 or IPv6
+// addresses or CIDR ranges. A nil proxies trusts every proxy, as unset
+// TrustedProxies do; an empty list trusts none.
+func New(proxies *[]string) (*Resolver, error) {
+	if proxies == nil {
+		return &Resolver{}, nil
+	}
+	r := &Resolver{trusted: make([]netip.Prefix, 0, len(*proxies))}
+	var errs []error
+	for _, p := range *proxies {
+		prefix, err := ParseProxy(p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.trusted = append(r.trusted, prefix)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to parse TrustedProxies: %w", errors.Join(errs...))
+	}
+	return r, nil
+}
+
+// FromAPIServer returns the Resolver of the TrustedProxies of s.
+func FromAPIServer(s apiserver.APIServerSettings) (*Resolver, error) {
+	return New(s.TrustedProxies)
+}
+
+// FromWebServer returns the Resolver of the TrustedProxies of s.
+func FromWebServer(s webserver.WebServerSettings) (*Resolver, error) {
+	return New(s.TrustedProxies)
+}
+
+// ParseProxy parses an entry of TrustedProxies: an IPv4 or IPv6 address, or
+// a CIDR range. An address is returned as a single-address prefix.
+func ParseProxy(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		if prefix.Addr().Zone() != "" {
+			return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: zones are not supported", s)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+	}
+	if addr.Zone() != "" {
+		return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: zones are not supported", s)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Trusted reports whether addr is a trusted proxy.
+func (r *Resolver) Trusted(addr netip.Addr) bool {
+	if r == nil || r.trusted == nil {
+		return true
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, p := range r.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client of req.
+//
+// The hops are read from the Forwarded header if req has one, and from
+// X-Forwarded-For otherwise. When every proxy is trusted, the leftmost hop
+// that is an address is the client. Otherwise hops are walked from the peer
+// address of req leftwards while the current hop is trusted; a hop that is
+// not an address, such as "unknown" or an obfuscated identifier, ends the
+// walk at the proxy that reported it.
+//
+// If RemoteAddr is not an address, it is returned unchanged.
+func (r *Resolver) ClientIP(req *http.Request) string {
+	host := req.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	remote = remote.Unmap()
+
+	hops := forwardedHops(req.Header)
+	if r == nil || r.trusted == nil {
+		for _, hop := range hops {
+			if addr, ok := parseHop(hop); ok {
+				return addr.String()
+			}
+		}
+		return remote.String()
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0 && r.Trusted(client); i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+	}
+	return client.String()
+}
+
+// forwardedHops returns the hops listed by the Forwarded header of h, or by
+// X-Forwarded-For if h has no Forwarded header, from the client to the last
+// proxy.
+func forwardedHops(h http.Header) []string {
+	var hops []string
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		for _, value := range values {
+			for _, element := range splitQuoted(value, ',') {
+				hops = append(hops, forwardedFor(element))
+			}
+		}
+		return hops
+	}
+	for _, value := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// forwardedFor returns the for parameter of a Forwarded element, unquoted, or
+// "" if the element has none.
+func forwardedFor(element string) string {
+	for _, pair := range splitQuoted(element, ';') {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "for") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = strings.ReplaceAll(value[1:len(value)-1], `\`, "")
+		}
+		return value
+	}
+	return ""
+}
+
+// splitQuoted splits s at sep outside of quoted strings, trimming spaces and
+// dropping empty parts.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped, start := false, false, 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			c := s[i]
+			switch {
+			case escaped:
+				escaped = false
+				continue
+			case quoted && c == '\\':
+				escaped = true
+				continue
+			case c == '"':
+				quoted = !quoted
+				continue
+			case quoted || c != sep:
+				continue
+			}
+		}
+		if part := strings.TrimSpace(s[start:i]); part != "" {
+			parts = append(parts, part)
+		}
+		start = i + 1
+	}
+	return parts
+}
+
+// parseHop parses a hop of X-Forwarded-For or of a Forwarded for parameter:
+// an address, optionally with a port, and with IPv6 addresses optionally in
+// brackets.
+func parseHop(hop string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(hop); err == nil {
+		return addr.Unmap().WithZone(""), true
+	}
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap().WithZone(""), true
+	}
+	if strings.HasPrefix(hop, "[") && strings.HasSuffix(hop, "]") {
+		if addr, err := netip.ParseAddr(hop[1 : len(hop)-1]); err == nil {
+			return addr.Unmap().WithZone(""), true
+		}
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,95 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/web_server"
+)
+
+func list(s ...string) *[]string {
+	return &s
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(list("10.0.0.1", "10.0.0.0/8", "::1", "2001:db8::/32", "::ffff:10.1.2.3")); err != nil {
+		t.Errorf("Failed to parse valid proxies: %v", err)
+	}
+	_, err := New(list("10.0.0.1", "10.0.0.0/33", "proxy.local", "fe80::1%eth0"))
+	if err == nil {
+		t.Fatal("Expected an error for invalid proxies")
+	}
+	for _, bad := range []string{"10.0.0.0/33", "proxy.local", "fe80::1%eth0"} {
+		if !strings.Contains(err.Error(), bad) {
+			t.Errorf("Expected the error to name %q: %v", bad, err)
+		}
+	}
+
+	if _, err := FromAPIServer(apiserver.APIServerSettings{TrustedProxies: list("bad")}); err == nil {
+		t.Error("Expected an error for APIServerSettings")
+	}
+	if _, err := FromWebServer(webserver.WebServerSettings{TrustedProxies: list("bad")}); err == nil {
+		t.Error("Expected an error for WebServerSettings")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		header  []string
+		trusted *[]string
+		want    string
+	}{
+		{"direct", "203.0.113.7:1234", nil, list("10.0.0.0/8"), "203.0.113.7"},
+		{"unset trusts all", "10.0.0.1:1234", []string{"X-Forwarded-For", "198.51.100.1, 10.0.0.2"}, nil, "198.51.100.1"},
+		{"unset skips invalid", "10.0.0.1:1234", []string{"X-Forwarded-For", "unknown, 198.51.100.1"}, nil, "198.51.100.1"},
+		{"none trusted", "10.0.0.1:1234", []string{"X-Forwarded-For", "198.51.100.1"}, list(), "10.0.0.1"},
+		{"untrusted remote", "203.0.113.7:1234", []string{"X-Forwarded-For", "198.51.100.1"}, list("10.0.0.0/8"), "203.0.113.7"},
+		{"spoofed entry", "10.0.0.1:1234", []string{"X-Forwarded-For", "6.6.6.6, 198.51.100.1", "X-Forwarded-For", "10.0.0.2"}, list("10.0.0.0/8"), "198.51.100.1"},
+		{"single address", "[::1]:1234", []string{"X-Forwarded-For", "2001:db8::7"}, list("::1"), "2001:db8::7"},
+		{"mapped address", "[::ffff:10.0.0.1]:1234", []string{"X-Forwarded-For", "198.51.100.1"}, list("10.0.0.1"), "198.51.100.1"},
+		{"all trusted", "10.0.0.1:1234", []string{"X-Forwarded-For", "10.0.0.3, 10.0.0.2"}, list("10.0.0.0/8"), "10.0.0.3"},
+		{"invalid hop", "10.0.0.1:1234", []string{"X-Forwarded-For", "198.51.100.1, garbage"}, list("10.0.0.0/8"), "10.0.0.1"},
+		{"hop with port", "10.0.0.1:1234", []string{"X-Forwarded-For", "198.51.100.1:4711"}, list("10.0.0.0/8"), "198.51.100.1"},
+		{"forwarded", "10.0.0.1:1234", []string{
+			"Forwarded", `for=6.6.6.6, for="[2001:db8::7]:4711";proto=https`,
+			"Forwarded", "for=10.0.0.2;by=10.0.0.1",
+			"X-Forwarded-For", "198.51.100.1",
+		}, list("10.0.0.0/8"), "2001:db8::7"},
+		{"forwarded quoted separator", "10.0.0.1:1234", []string{"Forwarded", `for=198.51.100.1;host="a,b";proto=https`}, list("10.0.0.0/8"), "198.51.100.1"},
+		{"forwarded obfuscated", "10.0.0.1:1234", []string{"Forwarded", "for=198.51.100.1, for=_hidden"}, list("10.0.0.0/8"), "10.0.0.1"},
+		{"forwarded unset", "10.0.0.1:1234", []string{"Forwarded", "for=unknown, for=198.51.100.1"}, nil, "198.51.100.1"},
+		{"remote not an address", "pipe", []string{"X-Forwarded-For", "198.51.100.1"}, nil, "pipe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.trusted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for i := 0; i+1 < len(tt.header); i += 2 {
+				req.Header.Add(tt.header[i], tt.header[i+1])
+			}
+			if got := r.ClientIP(req); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZeroResolver(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	var nilResolver *Resolver
+	for _, r := range []*Resolver{nilResolver, {}} {
+		if got := r.ClientIP(req); got != "198.51.100.1" {
+			t.Errorf("ClientIP() = %q", got)
+		}
+	}
+}
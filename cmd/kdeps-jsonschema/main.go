@@ -0,0 +1,95 @@
+// Command kdeps-jsonschema writes JSON Schema documents for the kdeps Pkl
+// modules.
+//
+// Usage:
+//
+//	kdeps-jsonschema [-o dir] [name ...]
+//
+// With -o, a <name>.schema.json file is written to dir for each named
+// document, or for every document if none is named. Without -o, the single
+// named document is written to standard output. -list prints the document
+// names.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kdeps/schema/jsonschema"
+)
+
+func main() {
+	out := flag.String("o", "", "write <name>.schema.json files to this directory")
+	list := flag.Bool("list", false, "list the document names and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-o dir] [name ...]\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*out, *list, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(out string, list bool, names []string) error {
+	if list {
+		for _, d := range jsonschema.Documents() {
+			fmt.Println(d.Name)
+		}
+		return nil
+	}
+	if len(names) == 0 {
+		if out == "" {
+			return fmt.Errorf("name a document or use -o; see -list")
+		}
+		for _, d := range jsonschema.Documents() {
+			names = append(names, d.Name)
+		}
+	}
+	if out == "" && len(names) > 1 {
+		return fmt.Errorf("use -o to write more than one document")
+	}
+
+	for _, name := range names {
+		s, ok, err := jsonschema.GenerateDocument(name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("unknown document %q; see -list", name)
+		}
+		data, err := encode(s)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		if out == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", out, err)
+		}
+		path := filepath.Join(out, name+".schema.json")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func encode(s *jsonschema.Schema) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,566 @@
+// Package codec encodes the generated kdeps types as JSON and YAML, and
+// decodes them back.
+//
+// The generated structs only carry pkl tags, so encoding/json would use the
+// Go field names and write pkl.Duration as an opaque struct. This package
+// walks the values itself:
+//
+//   - properties are named as in Pkl (the pkl tags) and appear in
+//     declaration order; null properties are omitted
+//   - mapping entries are sorted by key
+//   - pkl.Duration and pkl.DataSize are strings in Pkl syntax, such as
+//     "60.s" and "1.5.gb"
+//   - enums such as gpu.GPU are strings, and decoding an unknown value fails
+//     with the error of the generated UnmarshalBinary method
+//   - a pkl.Object, such as ResourceAction.Expr, is an array of its elements
+//     or an object of its properties and entries
+//
+// Decoding rejects unknown properties. It does not fill in Pkl defaults; use
+// defaults.ApplyDefaults for that.
+//
+// There is no YAML library to depend on, so YAML is read and written by this
+// package and only a subset is supported. UnmarshalYAML reads block mappings
+// and sequences, plain, single- and double-quoted scalars, literal block
+// scalars (|) and single-line flow collections. It rejects folded block
+// scalars (>), quoted scalars spanning several lines, anchors, aliases,
+// tags, directives, complex keys (?) and plain scalars starting with the
+// reserved @ or `, and does not support multiple documents. MarshalYAML only
+// writes that subset, and quotes strings that YAML 1.1 parsers would read as
+// something else, such as yes, off, ~ or 1:20.
+//
+//	data, err := codec.MarshalJSON(wf)
+//	var wf workflow.WorkflowImpl
+//	err = codec.UnmarshalYAML(data, &wf)
+package codec
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+var (
+	durationType = reflect.TypeOf(pkl.Duration{})
+	dataSizeType = reflect.TypeOf(pkl.DataSize{})
+	objectType   = reflect.TypeOf(pkl.Object{})
+
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// object is an encoded struct or mapping, with its keys in order.
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+func newObject() *object {
+	return &object{values: make(map[string]any)}
+}
+
+func (o *object) set(key string, value any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// FormatDuration returns d in Pkl syntax, such as "60.s" or "1.5.min".
+func FormatDuration(d pkl.Duration) string {
+	return strconv.FormatFloat(d.Value, 'f', -1, 64) + "." + d.Unit.String()
+}
+
+// ParseDuration parses a duration in Pkl syntax, such as "60.s".
+func ParseDuration(s string) (pkl.Duration, error) {
+	value, unit, err := splitUnit(s)
+	if err != nil {
+		return pkl.Duration{}, fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	u, err := pkl.ToDurationUnit(unit)
+	if err != nil {
+		return pkl.Duration{}, fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	return pkl.Duration{Value: value, Unit: u}, nil
+}
+
+// FormatDataSize returns d in Pkl syntax, such as "512.mb".
+func FormatDataSize(d pkl.DataSize) string {
+	return strconv.FormatFloat(d.Value, 'f', -1, 64) + "." + d.Unit.String()
+}
+
+// ParseDataSize parses a data size in Pkl syntax, such as "512.mb".
+func ParseDataSize(s string) (pkl.DataSize, error) {
+	value, unit, err := splitUnit(s)
+	if err != nil {
+		return pkl.DataSize{}, fmt.Errorf("invalid data size %q: %w", s, err)
+	}
+	u, err := pkl.ToDataSizeUnit(unit)
+	if err != nil {
+		return pkl.DataSize{}, fmt.Errorf("invalid data size %q: %w", s, err)
+	}
+	return pkl.DataSize{Value: value, Unit: u}, nil
+}
+
+func splitUnit(s string) (float64, string, error) {
+	i := strings.LastIndexByte(s, '.')
+	if i <= 0 {
+		return 0, "", errors.New("missing unit")
+	}
+	value, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, "", fmt.Errorf("invalid value %q", s[:i])
+	}
+	return value, s[i+1:], nil
+}
+
+// encode returns the tree of v: an *object, []any, string, bool, int64,
+// uint64, float64 or nil.
+func encode(v reflect.Value) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch t := v.Type(); t {
+	case durationType:
+		return FormatDuration(v.Interface().(pkl.Duration)), nil
+	case dataSizeType:
+		return FormatDataSize(v.Interface().(pkl.DataSize)), nil
+	case objectType:
+		return encodeObject(v.Interface().(pkl.Object))
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return encode(v.Elem())
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("unsupported float value %v", f)
+		}
+		return f, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []any{}, nil
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			e, err := encode(v.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			list[i] = e
+		}
+		return list, nil
+	case reflect.Map:
+		o := newObject()
+		for _, key := range sortedKeys(v) {
+			name := fmt.Sprint(key.Interface())
+			e, err := encode(v.MapIndex(key))
+			if err != nil {
+				return nil, fmt.Errorf("[%q]: %w", name, err)
+			}
+			o.set(name, e)
+		}
+		return o, nil
+	case reflect.Struct:
+		o := newObject()
+		if err := encodeFields(o, v); err != nil {
+			return nil, err
+		}
+		return o, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func encodeFields(o *object, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("pkl")
+		if name == "" && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := encodeFields(o, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		e, err := encode(v.Field(i))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if e != nil {
+			o.set(name, e)
+		}
+	}
+	return nil
+}
+
+func encodeObject(obj pkl.Object) (any, error) {
+	if len(obj.Properties) == 0 && len(obj.Entries) == 0 {
+		return encode(reflect.ValueOf(obj.Elements))
+	}
+	if len(obj.Elements) > 0 {
+		return nil, errors.New("cannot encode an object with both members and elements")
+	}
+	o := newObject()
+	names := make([]string, 0, len(obj.Properties))
+	for name := range obj.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e, err := encode(reflect.ValueOf(obj.Properties[name]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		o.set(name, e)
+	}
+	entries := reflect.ValueOf(obj.Entries)
+	for _, key := range sortedKeys(entries) {
+		name := fmt.Sprint(key.Interface())
+		e, err := encode(entries.MapIndex(key))
+		if err != nil {
+			return nil, fmt.Errorf("[%q]: %w", name, err)
+		}
+		o.set(name, e)
+	}
+	return o, nil
+}
+
+func sortedKeys(m reflect.Value) []reflect.Value {
+	if !m.IsValid() || m.Len() == 0 {
+		return nil
+	}
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+// plain is an unquoted YAML scalar, whose type depends on what it is
+// decoded into.
+type plain string
+
+// decode stores the tree node into v, which must be settable. Objects are
+// map[string]any, numbers json.Number and YAML plain scalars plain.
+func decode(node any, v reflect.Value) error {
+	if p, ok := node.(plain); ok && p.isNull() {
+		node = nil
+	}
+	if node == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	t := v.Type()
+	switch t {
+	case durationType:
+		s, err := scalarString(node, t)
+		if err != nil {
+			return err
+		}
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(d))
+		return nil
+	case dataSizeType:
+		s, err := scalarString(node, t)
+		if err != nil {
+			return err
+		}
+		d, err := ParseDataSize(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(d))
+		return nil
+	case objectType:
+		return decodeObject(node, v)
+	}
+
+	if t.Kind() == reflect.String && reflect.PointerTo(t).Implements(binaryUnmarshalerType) {
+		s, err := scalarString(node, t)
+		if err != nil {
+			return err
+		}
+		// The generated enums validate their values.
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary([]byte(s))
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		e := reflect.New(t.Elem())
+		if err := decode(node, e.Elem()); err != nil {
+			return err
+		}
+		v.Set(e)
+		return nil
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return fmt.Errorf("unsupported type %s", t)
+		}
+		generic, err := decodeAny(node)
+		if err != nil {
+			return err
+		}
+		if generic != nil {
+			v.Set(reflect.ValueOf(generic))
+		}
+		return nil
+	case reflect.String:
+		s, err := scalarString(node, t)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Bool:
+		b, err := scalarBool(node)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s, err := scalarNumber(node, t)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return fmt.Errorf("cannot decode %s into %s", s, t)
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s, err := scalarNumber(node, t)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return fmt.Errorf("cannot decode %s into %s", s, t)
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		s, err := scalarNumber(node, t)
+		if err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return fmt.Errorf("cannot decode %s into %s", s, t)
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Slice:
+		list, ok := node.([]any)
+		if !ok {
+			return fmt.Errorf("cannot decode %s into %s", describe(node), t)
+		}
+		s := reflect.MakeSlice(t, len(list), len(list))
+		for i, e := range list {
+			if err := decode(e, s.Index(i)); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Map:
+		m, ok := node.(map[string]any)
+		if !ok {
+			return fmt.Errorf("cannot decode %s into %s", describe(node), t)
+		}
+		if t.Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		out := reflect.MakeMapWithSize(t, len(m))
+		for key, e := range m {
+			ev := reflect.New(t.Elem()).Elem()
+			if err := decode(e, ev); err != nil {
+				return fmt.Errorf("[%q]: %w", key, err)
+			}
+			out.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), ev)
+		}
+		v.Set(out)
+		return nil
+	case reflect.Struct:
+		m, ok := node.(map[string]any)
+		if !ok {
+			return fmt.Errorf("cannot decode %s into %s", describe(node), t)
+		}
+		seen := make(map[string]bool, len(m))
+		if err := decodeFields(m, v, seen); err != nil {
+			return err
+		}
+		for key := range m {
+			if !seen[key] {
+				return fmt.Errorf("unknown property %s of %s", key, t)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", t)
+}
+
+func decodeFields(m map[string]any, v reflect.Value, seen map[string]bool) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("pkl")
+		if name == "" && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := decodeFields(m, v.Field(i), seen); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		node, ok := m[name]
+		if !ok {
+			continue
+		}
+		seen[name] = true
+		if err := decode(node, v.Field(i)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func decodeObject(node any, v reflect.Value) error {
+	var obj pkl.Object
+	switch n := node.(type) {
+	case []any:
+		for _, e := range n {
+			generic, err := decodeAny(e)
+			if err != nil {
+				return err
+			}
+			obj.Elements = append(obj.Elements, generic)
+		}
+	case map[string]any:
+		obj.Properties = make(map[string]any, len(n))
+		for key, e := range n {
+			generic, err := decodeAny(e)
+			if err != nil {
+				return err
+			}
+			obj.Properties[key] = generic
+		}
+	default:
+		return fmt.Errorf("cannot decode %s into %s", describe(node), v.Type())
+	}
+	v.Set(reflect.ValueOf(obj))
+	return nil
+}
+
+// decodeAny returns node as the values encoding/json produces for an any,
+// except that integers are int64.
+func decodeAny(node any) (any, error) {
+	switch n := node.(type) {
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		return n.Float64()
+	case plain:
+		return n.resolve(), nil
+	case []any:
+		list := make([]any, len(n))
+		for i, e := range n {
+			generic, err := decodeAny(e)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = generic
+		}
+		return list, nil
+	case map[string]any:
+		m := make(map[string]any, len(n))
+		for key, e := range n {
+			generic, err := decodeAny(e)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = generic
+		}
+		return m, nil
+	}
+	return node, nil
+}
+
+func scalarString(node any, t reflect.Type) (string, error) {
+	switch n := node.(type) {
+	case string:
+		return n, nil
+	case plain:
+		return string(n), nil
+	}
+	return "", fmt.Errorf("cannot decode %s into %s", describe(node), t)
+}
+
+func scalarBool(node any) (bool, error) {
+	switch n := node.(type) {
+	case bool:
+		return n, nil
+	case plain:
+		if b, ok := n.resolve().(bool); ok {
+			return b, nil
+		}
+	}
+	return false, fmt.Errorf("cannot decode %s into bool", describe(node))
+}
+
+func scalarNumber(node any, t reflect.Type) (string, error) {
+	switch n := node.(type) {
+	case json.Number:
+		return n.String(), nil
+	case plain:
+		switch r := n.resolve().(type) {
+		case int64:
+			return strconv.FormatInt(r, 10), nil
+		case float64:
+			return strconv.FormatFloat(r, 'g', -1, 64), nil
+		}
+	}
+	return "", fmt.Errorf("cannot decode %s into %s", describe(node), t)
+}
+
+func describe(node any) string {
+	switch n := node.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return strconv.Quote(n)
+	case plain:
+		return string(n)
+	}
+	return fmt.Sprint(node)
+}
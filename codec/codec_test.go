@@ -0,0 +1,251 @@
+package codec
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/defaults"
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/exec"
+	"github.com/kdeps/schema/gen/kdeps"
+	"github.com/kdeps/schema/gen/kdeps/gpu"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/web_server"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+func testWorkflow() workflow.WorkflowImpl {
+	wf := defaults.NewWorkflow()
+	wf.AgentID = "demo"
+	wf.Description = "A demo: with \"quotes\", # and\nnewlines"
+	wf.Authors = &[]string{"Alice", "-dash", "true", "1.5", ""}
+	wf.TargetActionID = "respond"
+	wf.Workflows = []string{}
+	wf.Settings.APIServerMode = true
+	api := defaults.NewAPIServerSettings()
+	api.Routes = []apiserver.APIServerRoutes{
+		{Path: "/api/v1/whois", Methods: []string{"GET", "POST"}},
+		{Path: "/", Methods: []string{}},
+	}
+	api.CORS.AllowOrigins = &[]string{"https://kdeps.com"}
+	wf.Settings.APIServer = &api
+	web := defaults.NewWebServerSettings()
+	web.Routes = []webserver.WebServerRoutes{defaults.NewWebServerRoutes()}
+	wf.Settings.WebServer = &web
+	wf.Settings.AgentSettings.Models = []string{"llama3.2"}
+	wf.Settings.AgentSettings.CondaPackages = &map[string]map[string]string{"base": {"main": "diffuser"}}
+	wf.Settings.AgentSettings.Env = &map[string]string{}
+	return wf
+}
+
+func testResource() resource.Resource {
+	run := defaults.NewResourceExec()
+	run.Command = "echo hi"
+	run.Env = &map[string]string{"B": "2", "A": "1"}
+	return resource.Resource{
+		ActionID: "exec",
+		Run: resource.ResourceAction{
+			Expr:          &pkl.Object{Elements: []any{"@(memory.setRecord(\"a\", \"b\"))", int64(3)}},
+			Exec:          &run,
+			SkipCondition: &[]any{false, map[string]any{"n": 1.5, "list": []any{"x"}}},
+		},
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, want := range []any{testWorkflow(), testResource(), defaults.NewKdeps()} {
+		data, err := MarshalJSON(want)
+		if err != nil {
+			t.Fatalf("MarshalJSON failed: %v", err)
+		}
+		got := reflect.New(reflect.TypeOf(want))
+		if err := UnmarshalJSON(data, got.Interface()); err != nil {
+			t.Fatalf("UnmarshalJSON failed: %v\n%s", err, data)
+		}
+		if !reflect.DeepEqual(got.Elem().Interface(), want) {
+			t.Errorf("JSON round trip changed the value:\n%s\n got %+v\nwant %+v", data, got.Elem(), want)
+		}
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	for _, want := range []any{testWorkflow(), testResource(), defaults.NewKdeps()} {
+		data, err := MarshalYAML(want)
+		if err != nil {
+			t.Fatalf("MarshalYAML failed: %v", err)
+		}
+		got := reflect.New(reflect.TypeOf(want))
+		if err := UnmarshalYAML(data, got.Interface()); err != nil {
+			t.Fatalf("UnmarshalYAML failed: %v\n%s", err, data)
+		}
+		if !reflect.DeepEqual(got.Elem().Interface(), want) {
+			t.Errorf("YAML round trip changed the value:\n%s\n got %+v\nwant %+v", data, got.Elem(), want)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	data, err := MarshalJSON(defaults.NewCORSConfig())
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if want := `{"EnableCORS":false,"AllowCredentials":true,"MaxAge":"12.h"}`; string(data) != want {
+		t.Errorf("MarshalJSON = %s, want %s", data, want)
+	}
+
+	data, err = MarshalJSON(testResource())
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"Expr":["@(memory.setRecord(\"a\", \"b\"))",3]`) ||
+		!strings.Contains(string(data), `"Env":{"A":"1","B":"2"}`) ||
+		!strings.Contains(string(data), `"TimeoutDuration":"60.s"`) {
+		t.Errorf("Unexpected encoding %s", data)
+	}
+}
+
+func TestMarshalYAML(t *testing.T) {
+	api := defaults.NewAPIServerSettings()
+	api.Routes = []apiserver.APIServerRoutes{{Path: "/api", Methods: []string{"GET"}}}
+	data, err := MarshalYAML(api)
+	if err != nil {
+		t.Fatalf("MarshalYAML failed: %v", err)
+	}
+	want := `HostIP: 127.0.0.1
+PortNum: 3000
+Routes:
+  - Path: /api
+    Methods:
+      - GET
+CORS:
+  EnableCORS: false
+  AllowCredentials: true
+  MaxAge: 12.h
+`
+	if string(data) != want {
+		t.Errorf("MarshalYAML =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestMarshalYAMLQuotesYAML11Forms(t *testing.T) {
+	quoted := []string{"yes", "No", "ON", "off", "y", "N", "~", "=", "<<", "Null", "1:20", "1_000", "0b101", "0x1F", "010", "1e3"}
+	plain := []string{"yesterday", "one", "12.h", "127.0.0.1", "a=b", "v1.2", "/api"}
+	for _, s := range quoted {
+		data, err := MarshalYAML(map[string]string{"k": s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "k: " + strconv.Quote(s) + "\n"; string(data) != want {
+			t.Errorf("MarshalYAML(%q) = %q, want %q", s, data, want)
+		}
+	}
+	for _, s := range plain {
+		data, err := MarshalYAML(map[string]string{"k": s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "k: " + s + "\n"; string(data) != want {
+			t.Errorf("MarshalYAML(%q) = %q, want %q", s, data, want)
+		}
+		var got map[string]string
+		if err := UnmarshalYAML(data, &got); err != nil || got["k"] != s {
+			t.Errorf("%q did not round-trip: %v %v", s, got, err)
+		}
+	}
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	src := `---
+# An API server.
+HostIP: '0.0.0.0' # quoted
+PortNum: 0x10
+TrustedProxies: [10.0.0.1, "::1"]
+Routes:
+- Path: "/a\tb"
+  Methods: [GET]
+-   Path: /c
+    Methods:
+      - POST
+CORS: {EnableCORS: true, MaxAge: 1.5.min}
+`
+	var got apiserver.APIServerSettings
+	if err := UnmarshalYAML([]byte(src), &got); err != nil {
+		t.Fatalf("UnmarshalYAML failed: %v", err)
+	}
+	want := apiserver.APIServerSettings{
+		HostIP:         "0.0.0.0",
+		PortNum:        16,
+		TrustedProxies: &[]string{"10.0.0.1", "::1"},
+		Routes: []apiserver.APIServerRoutes{
+			{Path: "/a\tb", Methods: []string{"GET"}},
+			{Path: "/c", Methods: []string{"POST"}},
+		},
+		CORS: apiserver.CORSConfig{EnableCORS: true, MaxAge: pkl.Duration{Value: 1.5, Unit: pkl.Minute}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalYAML =\n%+v\nwant\n%+v", got, want)
+	}
+
+	var run exec.ResourceExec
+	if err := UnmarshalYAML([]byte("Command: |\n  echo a\n\n  echo b\nExitCode: 2\n"), &run); err != nil {
+		t.Fatalf("UnmarshalYAML failed: %v", err)
+	}
+	if run.Command != "echo a\n\necho b\n" || *run.ExitCode != 2 {
+		t.Errorf("Unexpected literal block decoding %+v", run)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var k kdeps.Kdeps
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"enum", UnmarshalJSON([]byte(`{"DockerGPU":"tpu"}`), &k), `"tpu" is not a valid GPU`},
+		{"unknown", UnmarshalJSON([]byte(`{"Gpu":"cpu"}`), &k), "unknown property Gpu"},
+		{"range", UnmarshalJSON([]byte(`{"PortNum":70000}`), new(apiserver.APIServerSettings)), "PortNum: cannot decode 70000"},
+		{"duration", UnmarshalYAML([]byte("MaxAge: 12.hours\n"), new(apiserver.CORSConfig)), `invalid duration "12.hours"`},
+		{"type", UnmarshalYAML([]byte("PortNum: many\n"), new(apiserver.APIServerSettings)), "cannot decode many into uint16"},
+		{"indent", UnmarshalYAML([]byte("HostIP: a\n  PortNum: 1\n"), new(apiserver.APIServerSettings)), "line 2: unexpected indentation"},
+		{"trailing", UnmarshalJSON([]byte(`{} {}`), &k), "unexpected data"},
+		{"pointer", UnmarshalJSON([]byte(`{}`), k), "non-pointer"},
+		{"folded", UnmarshalYAML([]byte("Command: >\n  echo a\n"), new(exec.ResourceExec)), "line 1: folded block scalars (>) are not supported"},
+		{"multi-line quoted", UnmarshalYAML([]byte("Command: \"echo\n  a\"\n"), new(exec.ResourceExec)), "quoted strings must end on the line they start"},
+		{"anchor", UnmarshalYAML([]byte("KdepsDir: &a demo\n"), &k), "line 1: anchors are not supported"},
+		{"alias", UnmarshalYAML([]byte("KdepsDir: *ref\n"), &k), "line 1: aliases are not supported"},
+		{"tag", UnmarshalYAML([]byte("KdepsDir: !!str demo\n"), &k), "line 1: tags are not supported"},
+		{"directive", UnmarshalYAML([]byte("%YAML 1.2\nKdepsDir: demo\n"), &k), "line 1: directives are not supported"},
+		{"reserved", UnmarshalYAML([]byte("KdepsDir: @demo\n"), &k), "line 1: '@' is reserved"},
+		{"sequence anchor", UnmarshalYAML([]byte("ItemValues:\n  - &x '@b'\n"), new(exec.ResourceExec)), "line 2: anchors are not supported"},
+		{"flow alias", UnmarshalYAML([]byte("ItemValues: [a, *b]\n"), new(exec.ResourceExec)), "line 1: aliases are not supported"},
+		{"anchored key", UnmarshalYAML([]byte("&a KdepsDir: demo\n"), &k), "line 1: anchors are not supported"},
+		{"complex key", UnmarshalYAML([]byte("? KdepsDir\n: demo\n"), &k), "line 1: complex keys are not supported"},
+		{"complex key entry", UnmarshalYAML([]byte("? KdepsDir: demo\n"), &k), "line 1: complex keys are not supported"},
+	}
+	for _, tt := range tests {
+		if tt.err == nil || !strings.Contains(tt.err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, tt.err)
+		}
+	}
+	if err := UnmarshalYAML([]byte("DockerGPU: nvidia\n"), &k); err != nil || k.DockerGPU != gpu.Nvidia {
+		t.Errorf("Expected a valid enum to decode, got %v %q", err, k.DockerGPU)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for _, s := range []string{"60.s", "1.5.min", "12.h", "0.ns", "-3.d"} {
+		d, err := ParseDuration(s)
+		if err != nil || FormatDuration(d) != s {
+			t.Errorf("ParseDuration(%q) = %v, %v", s, d, err)
+		}
+	}
+	for _, s := range []string{"60", "60s", ".s", "1.2.3.x", "NaN.s"} {
+		if _, err := ParseDuration(s); err == nil {
+			t.Errorf("Expected ParseDuration(%q) to fail", s)
+		}
+	}
+}
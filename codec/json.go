@@ -0,0 +1,98 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// MarshalJSON returns the JSON encoding of v.
+func MarshalJSON(v any) ([]byte, error) {
+	tree, err := encode(reflect.ValueOf(v))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, tree); err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the JSON data into v, which must be a non-nil
+// pointer.
+func UnmarshalJSON(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("failed to decode JSON: non-pointer or nil %T", v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("failed to decode JSON: unexpected data after the value")
+	}
+	if err := decode(tree, rv.Elem()); err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(buf *bytes.Buffer, tree any) error {
+	switch n := tree.(type) {
+	case nil:
+		buf.WriteString("null")
+	case *object:
+		buf.WriteByte('{')
+		for i, key := range n.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeJSON(buf, n.values[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, e := range n {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case int64:
+		buf.WriteString(strconv.FormatInt(n, 10))
+	case uint64:
+		buf.WriteString(strconv.FormatUint(n, 10))
+	case string:
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(n); err != nil {
+			return err
+		}
+		// Encode terminates the value with a newline.
+		buf.Truncate(buf.Len() - 1)
+	default:
+		data, err := json.Marshal(n)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	return nil
+}
@@ -0,0 +1,703 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MarshalYAML returns the YAML encoding of v, in block style with two-space
+// indentation.
+func MarshalYAML(v any) ([]byte, error) {
+	tree, err := encode(reflect.ValueOf(v))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	var buf bytes.Buffer
+	switch n := tree.(type) {
+	case *object:
+		if len(n.keys) > 0 {
+			writeYAMLObject(&buf, n, "", "")
+			return buf.Bytes(), nil
+		}
+	case []any:
+		if len(n) > 0 {
+			writeYAMLList(&buf, n, "")
+			return buf.Bytes(), nil
+		}
+	}
+	buf.WriteString(yamlScalar(tree) + "\n")
+	return buf.Bytes(), nil
+}
+
+// UnmarshalYAML decodes the YAML data into v, which must be a non-nil
+// pointer.
+//
+// It reads the subset of YAML 1.2 described in the package documentation.
+func UnmarshalYAML(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("failed to decode YAML: non-pointer or nil %T", v)
+	}
+	p := &yamlParser{lines: strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")}
+	p.skipDocumentStart()
+	tree, err := p.parseBlock(0)
+	if err == nil && p.skip() {
+		err = p.errorf("unexpected content")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode YAML: %w", err)
+	}
+	if err := decode(tree, rv.Elem()); err != nil {
+		return fmt.Errorf("failed to decode YAML: %w", err)
+	}
+	return nil
+}
+
+// writeYAMLObject writes the entries of o at indent. The first key is
+// prefixed with first instead, so that it can follow a sequence dash.
+func writeYAMLObject(buf *bytes.Buffer, o *object, indent, first string) {
+	for i, key := range o.keys {
+		if i == 0 {
+			buf.WriteString(first)
+		} else {
+			buf.WriteString(indent)
+		}
+		buf.WriteString(yamlScalar(key) + ":")
+		writeYAMLValue(buf, o.values[key], indent, false)
+	}
+}
+
+func writeYAMLList(buf *bytes.Buffer, list []any, indent string) {
+	for _, e := range list {
+		buf.WriteString(indent + "-")
+		writeYAMLValue(buf, e, indent, true)
+	}
+}
+
+// writeYAMLValue writes the value of a mapping entry or sequence item, after
+// its key or dash, at indent.
+func writeYAMLValue(buf *bytes.Buffer, node any, indent string, item bool) {
+	switch n := node.(type) {
+	case *object:
+		if len(n.keys) == 0 {
+			break
+		}
+		if item {
+			// The first entry of a mapping in a sequence shares the dash's line.
+			writeYAMLObject(buf, n, indent+"  ", " ")
+		} else {
+			buf.WriteString("\n")
+			writeYAMLObject(buf, n, indent+"  ", indent+"  ")
+		}
+		return
+	case []any:
+		if len(n) == 0 {
+			break
+		}
+		buf.WriteString("\n")
+		writeYAMLList(buf, n, indent+"  ")
+		return
+	}
+	buf.WriteString(" " + yamlScalar(node) + "\n")
+}
+
+// yamlScalar returns a scalar, or an empty collection, in flow style.
+func yamlScalar(node any) string {
+	switch n := node.(type) {
+	case nil:
+		return "null"
+	case *object:
+		return "{}"
+	case []any:
+		return "[]"
+	case string:
+		if plainSafe(n) {
+			return n
+		}
+		return strconv.Quote(n)
+	case bool:
+		return strconv.FormatBool(n)
+	case int64:
+		return strconv.FormatInt(n, 10)
+	case uint64:
+		return strconv.FormatUint(n, 10)
+	case float64:
+		s := strconv.FormatFloat(n, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		return s
+	}
+	return strconv.Quote(fmt.Sprint(node))
+}
+
+// yaml11Forms are the plain scalars, compared ignoring case, that YAML 1.1
+// parsers read as booleans, nulls, merge keys or value keys rather than
+// strings.
+var yaml11Forms = map[string]bool{
+	"y": true, "yes": true, "n": true, "no": true, "on": true, "off": true,
+	"true": true, "false": true, "null": true, "~": true, "=": true, "<<": true,
+}
+
+// yaml11Number matches the plain scalars that YAML 1.1 parsers read as
+// numbers: with digit separators, binary, hexadecimal or sexagesimal.
+var yaml11Number = regexp.MustCompile(`^[-+]?(0b[01_]+|0x[0-9a-fA-F_]+|[0-9][0-9_]*(:[0-5]?[0-9])*(\.[0-9_]*)?([eE][-+]?[0-9]+)?|\.[0-9_]+([eE][-+]?[0-9]+)?)$`)
+
+// plainSafe reports whether s can be written as a plain scalar that reads
+// back as the same string, both under the YAML 1.2 core schema and under
+// YAML 1.1, which many parsers still implement.
+func plainSafe(s string) bool {
+	if yaml11Forms[strings.ToLower(s)] || yaml11Number.MatchString(s) {
+		return false
+	}
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	if strings.ContainsFunc(s, func(r rune) bool { return unicode.IsControl(r) || r == utf8.RuneError }) {
+		return false
+	}
+	_, isString := plain(s).resolve().(string)
+	return isString
+}
+
+func (p plain) isNull() bool {
+	switch p {
+	case "null", "Null", "NULL", "~", "":
+		return true
+	}
+	return false
+}
+
+// resolve returns the value of a plain scalar under the YAML core schema.
+func (p plain) resolve() any {
+	s := string(p)
+	switch s {
+	case "null", "Null", "NULL", "~", "":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0o") {
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return n
+		}
+	}
+	if strings.ContainsAny(s, "0123456789") && !strings.ContainsAny(s, "xXpP_") {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+type yamlParser struct {
+	lines []string
+	i     int
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.i+1, fmt.Sprintf(format, args...))
+}
+
+// skip moves past blank and comment lines and reports whether a line is left.
+func (p *yamlParser) skip() bool {
+	for ; p.i < len(p.lines); p.i++ {
+		content := strings.TrimLeft(p.lines[p.i], " ")
+		if content != "" && content[0] != '#' {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *yamlParser) skipDocumentStart() {
+	if p.skip() && strings.TrimRight(p.lines[p.i], " ") == "---" {
+		p.i++
+	}
+}
+
+// indent returns the indentation and content of the current line.
+func (p *yamlParser) indent() (int, string, error) {
+	line := p.lines[p.i]
+	content := strings.TrimLeft(line, " ")
+	if strings.HasPrefix(content, "\t") {
+		return 0, "", p.errorf("tabs are not allowed in indentation")
+	}
+	return len(line) - len(content), strings.TrimRight(content, " \t"), nil
+}
+
+// parseBlock parses the node starting on the next line, which must be
+// indented by at least min. It returns nil if there is none.
+func (p *yamlParser) parseBlock(min int) (any, error) {
+	if !p.skip() {
+		return nil, nil
+	}
+	ind, content, err := p.indent()
+	if err != nil || ind < min {
+		return nil, err
+	}
+	switch {
+	case content == "-" || strings.HasPrefix(content, "- "):
+		return p.parseSequence(ind)
+	case isMapEntry(content):
+		return p.parseMapping(ind)
+	}
+	value, err := parseFlow(content)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.i++
+	return value, nil
+}
+
+func (p *yamlParser) parseMapping(ind int) (any, error) {
+	m := make(map[string]any)
+	for p.skip() {
+		lineInd, content, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		if lineInd < ind {
+			break
+		}
+		if lineInd > ind {
+			return nil, p.errorf("unexpected indentation")
+		}
+		key, rest, err := splitMapEntry(content)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		value, err := p.parseValue(rest, ind, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+func (p *yamlParser) parseSequence(ind int) (any, error) {
+	list := []any{}
+	for p.skip() {
+		lineInd, content, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		isItem := content == "-" || strings.HasPrefix(content, "- ")
+		if lineInd < ind || (lineInd == ind && !isItem) {
+			// A sequence may be indented like the key it is the value of.
+			break
+		}
+		if lineInd > ind {
+			return nil, p.errorf("expected a sequence item")
+		}
+		rest := strings.TrimLeft(content[1:], " ")
+		if rest != "" && (rest == "-" || strings.HasPrefix(rest, "- ") || isMapEntry(rest)) {
+			// A compact nested block: parse it as if it started its own line.
+			col := lineInd + len(content) - len(rest)
+			p.lines[p.i] = strings.Repeat(" ", col) + rest
+			value, err := p.parseBlock(col)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+			continue
+		}
+		value, err := p.parseValue(rest, ind, false)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+// parseValue parses the value after a mapping key or sequence dash on the
+// current line, continuing on more-indented lines if it is empty. Sequences
+// that are values of a mapping may be indented like their key.
+func (p *yamlParser) parseValue(rest string, ind int, inMapping bool) (any, error) {
+	rest = stripComment(rest)
+	if strings.HasPrefix(rest, "|") {
+		return p.parseLiteral(rest, ind)
+	}
+	if strings.HasPrefix(rest, ">") {
+		return nil, p.errorf("folded block scalars (>) are not supported, use a literal block scalar (|)")
+	}
+	if rest != "" {
+		value, err := parseFlow(rest)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.i++
+		return value, nil
+	}
+	p.i++
+	if !p.skip() {
+		return nil, nil
+	}
+	next, content, err := p.indent()
+	if err != nil {
+		return nil, err
+	}
+	if next > ind {
+		return p.parseBlock(next)
+	}
+	if inMapping && next == ind && (content == "-" || strings.HasPrefix(content, "- ")) {
+		return p.parseSequence(ind)
+	}
+	return nil, nil
+}
+
+// parseLiteral parses a literal block scalar introduced by header.
+func (p *yamlParser) parseLiteral(header string, ind int) (any, error) {
+	chomp := strings.TrimPrefix(header, "|")
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, p.errorf("unsupported block scalar header %q", header)
+	}
+	p.i++
+	var lines []string
+	block := -1
+	for ; p.i < len(p.lines); p.i++ {
+		line := p.lines[p.i]
+		content := strings.TrimLeft(line, " ")
+		lineInd := len(line) - len(content)
+		if content == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if block < 0 {
+			if lineInd <= ind {
+				break
+			}
+			block = lineInd
+		}
+		if lineInd < block {
+			break
+		}
+		lines = append(lines, line[block:])
+	}
+	text := strings.Join(lines, "\n")
+	switch chomp {
+	case "-":
+		text = strings.TrimRight(text, "\n")
+	case "+":
+		text += "\n"
+	default:
+		text = strings.TrimRight(text, "\n")
+		if text != "" {
+			text += "\n"
+		}
+	}
+	return text, nil
+}
+
+// isMapEntry reports whether content starts with a mapping key.
+func isMapEntry(content string) bool {
+	_, _, err := splitMapEntry(content)
+	return err == nil
+}
+
+// splitMapEntry splits `key: value` into the key and the rest.
+func splitMapEntry(content string) (string, string, error) {
+	if content != "" && (content[0] == '"' || content[0] == '\'') {
+		key, n, err := parseQuoted(content)
+		if err != nil {
+			return "", "", err
+		}
+		rest := strings.TrimLeft(content[n:], " ")
+		if !strings.HasPrefix(rest, ":") || (len(rest) > 1 && rest[1] != ' ') {
+			return "", "", errors.New("expected ':' after key")
+		}
+		return key, strings.TrimSpace(rest[1:]), nil
+	}
+	if content == "" || strings.ContainsRune("[{#", rune(content[0])) || content == "-" || strings.HasPrefix(content, "- ") {
+		return "", "", errors.New("not a mapping entry")
+	}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '#' && i > 0 && content[i-1] == ' ' {
+			break
+		}
+		if content[i] == ':' && (i+1 == len(content) || content[i+1] == ' ') {
+			key := strings.TrimRight(content[:i], " ")
+			if key == "" {
+				break
+			}
+			if err := checkPlain(key); err != nil {
+				return "", "", err
+			}
+			return key, strings.TrimSpace(content[i+1:]), nil
+		}
+	}
+	return "", "", errors.New("not a mapping entry")
+}
+
+// stripComment removes a trailing comment from a single-line value.
+func stripComment(s string) string {
+	quote := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return strings.TrimRight(s[:i], " ")
+		}
+	}
+	return s
+}
+
+// parseFlow parses a single-line scalar or flow collection.
+func parseFlow(s string) (any, error) {
+	f := &flowParser{s: stripComment(s)}
+	node, err := f.value(false)
+	if err != nil {
+		return nil, err
+	}
+	f.space()
+	if f.pos != len(f.s) {
+		return nil, fmt.Errorf("unexpected %q after value", f.s[f.pos:])
+	}
+	return node, nil
+}
+
+type flowParser struct {
+	s   string
+	pos int
+}
+
+func (f *flowParser) space() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *flowParser) value(inFlow bool) (any, error) {
+	f.space()
+	if f.pos == len(f.s) {
+		return plain(""), nil
+	}
+	switch f.s[f.pos] {
+	case '[':
+		f.pos++
+		list := []any{}
+		for {
+			f.space()
+			if f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return list, nil
+			}
+			e, err := f.value(true)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, e)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		m := make(map[string]any)
+		for {
+			f.space()
+			if f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return m, nil
+			}
+			k, err := f.value(true)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if p, isPlain := k.(plain); isPlain {
+				key, ok = string(p), true
+			}
+			f.space()
+			if !ok || f.pos == len(f.s) || f.s[f.pos] != ':' {
+				return nil, errors.New("expected 'key: value' in flow mapping")
+			}
+			f.pos++
+			e, err := f.value(true)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = e
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		s, n, err := parseQuoted(f.s[f.pos:])
+		if err != nil {
+			return nil, err
+		}
+		f.pos += n
+		return s, nil
+	}
+	start := f.pos
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		if inFlow && (c == ',' || c == ']' || c == '}' || (c == ':' && (f.pos+1 == len(f.s) || f.s[f.pos+1] == ' '))) {
+			break
+		}
+		f.pos++
+	}
+	text := strings.TrimRight(f.s[start:f.pos], " ")
+	if err := checkPlain(text); err != nil {
+		return nil, err
+	}
+	return plain(text), nil
+}
+
+// checkPlain rejects a plain scalar that starts with an indicator of a YAML
+// feature this package does not support, rather than reading the indicator
+// as part of a string.
+func checkPlain(s string) error {
+	if s == "" {
+		return nil
+	}
+	switch s[0] {
+	case '&':
+		return fmt.Errorf("anchors are not supported: %q", s)
+	case '*':
+		return fmt.Errorf("aliases are not supported: %q", s)
+	case '!':
+		return fmt.Errorf("tags are not supported: %q", s)
+	case '%':
+		return fmt.Errorf("directives are not supported: %q", s)
+	case '@', '`':
+		return fmt.Errorf("%q is reserved and cannot start a plain scalar, quote the value: %q", s[0], s)
+	case '?':
+		if len(s) == 1 || s[1] == ' ' {
+			return fmt.Errorf("complex keys are not supported: %q", s)
+		}
+	}
+	return nil
+}
+
+func (f *flowParser) separator(end byte) error {
+	f.space()
+	if f.pos < len(f.s) && f.s[f.pos] == ',' {
+		f.pos++
+		return nil
+	}
+	if f.pos < len(f.s) && f.s[f.pos] == end {
+		return nil
+	}
+	return fmt.Errorf("expected ',' or '%c' in flow collection", end)
+}
+
+// parseQuoted parses the quoted scalar at the start of s and returns its
+// value and length.
+func parseQuoted(s string) (string, int, error) {
+	if s[0] == '\'' {
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					b.WriteByte('\'')
+					i++
+					continue
+				}
+				return b.String(), i + 1, nil
+			}
+			b.WriteByte(s[i])
+		}
+		return "", 0, errors.New("unterminated single-quoted string (quoted strings must end on the line they start)")
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return b.String(), i + 1, nil
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			break
+		}
+		switch c := s[i]; c {
+		case '0':
+			b.WriteByte(0)
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 't', '\t':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'v':
+			b.WriteByte('\v')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'e':
+			b.WriteByte(0x1b)
+		case ' ', '"', '/', '\\':
+			b.WriteByte(c)
+		case 'N':
+			b.WriteRune('\u0085')
+		case '_':
+			b.WriteRune(' ')
+		case 'L':
+			b.WriteRune(' ')
+		case 'P':
+			b.WriteRune(' ')
+		case 'x', 'u', 'U':
+			n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+			if i+n >= len(s) {
+				return "", 0, errors.New("invalid escape in double-quoted string")
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", 0, errors.New("invalid escape in double-quoted string")
+			}
+			if c == 'x' {
+				b.WriteByte(byte(r))
+			} else {
+				b.WriteRune(rune(r))
+			}
+			i += n
+		default:
+			return "", 0, fmt.Errorf("invalid escape \\%c in double-quoted string", c)
+		}
+	}
+	return "", 0, errors.New("unterminated double-quoted string (quoted strings must end on the line they start)")
+}
@@ -0,0 +1,119 @@
+package defaults
+
+import (
+	"reflect"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// constructors maps each type with a New function to that function.
+var constructors = make(map[reflect.Type]func() reflect.Value)
+
+func register[T any](newT func() T) {
+	constructors[reflect.TypeOf(*new(T))] = func() reflect.Value {
+		return reflect.ValueOf(newT())
+	}
+}
+
+func init() {
+	register(NewWorkflow)
+	register(NewSettings)
+	register(NewDockerSettings)
+	register(NewAPIServerSettings)
+	register(NewCORSConfig)
+	register(NewWebServerSettings)
+	register(NewWebServerRoutes)
+	register(NewAPIServerResponse)
+	register(NewResourceExec)
+	register(NewResourcePython)
+	register(NewResourceHTTPClient)
+	register(NewResourceChat)
+	register(NewToolProperties)
+	register(NewKdeps)
+}
+
+var (
+	durationType = reflect.TypeOf(pkl.Duration{})
+	dataSizeType = reflect.TypeOf(pkl.DataSize{})
+)
+
+// ApplyDefaults sets the unset fields of *v, and of every value nested in
+// it, to their Pkl defaults.
+//
+// A field is unset if it holds its zero value; nil pointers to optional
+// objects are left nil, as Pkl defaults them to null. A struct that is
+// entirely zero is replaced by its New value. Otherwise bool fields are left
+// alone, since a false that was set explicitly cannot be told apart from an
+// unset one: build values with a New function to get bools that default to
+// true, such as CORSConfig.AllowCredentials.
+func ApplyDefaults[T any](v *T) {
+	if v == nil {
+		return
+	}
+	apply(reflect.ValueOf(v).Elem())
+}
+
+func apply(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			apply(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			apply(v.Index(i))
+		}
+	case reflect.Map:
+		if !walked(v.Type().Elem()) {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			// Map elements are not addressable, so update a copy.
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(iter.Value())
+			apply(e)
+			v.SetMapIndex(iter.Key(), e)
+		}
+	case reflect.Struct:
+		if scalar(v.Type()) {
+			return
+		}
+		if newT, ok := constructors[v.Type()]; ok {
+			if v.IsZero() {
+				v.Set(newT())
+				return
+			}
+			def := newT()
+			for i := 0; i < v.NumField(); i++ {
+				f := v.Field(i)
+				if f.Kind() == reflect.Bool || (f.Kind() == reflect.Struct && !scalar(f.Type())) {
+					// Nested structs are handled below, field by field.
+					continue
+				}
+				if f.CanSet() && f.IsZero() {
+					f.Set(def.Field(i))
+				}
+			}
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.CanSet() {
+				apply(f)
+			}
+		}
+	}
+}
+
+// walked reports whether values of t may hold structs to apply defaults to.
+func walked(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		return true
+	}
+	return false
+}
+
+// scalar reports whether the struct type t is a Pkl scalar such as Duration.
+func scalar(t reflect.Type) bool {
+	return t == durationType || t == dataSizeType
+}
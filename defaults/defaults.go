@@ -0,0 +1,160 @@
+// Package defaults constructs the generated kdeps types with the default
+// values their Pkl modules declare.
+//
+// The Go zero value of a generated struct is not what Pkl produces for an
+// object that leaves its properties unset: WebServerSettings.PortNum is 8080,
+// not 0, and ResourceExec.TimeoutDuration is 60.s, not nil. The New
+// functions return values equal to Pkl's, and ApplyDefaults fills in the
+// unset fields of a value built by hand.
+//
+// Types without a New function, such as resource.Resource or
+// apiserver.APIServerRoutes, declare no defaults of their own; their zero
+// value is what Pkl produces, apart from nested values ApplyDefaults fills
+// in.
+package defaults
+
+import (
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/api_server_response"
+	"github.com/kdeps/schema/gen/docker"
+	"github.com/kdeps/schema/gen/exec"
+	"github.com/kdeps/schema/gen/http"
+	"github.com/kdeps/schema/gen/kdeps"
+	"github.com/kdeps/schema/gen/kdeps/gpu"
+	"github.com/kdeps/schema/gen/kdeps/mode"
+	"github.com/kdeps/schema/gen/kdeps/path"
+	"github.com/kdeps/schema/gen/llm"
+	"github.com/kdeps/schema/gen/project"
+	"github.com/kdeps/schema/gen/python"
+	"github.com/kdeps/schema/gen/web_server"
+	"github.com/kdeps/schema/gen/web_server/webservertype"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+// TimeoutDuration is the default timeout of exec, python, HTTP and chat
+// resources.
+var TimeoutDuration = pkl.Duration{Value: 60, Unit: pkl.Second}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// NewWorkflow returns a Workflow with the defaults of Workflow.pkl.
+func NewWorkflow() workflow.WorkflowImpl {
+	return workflow.WorkflowImpl{
+		Version:  "1.0.0",
+		Settings: NewSettings(),
+	}
+}
+
+// NewSettings returns project Settings with the defaults of Project.pkl.
+// APIServer and WebServer default to null.
+func NewSettings() project.Settings {
+	return project.Settings{
+		AgentSettings: NewDockerSettings(),
+	}
+}
+
+// NewDockerSettings returns DockerSettings with the defaults of Docker.pkl.
+func NewDockerSettings() docker.DockerSettings {
+	return docker.DockerSettings{
+		Timezone:       "Etc/UTC",
+		OllamaImageTag: "0.6.5",
+	}
+}
+
+// NewAPIServerSettings returns APIServerSettings with the defaults of
+// APIServer.pkl.
+func NewAPIServerSettings() apiserver.APIServerSettings {
+	return apiserver.APIServerSettings{
+		HostIP:  "127.0.0.1",
+		PortNum: 3000,
+		CORS:    NewCORSConfig(),
+	}
+}
+
+// NewCORSConfig returns a CORSConfig with the defaults of APIServer.pkl.
+func NewCORSConfig() apiserver.CORSConfig {
+	return apiserver.CORSConfig{
+		AllowCredentials: true,
+		MaxAge:           pkl.Duration{Value: 12, Unit: pkl.Hour},
+	}
+}
+
+// NewWebServerSettings returns WebServerSettings with the defaults of
+// WebServer.pkl.
+func NewWebServerSettings() webserver.WebServerSettings {
+	return webserver.WebServerSettings{
+		HostIP:  "127.0.0.1",
+		PortNum: 8080,
+	}
+}
+
+// NewWebServerRoutes returns WebServerRoutes with the defaults of
+// WebServer.pkl.
+func NewWebServerRoutes() webserver.WebServerRoutes {
+	return webserver.WebServerRoutes{
+		AppPort:    ptr[uint16](8052),
+		ServerType: webservertype.Static,
+		PublicPath: "/web",
+	}
+}
+
+// NewAPIServerResponse returns an APIServerResponse with the defaults of
+// APIServerResponse.pkl.
+func NewAPIServerResponse() apiserverresponse.APIServerResponseImpl {
+	return apiserverresponse.APIServerResponseImpl{
+		Success: true,
+	}
+}
+
+// NewResourceExec returns a ResourceExec with the defaults of Exec.pkl.
+func NewResourceExec() exec.ResourceExec {
+	return exec.ResourceExec{
+		ExitCode:        ptr(0),
+		TimeoutDuration: ptr(TimeoutDuration),
+	}
+}
+
+// NewResourcePython returns a ResourcePython with the defaults of Python.pkl.
+func NewResourcePython() python.ResourcePython {
+	return python.ResourcePython{
+		ExitCode:        ptr(0),
+		TimeoutDuration: ptr(TimeoutDuration),
+	}
+}
+
+// NewResourceHTTPClient returns a ResourceHTTPClient with the defaults of
+// HTTP.pkl.
+func NewResourceHTTPClient() http.ResourceHTTPClient {
+	return http.ResourceHTTPClient{
+		TimeoutDuration: ptr(TimeoutDuration),
+	}
+}
+
+// NewResourceChat returns a ResourceChat with the defaults of LLM.pkl.
+func NewResourceChat() llm.ResourceChat {
+	return llm.ResourceChat{
+		Model:           "llama3.2",
+		JSONResponse:    ptr(false),
+		TimeoutDuration: ptr(TimeoutDuration),
+	}
+}
+
+// NewToolProperties returns ToolProperties with the defaults of LLM.pkl.
+func NewToolProperties() llm.ToolProperties {
+	return llm.ToolProperties{
+		Required: ptr(true),
+	}
+}
+
+// NewKdeps returns the Kdeps configuration with the defaults of Kdeps.pkl.
+func NewKdeps() kdeps.Kdeps {
+	return kdeps.Kdeps{
+		RunMode:   mode.Docker,
+		DockerGPU: gpu.Cpu,
+		KdepsDir:  ".kdeps",
+		KdepsPath: path.User,
+	}
+}
@@ -0,0 +1,181 @@
+package defaults
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/api_server_request"
+	"github.com/kdeps/schema/gen/api_server_response"
+	"github.com/kdeps/schema/gen/data"
+	"github.com/kdeps/schema/gen/exec"
+	"github.com/kdeps/schema/gen/http"
+	"github.com/kdeps/schema/gen/kdeps"
+	"github.com/kdeps/schema/gen/llm"
+	"github.com/kdeps/schema/gen/python"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/web_server"
+	"github.com/kdeps/schema/gen/web_server/webservertype"
+	"github.com/kdeps/schema/gen/workflow"
+	"github.com/kdeps/schema/internal/pkldecl"
+)
+
+// roots are the module types every generated class is reachable from.
+var roots = []reflect.Type{
+	reflect.TypeOf(workflow.WorkflowImpl{}),
+	reflect.TypeOf(resource.Resource{}),
+	reflect.TypeOf(kdeps.Kdeps{}),
+	reflect.TypeOf(apiserverrequest.APIServerRequestImpl{}),
+	reflect.TypeOf(apiserverresponse.APIServerResponseImpl{}),
+	reflect.TypeOf(data.DataImpl{}),
+	reflect.TypeOf(exec.ExecImpl{}),
+	reflect.TypeOf(http.HTTPImpl{}),
+	reflect.TypeOf(llm.LLMImpl{}),
+	reflect.TypeOf(python.PythonImpl{}),
+}
+
+// classTypes returns the generated struct types reachable from roots.
+func classTypes() []reflect.Type {
+	seen := make(map[reflect.Type]bool)
+	var ret []reflect.Type
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			walk(t.Elem())
+		case reflect.Struct:
+			if seen[t] || t.PkgPath() == reflect.TypeOf(pkl.Duration{}).PkgPath() {
+				return
+			}
+			seen[t] = true
+			ret = append(ret, t)
+			for i := 0; i < t.NumField(); i++ {
+				walk(t.Field(i).Type)
+			}
+		}
+	}
+	for _, t := range roots {
+		walk(t)
+	}
+	return ret
+}
+
+// literal returns v as ParseLiteral would return its Pkl literal.
+func literal(v reflect.Value) (any, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), true
+	}
+	return v.Interface(), true
+}
+
+// TestDefaultsMatchPkl checks every New function against the defaults
+// declared in the Pkl source, and that every class declaring a default has
+// a New function.
+func TestDefaultsMatchPkl(t *testing.T) {
+	modules, err := pkldecl.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range classTypes() {
+		_, class, err := modules.ClassOf(typ)
+		if err != nil {
+			t.Errorf("%s: %v", typ, err)
+			continue
+		}
+		newT, ok := constructors[typ]
+		if !ok {
+			for _, p := range class.Properties {
+				if p.Default != "" && p.Default != "null" {
+					t.Errorf("%s declares %s = %s but has no New function", typ, p.Name, p.Default)
+				}
+			}
+			continue
+		}
+
+		v := newT()
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			got := v.Field(i)
+			if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(pkl.Duration{}) {
+				if nested, ok := constructors[f.Type]; ok && !reflect.DeepEqual(got.Interface(), nested().Interface()) {
+					t.Errorf("%s.%s is not New%s()", typ, f.Name, f.Type.Name())
+				}
+				continue
+			}
+			p := class.Property(f.Tag.Get("pkl"))
+			if p == nil {
+				t.Errorf("%s.%s has no Pkl property", typ, f.Name)
+				continue
+			}
+			want, hasDefault := pkldecl.ParseLiteral(p.Default)
+			if !hasDefault {
+				if !got.IsZero() {
+					t.Errorf("%s.%s = %v, but Pkl declares no default", typ, f.Name, got)
+				}
+				continue
+			}
+			if lit, ok := literal(got); !ok || !reflect.DeepEqual(lit, want) {
+				t.Errorf("%s.%s = %v, want the Pkl default %s", typ, f.Name, lit, p.Default)
+			}
+		}
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	wf := workflow.WorkflowImpl{AgentID: "demo"}
+	wf.Settings.APIServer = &apiserver.APIServerSettings{PortNum: 9000}
+	wf.Settings.WebServer = &webserver.WebServerSettings{
+		Routes: []webserver.WebServerRoutes{{Path: "/ui", ServerType: webservertype.App}},
+	}
+	ApplyDefaults(&wf)
+
+	if wf.AgentID != "demo" || wf.Version != "1.0.0" || wf.Settings.AgentSettings.Timezone != "Etc/UTC" {
+		t.Errorf("Unexpected workflow %+v", wf)
+	}
+	api := wf.Settings.APIServer
+	if api.PortNum != 9000 || api.HostIP != "127.0.0.1" || !reflect.DeepEqual(api.CORS, NewCORSConfig()) {
+		t.Errorf("Unexpected API server settings %+v", api)
+	}
+	web := wf.Settings.WebServer
+	if web.PortNum != 8080 {
+		t.Errorf("Unexpected web server port %d", web.PortNum)
+	}
+	if route := web.Routes[0]; route.ServerType != webservertype.App || route.PublicPath != "/web" || *route.AppPort != 8052 {
+		t.Errorf("Unexpected route %+v", route)
+	}
+}
+
+func TestApplyDefaultsKeepsSetValues(t *testing.T) {
+	cors := apiserver.CORSConfig{EnableCORS: true, AllowCredentials: false}
+	ApplyDefaults(&cors)
+	if cors.AllowCredentials || cors.MaxAge != NewCORSConfig().MaxAge {
+		t.Errorf("Unexpected CORS config %+v", cors)
+	}
+
+	chats := map[string]llm.ResourceChat{"a": {Model: "mistral"}}
+	ApplyDefaults(&chats)
+	if chat := chats["a"]; chat.Model != "mistral" || *chat.TimeoutDuration != TimeoutDuration || *chat.JSONResponse {
+		t.Errorf("Unexpected chat %+v", chat)
+	}
+
+	var res resource.Resource
+	ApplyDefaults(&res)
+	if res.Run.Exec != nil || res.Run.Chat != nil {
+		t.Errorf("Expected optional objects to stay null, got %+v", res.Run)
+	}
+	ApplyDefaults[resource.Resource](nil)
+}
@@ -0,0 +1,238 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// EvalError is a Pkl evaluation failure with the details of pkl's error
+// report broken out. The Loader returns it in place of *pkl.EvalError, which
+// remains reachable through errors.As.
+type EvalError struct {
+	// ModuleURI is the module where the failure is reported, normally the
+	// evaluated file.
+	ModuleURI string
+	// Line and Column locate the failing expression, 1-based. They are zero
+	// if pkl reported no source location.
+	Line, Column int
+	// PropertyPath is the path of the failing member within ModuleURI, e.g.
+	// "Settings.APIServer.Routes[2].Methods".
+	PropertyPath string
+	// Constraint is the violated type constraint, such as "isValidHTTPMethod",
+	// or empty if the failure is not a constraint violation.
+	Constraint string
+	// Message is pkl's description of the failure, e.g. the text of the throw.
+	Message string
+	// Value is the offending value as printed by pkl, if reported.
+	Value string
+	// Frames is the stack trace, innermost first.
+	Frames []Frame
+
+	err *pkl.EvalError
+}
+
+// Frame is an entry of a Pkl stack trace.
+type Frame struct {
+	// Member is the member being evaluated, e.g. "workflow#Settings.AgentSettings".
+	Member string
+	// ModuleURI is the URI of the module holding the source.
+	ModuleURI string
+	// Line and Column locate the expression, 1-based.
+	Line, Column int
+	// Source is the source line.
+	Source string
+}
+
+func (e *EvalError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.ModuleURI)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d:%d", e.Line, e.Column)
+	}
+	b.WriteString(": ")
+	if e.PropertyPath != "" {
+		b.WriteString(e.PropertyPath)
+		b.WriteString(": ")
+	}
+	b.WriteString(firstLine(e.Message))
+	if e.Constraint != "" {
+		fmt.Fprintf(&b, " (constraint %s)", e.Constraint)
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying *pkl.EvalError.
+func (e *EvalError) Unwrap() error {
+	return e.err
+}
+
+// Output returns pkl's original error report.
+func (e *EvalError) Output() string {
+	if e.err == nil {
+		return ""
+	}
+	return e.err.ErrorOutput
+}
+
+var (
+	sourceLine     = regexp.MustCompile(`^(\s*)(\d+) \| (.*)$`)
+	frameLine      = regexp.MustCompile(`^at (.*) \((.*)\)$`)
+	typeConstraint = regexp.MustCompile("^Type constraint `(.+)` violated\\.")
+	functionFrame  = regexp.MustCompile(`\.<function#\d+>`)
+)
+
+// NewEvalError parses the report of a *pkl.EvalError raised while evaluating
+// moduleURI. The frame of moduleURI, or else the outermost frame of a user
+// module, provides the location and property path.
+func NewEvalError(moduleURI string, err *pkl.EvalError) *EvalError {
+	e := &EvalError{ModuleURI: moduleURI, err: err}
+	lines := strings.Split(strings.ReplaceAll(err.ErrorOutput, "\r\n", "\n"), "\n")
+
+	var message []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := sourceLine.FindStringSubmatch(line); m != nil {
+			frame, next, ok := parseFrame(lines, i, m)
+			if ok {
+				e.Frames = append(e.Frames, frame)
+				i = next
+				continue
+			}
+		}
+		if len(e.Frames) == 0 && !strings.HasPrefix(line, "–– Pkl Error ––") {
+			message = append(message, line)
+		}
+	}
+
+	msg := strings.TrimSpace(strings.Join(message, "\n"))
+	if before, value, ok := strings.Cut(msg, "\nValue: "); ok {
+		msg, e.Value = strings.TrimSpace(before), strings.TrimSpace(value)
+	}
+	e.Message = msg
+
+	if m := typeConstraint.FindStringSubmatch(msg); m != nil {
+		e.Constraint = m[1]
+	} else if len(e.Frames) > 0 && strings.Contains(e.Frames[0].Source, "throw(") {
+		e.Constraint = lastSegment(e.Frames[0].Member)
+	}
+
+	if frame, ok := e.locationFrame(); ok {
+		e.ModuleURI = frame.ModuleURI
+		e.Line, e.Column = frame.Line, frame.Column
+		if _, path, ok := strings.Cut(frame.Member, "#"); ok {
+			e.PropertyPath = functionFrame.ReplaceAllString(path, "")
+		}
+	}
+	return e
+}
+
+// parseFrame reads the source line at lines[i], the caret line and the "at"
+// line that follow it.
+func parseFrame(lines []string, i int, m []string) (Frame, int, bool) {
+	j := i + 1
+	// Multi-line expressions repeat the source line prefix before the carets.
+	for j < len(lines) && sourceLine.MatchString(lines[j]) {
+		j++
+	}
+	if j+1 >= len(lines) || !strings.Contains(lines[j], "^") {
+		return Frame{}, i, false
+	}
+	at := frameLine.FindStringSubmatch(lines[j+1])
+	if at == nil {
+		return Frame{}, i, false
+	}
+
+	line, _ := strconv.Atoi(m[2])
+	prefix := len(m[1]) + len(m[2]) + len(" | ")
+	column := strings.IndexByte(lines[j], '^') - prefix + 1
+	return Frame{
+		Member:    strings.TrimSpace(at[1]),
+		ModuleURI: at[2],
+		Line:      line,
+		Column:    max(column, 1),
+		Source:    m[3],
+	}, j + 1, true
+}
+
+func (e *EvalError) locationFrame() (Frame, bool) {
+	for _, f := range e.Frames {
+		if f.ModuleURI == e.ModuleURI {
+			return f, true
+		}
+	}
+	for i := len(e.Frames) - 1; i >= 0; i-- {
+		uri := e.Frames[i].ModuleURI
+		if !strings.HasPrefix(uri, "pkl:") && !strings.HasPrefix(uri, "repl:") {
+			return e.Frames[i], true
+		}
+	}
+	return Frame{}, false
+}
+
+func lastSegment(member string) string {
+	member = functionFrame.ReplaceAllString(member, "")
+	if _, after, ok := strings.Cut(member, "#"); ok {
+		member = after
+	}
+	if i := strings.LastIndexByte(member, '.'); i >= 0 {
+		return member[i+1:]
+	}
+	return member
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// wrapEvalError converts a *pkl.EvalError in err into an *EvalError.
+func wrapEvalError(moduleURI string, err error) error {
+	var pklErr *pkl.EvalError
+	if err == nil || !errors.As(err, &pklErr) {
+		return err
+	}
+	var evalErr *EvalError
+	if errors.As(err, &evalErr) {
+		return err
+	}
+	return NewEvalError(moduleURI, pklErr)
+}
+
+// EvalErrors is a list of evaluation failures.
+type EvalErrors []*EvalError
+
+// EvalErrorsOf returns every *EvalError in the tree of err, including errors
+// joined with errors.Join or collected by agent.Load, in order.
+func EvalErrorsOf(err error) EvalErrors {
+	var list EvalErrors
+	var walk func(error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case nil:
+		case *EvalError:
+			list = append(list, e)
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return list
+}
+
+// Error renders the list with one failure per line.
+func (l EvalErrors) Error() string {
+	lines := make([]string, len(l))
+	for i, e := range l {
+		lines[i] = e.Error()
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+const constraintOutput = `–– Pkl Error ––
+Error: Unsupported HTTP method. The provided HTTP method is not supported. Please use one of the following methods: GET, POST, PUT, PATCH, DELETE, OPTIONS, or HEAD.
+
+49 |         hidden isValidHTTPMethod = (str) -> if (str.matches(APIServerMethodRegex)) true else throw("Error: Unsupported HTTP method.")
+                                                                                                 ^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^
+at org.kdeps.pkl.APIServer#APIServerRoutes.isValidHTTPMethod.<function#1> (package://schema.kdeps.com/core@0.5.0#/APIServer.pkl)
+
+55 |         Methods: Listing<String(isValidHTTPMethod)>
+                             ^^^^^^^^^^^^^^^^^^^^^^^^^
+at org.kdeps.pkl.APIServer#APIServerRoutes.Methods (package://schema.kdeps.com/core@0.5.0#/APIServer.pkl)
+
+14 |         Methods { "FETCH" }
+                       ^^^^^^^
+at workflow#Settings.APIServer.Routes[2].Methods (file:///work/agent/workflow.pkl)
+
+106 | text = renderer.renderDocument(value)
+             ^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^
+at pkl.base#Module.output.text (pkl:base)
+`
+
+const typeConstraintOutput = `–– Pkl Error ––
+Type constraint ` + "`length >= 3`" + ` violated.
+Value: "ab"
+
+3 | Name: String(length >= 3)
+                 ^^^^^^^^^^^
+at resource#Name (file:///work/agent/resources/base.pkl)
+
+5 | Name = "ab"
+           ^^^^
+at resource#Name (file:///work/agent/resources/fetch.pkl)
+`
+
+func TestNewEvalErrorFromThrow(t *testing.T) {
+	e := NewEvalError("file:///work/agent/workflow.pkl", &pkl.EvalError{ErrorOutput: constraintOutput})
+
+	if e.ModuleURI != "file:///work/agent/workflow.pkl" || e.Line != 14 || e.Column != 19 {
+		t.Errorf("Unexpected location %s:%d:%d", e.ModuleURI, e.Line, e.Column)
+	}
+	if e.PropertyPath != "Settings.APIServer.Routes[2].Methods" {
+		t.Errorf("Unexpected property path %q", e.PropertyPath)
+	}
+	if e.Constraint != "isValidHTTPMethod" {
+		t.Errorf("Unexpected constraint %q", e.Constraint)
+	}
+	if !strings.HasPrefix(e.Message, "Error: Unsupported HTTP method.") || strings.Contains(e.Message, "\n") {
+		t.Errorf("Unexpected message %q", e.Message)
+	}
+	if len(e.Frames) != 4 || e.Frames[3].ModuleURI != "pkl:base" || e.Frames[0].Line != 49 {
+		t.Errorf("Unexpected frames %+v", e.Frames)
+	}
+
+	want := "file:///work/agent/workflow.pkl:14:19: Settings.APIServer.Routes[2].Methods: " + e.Message + " (constraint isValidHTTPMethod)"
+	if e.Error() != want {
+		t.Errorf("Unexpected Error():\n%s\nwant:\n%s", e.Error(), want)
+	}
+}
+
+func TestNewEvalErrorFromTypeConstraint(t *testing.T) {
+	// Without a frame in the evaluated module, the outermost user frame is used.
+	e := NewEvalError("repl:text", &pkl.EvalError{ErrorOutput: typeConstraintOutput})
+
+	if e.ModuleURI != "file:///work/agent/resources/fetch.pkl" || e.Line != 5 || e.Column != 8 {
+		t.Errorf("Unexpected location %s:%d:%d", e.ModuleURI, e.Line, e.Column)
+	}
+	if e.Constraint != "length >= 3" || e.Value != `"ab"` || e.PropertyPath != "Name" {
+		t.Errorf("Unexpected details %+v", e)
+	}
+	if e.Message != "Type constraint `length >= 3` violated." {
+		t.Errorf("Unexpected message %q", e.Message)
+	}
+}
+
+func TestNewEvalErrorWithoutFrames(t *testing.T) {
+	e := NewEvalError("file:///x.pkl", &pkl.EvalError{ErrorOutput: "–– Pkl Error ––\nCannot find module `file:///missing.pkl`.\n"})
+	if e.ModuleURI != "file:///x.pkl" || e.Line != 0 || e.Message != "Cannot find module `file:///missing.pkl`." {
+		t.Errorf("Unexpected error %+v", e)
+	}
+	if e.Error() != "file:///x.pkl: Cannot find module `file:///missing.pkl`." {
+		t.Errorf("Unexpected Error() %q", e.Error())
+	}
+}
+
+// failingManager hands out evaluators that fail with a fixed report.
+type failingManager struct {
+	fakeManager
+	output string
+}
+
+func (m *failingManager) NewEvaluator(context.Context, ...func(*pkl.EvaluatorOptions)) (pkl.Evaluator, error) {
+	return &failingEvaluator{output: m.output}, nil
+}
+
+type failingEvaluator struct {
+	fakeEvaluator
+	output string
+}
+
+func (e *failingEvaluator) EvaluateModule(context.Context, *pkl.ModuleSource, any) error {
+	return &pkl.EvalError{ErrorOutput: e.output}
+}
+
+func TestLoaderReturnsEvalError(t *testing.T) {
+	loader := NewLoader(WithEvaluatorManager(&failingManager{output: constraintOutput}))
+	defer loader.Close()
+
+	var out struct{}
+	err := loader.EvaluateFile(context.Background(), "/work/agent/workflow.pkl", &out)
+
+	var evalErr *EvalError
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("Expected *EvalError, got %T: %v", err, err)
+	}
+	if evalErr.Line != 14 {
+		t.Errorf("Expected the failure in the evaluated file, got %s", evalErr)
+	}
+	var pklErr *pkl.EvalError
+	if !errors.As(err, &pklErr) || evalErr.Output() != constraintOutput {
+		t.Error("The original *pkl.EvalError is not reachable")
+	}
+
+	joined := fmt.Errorf("loading agent: %w", errors.Join(err, errors.New("unrelated"), NewEvalError("file:///work/agent/resources/fetch.pkl", &pkl.EvalError{ErrorOutput: typeConstraintOutput})))
+	list := EvalErrorsOf(joined)
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 evaluation errors, got %d", len(list))
+	}
+	if lines := strings.Split(list.Error(), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], "file:///work/agent/resources/fetch.pkl:5:8: Name: ") {
+		t.Errorf("Unexpected rendering:\n%s", list.Error())
+	}
+}
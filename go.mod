@@ -4,7 +4,10 @@ go 1.23.7
 
 toolchain go1.24.4
 
-require github.com/apple/pkl-go v0.12.1
+require (
+	github.com/apple/pkl-go v0.12.1
+	golang.org/x/sys v0.35.0
+)
 
 retract (
 	v0.4.6
@@ -0,0 +1,360 @@
+// Package graph builds the dependency graph of kdeps resources from their
+// Requires lists, validates it against a workflow's TargetActionID and
+// derives an execution plan.
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kdeps/schema/gen/resource"
+)
+
+// MissingDependencyError reports a Requires entry that names no known resource.
+type MissingDependencyError struct {
+	ActionID   string
+	Dependency string
+}
+
+func (e *MissingDependencyError) Error() string {
+	return fmt.Sprintf("resource %q requires unknown resource %q", e.ActionID, e.Dependency)
+}
+
+// CycleError reports resources that depend on each other. Cycle lists them
+// in dependency order, starting with the smallest ActionID and ending where
+// it started, e.g. [a b c a] for a requiring b, b requiring c and c requiring a.
+type CycleError struct {
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// UnresolvedTargetError reports a TargetActionID that names no known resource.
+type UnresolvedTargetError struct {
+	TargetActionID string
+}
+
+func (e *UnresolvedTargetError) Error() string {
+	return fmt.Sprintf("TargetActionID %q does not match any resource", e.TargetActionID)
+}
+
+// ValidationError collects every problem found by Graph.Validate.
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d dependency errors:", len(e.Errors))
+	for _, err := range e.Errors {
+		b.WriteString("\n  ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the collected errors, so errors.As finds each of them.
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// Graph is the dependency graph of a set of resources. An edge from a to b
+// means a requires b, so b must run before a.
+type Graph struct {
+	resources map[string]*resource.Resource
+	deps      map[string][]string
+	missing   []error
+}
+
+// New builds the graph of resources, which are indexed by ActionID as in
+// agent.Agent.Resources. Requires entries that name unknown resources are
+// reported by Validate and otherwise ignored.
+func New(resources map[string]*resource.Resource) *Graph {
+	g := &Graph{
+		resources: resources,
+		deps:      make(map[string][]string, len(resources)),
+	}
+	for _, id := range sortedIDs(resources) {
+		r := resources[id]
+		if r.Requires == nil {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, dep := range *r.Requires {
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			if _, ok := resources[dep]; !ok {
+				g.missing = append(g.missing, &MissingDependencyError{ActionID: id, Dependency: dep})
+				continue
+			}
+			g.deps[id] = append(g.deps[id], dep)
+		}
+		sort.Strings(g.deps[id])
+	}
+	return g
+}
+
+// ActionIDs returns the ActionIDs of all resources, sorted.
+func (g *Graph) ActionIDs() []string {
+	return sortedIDs(g.resources)
+}
+
+// Resource returns the resource with the given ActionID.
+func (g *Graph) Resource(id string) (*resource.Resource, bool) {
+	r, ok := g.resources[id]
+	return r, ok
+}
+
+// Dependencies returns the known resources that id requires directly, sorted.
+func (g *Graph) Dependencies(id string) []string {
+	return append([]string(nil), g.deps[id]...)
+}
+
+// Dependents returns the resources that require id directly, sorted.
+func (g *Graph) Dependents(id string) []string {
+	var dependents []string
+	for _, other := range g.ActionIDs() {
+		for _, dep := range g.deps[other] {
+			if dep == id {
+				dependents = append(dependents, other)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// Validate reports missing dependencies, cycles and, if target is not empty,
+// a target that names no resource. It returns nil or a *ValidationError
+// holding *MissingDependencyError, *CycleError and *UnresolvedTargetError values.
+func (g *Graph) Validate(target string) error {
+	errs := append([]error(nil), g.missing...)
+	for _, cycle := range g.Cycles() {
+		errs = append(errs, &CycleError{Cycle: cycle})
+	}
+	if target != "" {
+		if _, ok := g.resources[target]; !ok {
+			errs = append(errs, &UnresolvedTargetError{TargetActionID: target})
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// Reachable returns target and every resource it depends on, transitively, sorted.
+func (g *Graph) Reachable(target string) []string {
+	reached := g.reach(target)
+	ids := make([]string, 0, len(reached))
+	for id := range reached {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Unreachable returns the resources that target does not depend on,
+// transitively, sorted. These resources never run for the workflow.
+func (g *Graph) Unreachable(target string) []string {
+	reached := g.reach(target)
+	var ids []string
+	for _, id := range g.ActionIDs() {
+		if !reached[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (g *Graph) reach(target string) map[string]bool {
+	reached := make(map[string]bool)
+	if _, ok := g.resources[target]; !ok {
+		return reached
+	}
+	stack := []string{target}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if reached[id] {
+			continue
+		}
+		reached[id] = true
+		stack = append(stack, g.deps[id]...)
+	}
+	return reached
+}
+
+// Cycles returns every cycle of the graph, as described for CycleError,
+// sorted by their first ActionID. A resource that requires itself is a cycle.
+func (g *Graph) Cycles() [][]string {
+	t := &tarjan{g: g, index: make(map[string]int), low: make(map[string]int), onStack: make(map[string]bool)}
+	for _, id := range g.ActionIDs() {
+		if _, visited := t.index[id]; !visited {
+			t.visit(id)
+		}
+	}
+	sort.Slice(t.cycles, func(i, j int) bool { return t.cycles[i][0] < t.cycles[j][0] })
+	return t.cycles
+}
+
+// Plan is an execution plan. Every resource in a level depends only on
+// resources in earlier levels, so the resources of one level can run in parallel.
+type Plan struct {
+	Levels [][]string
+}
+
+// Order returns the resources of the plan in a valid sequential order.
+func (p *Plan) Order() []string {
+	var order []string
+	for _, level := range p.Levels {
+		order = append(order, level...)
+	}
+	return order
+}
+
+// Plan returns the execution plan for target and its dependencies, or for
+// every resource if target is empty. Each resource is placed in the earliest
+// level after all of its dependencies, and each level is sorted. It returns
+// the Validate error if the graph is invalid.
+func (g *Graph) Plan(target string) (*Plan, error) {
+	if err := g.Validate(target); err != nil {
+		return nil, err
+	}
+
+	ids := g.ActionIDs()
+	if target != "" {
+		ids = g.Reachable(target)
+	}
+
+	depth := make(map[string]int, len(ids))
+	var level func(id string) int
+	level = func(id string) int {
+		if d, ok := depth[id]; ok {
+			return d
+		}
+		d := 0
+		for _, dep := range g.deps[id] {
+			d = max(d, level(dep)+1)
+		}
+		depth[id] = d
+		return d
+	}
+
+	plan := &Plan{}
+	for _, id := range ids {
+		d := level(id)
+		for len(plan.Levels) <= d {
+			plan.Levels = append(plan.Levels, nil)
+		}
+		plan.Levels[d] = append(plan.Levels[d], id)
+	}
+	return plan, nil
+}
+
+func sortedIDs(resources map[string]*resource.Resource) []string {
+	ids := make([]string, 0, len(resources))
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// tarjan finds strongly connected components with Tarjan's algorithm.
+type tarjan struct {
+	g       *Graph
+	next    int
+	index   map[string]int
+	low     map[string]int
+	stack   []string
+	onStack map[string]bool
+	cycles  [][]string
+}
+
+func (t *tarjan) visit(id string) {
+	t.index[id] = t.next
+	t.low[id] = t.next
+	t.next++
+	t.stack = append(t.stack, id)
+	t.onStack[id] = true
+
+	for _, dep := range t.g.deps[id] {
+		if _, visited := t.index[dep]; !visited {
+			t.visit(dep)
+			t.low[id] = min(t.low[id], t.low[dep])
+		} else if t.onStack[dep] {
+			t.low[id] = min(t.low[id], t.index[dep])
+		}
+	}
+
+	if t.low[id] != t.index[id] {
+		return
+	}
+	component := make(map[string]bool)
+	for {
+		top := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		t.onStack[top] = false
+		component[top] = true
+		if top == id {
+			break
+		}
+	}
+	if len(component) > 1 || t.g.requires(id, id) {
+		t.cycles = append(t.cycles, t.g.cyclePath(component))
+	}
+}
+
+func (g *Graph) requires(id, dep string) bool {
+	i := sort.SearchStrings(g.deps[id], dep)
+	return i < len(g.deps[id]) && g.deps[id][i] == dep
+}
+
+// cyclePath returns a cycle through the members of a strongly connected
+// component, starting and ending at its smallest ActionID.
+func (g *Graph) cyclePath(component map[string]bool) []string {
+	start := ""
+	for id := range component {
+		if start == "" || id < start {
+			start = id
+		}
+	}
+
+	// Breadth-first search for the shortest way back to start within the component.
+	prev := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, dep := range g.deps[id] {
+			if !component[dep] {
+				continue
+			}
+			if dep == start {
+				path := []string{start}
+				for at := id; at != start; at = prev[at] {
+					path = append(path, at)
+				}
+				// path is start followed by the members in reverse order.
+				for i, j := 1, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return append(path, start)
+			}
+			if _, seen := prev[dep]; !seen {
+				prev[dep] = id
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return []string{start, start}
+}
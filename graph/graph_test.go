@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kdeps/schema/gen/resource"
+)
+
+func resources(requires map[string][]string) map[string]*resource.Resource {
+	m := make(map[string]*resource.Resource, len(requires))
+	for id, deps := range requires {
+		r := &resource.Resource{ActionID: id}
+		if deps != nil {
+			deps := deps
+			r.Requires = &deps
+		}
+		m[id] = r
+	}
+	return m
+}
+
+func TestPlan(t *testing.T) {
+	g := New(resources(map[string][]string{
+		"fetch":    nil,
+		"search":   nil,
+		"parse":    {"fetch"},
+		"llm":      {"parse", "search", "parse"},
+		"response": {"llm", "fetch"},
+		"unused":   {"fetch"},
+	}))
+
+	if err := g.Validate("response"); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	plan, err := g.Plan("response")
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	want := [][]string{{"fetch", "search"}, {"parse"}, {"llm"}, {"response"}}
+	if !reflect.DeepEqual(plan.Levels, want) {
+		t.Errorf("Expected levels %v, got %v", want, plan.Levels)
+	}
+	if order := plan.Order(); !reflect.DeepEqual(order, []string{"fetch", "search", "parse", "llm", "response"}) {
+		t.Errorf("Unexpected order %v", order)
+	}
+
+	all, err := g.Plan("")
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if !reflect.DeepEqual(all.Levels[1], []string{"parse", "unused"}) {
+		t.Errorf("Expected unused in the full plan, got %v", all.Levels)
+	}
+
+	if got := g.Unreachable("response"); !reflect.DeepEqual(got, []string{"unused"}) {
+		t.Errorf("Expected unused to be unreachable, got %v", got)
+	}
+	if got := g.Dependencies("llm"); !reflect.DeepEqual(got, []string{"parse", "search"}) {
+		t.Errorf("Unexpected dependencies %v", got)
+	}
+	if got := g.Dependents("fetch"); !reflect.DeepEqual(got, []string{"parse", "response", "unused"}) {
+		t.Errorf("Unexpected dependents %v", got)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	g := New(resources(map[string][]string{
+		"a":    {"b"},
+		"b":    {"c"},
+		"c":    {"a"},
+		"self": {"self"},
+		"d":    {"ghost"},
+	}))
+
+	err := g.Validate("missing")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	if len(validationErr.Errors) != 4 {
+		t.Fatalf("Expected 4 errors, got %v", err)
+	}
+
+	var missing *MissingDependencyError
+	if !errors.As(err, &missing) || missing.ActionID != "d" || missing.Dependency != "ghost" {
+		t.Errorf("Unexpected missing dependency error %+v", missing)
+	}
+	var target *UnresolvedTargetError
+	if !errors.As(err, &target) || target.TargetActionID != "missing" {
+		t.Errorf("Unexpected target error %+v", target)
+	}
+
+	cycles := g.Cycles()
+	want := [][]string{{"a", "b", "c", "a"}, {"self", "self"}}
+	if !reflect.DeepEqual(cycles, want) {
+		t.Errorf("Expected cycles %v, got %v", want, cycles)
+	}
+
+	if _, err := g.Plan("a"); !errors.As(err, &validationErr) {
+		t.Errorf("Expected Plan to fail validation, got %v", err)
+	}
+}
+
+func TestValidateWithoutTarget(t *testing.T) {
+	g := New(resources(map[string][]string{"a": nil}))
+	if err := g.Validate(""); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if got := g.Unreachable("missing"); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Expected everything unreachable from an unknown target, got %v", got)
+	}
+}
@@ -0,0 +1,250 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/validate"
+)
+
+// ErrCredentialsWildcard is returned by CORS for a configuration that allows
+// credentials from any origin, which browsers refuse and which would expose
+// credentialed responses to every site.
+var ErrCredentialsWildcard = errors.New(`AllowCredentials cannot be combined with the "*" origin`)
+
+// cors is a CORSConfig prepared for matching requests.
+type cors struct {
+	anyOrigin   bool
+	origins     []originPattern
+	methods     []string
+	headers     map[string]bool
+	allowList   string
+	expose      string
+	credentials bool
+	maxAge      string
+}
+
+// originPattern is an entry of CORSConfig.AllowOrigins. A host starting with
+// "*." matches the subdomains of the rest of the host, at any depth.
+type originPattern struct {
+	scheme, host, port string
+	subdomains         bool
+}
+
+// CORS returns a middleware applying cfg to the requests of a route.
+// methods are the Methods of the route, allowed when cfg.AllowMethods is
+// unset. If cfg.EnableCORS is false, the middleware returns its handler
+// unchanged.
+//
+// Preflight requests, OPTIONS requests with an Access-Control-Request-Method
+// header, are answered by the middleware: 204 when the origin, method and
+// headers are allowed, and 403 otherwise. Other requests reach the handler,
+// with the CORS headers set when their origin is allowed.
+//
+// AllowOrigins entries are "*", an origin such as "https://example.com", or
+// a subdomain pattern such as "https://*.example.com". Allowing credentials
+// with the "*" origin is an error.
+func CORS(cfg apiserver.CORSConfig, methods []string) (func(http.Handler) http.Handler, error) {
+	if !cfg.EnableCORS {
+		return func(next http.Handler) http.Handler { return next }, nil
+	}
+	c, err := newCORS(cfg, methods)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure CORS: %w", err)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.serve(w, r, next)
+		})
+	}, nil
+}
+
+func newCORS(cfg apiserver.CORSConfig, methods []string) (*cors, error) {
+	c := &cors{headers: make(map[string]bool), credentials: cfg.AllowCredentials}
+	if cfg.AllowOrigins != nil {
+		for _, origin := range *cfg.AllowOrigins {
+			if origin == "*" {
+				c.anyOrigin = true
+				continue
+			}
+			p, err := parseOriginPattern(origin)
+			if err != nil {
+				return nil, err
+			}
+			c.origins = append(c.origins, p)
+		}
+	}
+	if c.anyOrigin && c.credentials {
+		return nil, ErrCredentialsWildcard
+	}
+
+	if cfg.AllowMethods != nil {
+		methods = *cfg.AllowMethods
+	}
+	for _, m := range methods {
+		if err := validate.CORSMethod(m); err != nil {
+			return nil, err
+		}
+		c.methods = appendUnique(c.methods, strings.ToUpper(m))
+	}
+
+	if cfg.AllowHeaders != nil {
+		var names []string
+		for _, h := range *cfg.AllowHeaders {
+			name := http.CanonicalHeaderKey(strings.TrimSpace(h))
+			if name == "" {
+				return nil, errors.New("empty header in AllowHeaders")
+			}
+			if !c.headers[name] {
+				c.headers[name] = true
+				names = append(names, name)
+			}
+		}
+		c.allowList = strings.Join(names, ", ")
+	}
+	if cfg.ExposeHeaders != nil {
+		c.expose = strings.Join(*cfg.ExposeHeaders, ", ")
+	}
+
+	maxAge := cfg.MaxAge.GoDuration()
+	if maxAge < 0 {
+		return nil, fmt.Errorf("negative MaxAge %v", maxAge)
+	}
+	if maxAge > 0 {
+		c.maxAge = strconv.FormatInt(int64(maxAge/time.Second), 10)
+	}
+	return c, nil
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, e := range list {
+		if e == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func parseOriginPattern(origin string) (originPattern, error) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return originPattern{}, fmt.Errorf("invalid origin %q", origin)
+	}
+	p := originPattern{scheme: strings.ToLower(u.Scheme), host: strings.ToLower(u.Hostname()), port: u.Port()}
+	if rest, ok := strings.CutPrefix(p.host, "*."); ok {
+		p.host, p.subdomains = rest, true
+	}
+	if p.host == "" || strings.Contains(p.host, "*") {
+		return originPattern{}, fmt.Errorf("invalid origin %q: only a leading \"*.\" label is supported", origin)
+	}
+	return p, nil
+}
+
+func (p originPattern) match(scheme, host, port string) bool {
+	if scheme != p.scheme || port != p.port {
+		return false
+	}
+	if p.subdomains {
+		return strings.HasSuffix(host, "."+p.host)
+	}
+	return host == p.host
+}
+
+// allowOrigin reports whether the Origin header value is allowed.
+func (c *cors) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	scheme, host, port := strings.ToLower(u.Scheme), strings.ToLower(u.Hostname()), u.Port()
+	for _, p := range c.origins {
+		if p.match(scheme, host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cors) allowMethod(method string) bool {
+	for _, m := range c.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cors) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	header := w.Header()
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	header.Add("Vary", "Origin")
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+	if origin == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+	if !c.allowOrigin(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	if !preflight {
+		c.setOrigin(header, origin)
+		if c.expose != "" {
+			header.Set("Access-Control-Expose-Headers", c.expose)
+		}
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !c.allowMethod(method) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, h := range strings.Split(value, ",") {
+			if h = strings.TrimSpace(h); h != "" && !c.headers[http.CanonicalHeaderKey(h)] {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+	}
+	c.setOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+	if c.allowList != "" {
+		header.Set("Access-Control-Allow-Headers", c.allowList)
+	}
+	if c.maxAge != "" {
+		header.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *cors) setOrigin(header http.Header, origin string) {
+	if c.anyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
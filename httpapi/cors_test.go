@@ -0,0 +1,162 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/defaults"
+	"github.com/kdeps/schema/gen/api_server"
+)
+
+func list(s ...string) *[]string {
+	return &s
+}
+
+func corsHandler(t *testing.T, cfg apiserver.CORSConfig, methods ...string) http.Handler {
+	t.Helper()
+	mw, err := CORS(cfg, methods)
+	if err != nil {
+		t.Fatalf("Failed to configure CORS: %v", err)
+	}
+	return mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+}
+
+func serve(h http.Handler, method, origin string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Add(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCORSDisabled(t *testing.T) {
+	cfg := defaults.NewCORSConfig()
+	cfg.AllowOrigins = list("*")
+	w := serve(corsHandler(t, cfg, "GET"), http.MethodGet, "https://example.com")
+	if w.Code != http.StatusTeapot || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected a disabled CORS middleware, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	cfg := defaults.NewCORSConfig()
+	cfg.EnableCORS = true
+	cfg.AllowOrigins = list("https://example.com", "https://*.kdeps.com")
+	cfg.AllowHeaders = list("content-type", "X-Token")
+	h := corsHandler(t, cfg, "GET", "post")
+
+	w := serve(h, http.MethodOptions, "https://api.eu.kdeps.com",
+		"Access-Control-Request-Method", "POST",
+		"Access-Control-Request-Headers", "Content-Type, x-token")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", w.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://api.eu.kdeps.com",
+		"Access-Control-Allow-Methods":     "GET, POST",
+		"Access-Control-Allow-Headers":     "Content-Type, X-Token",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "43200",
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	for name, header := range map[string][]string{
+		"method": {"Access-Control-Request-Method", "DELETE"},
+		"header": {"Access-Control-Request-Method", "GET", "Access-Control-Request-Headers", "X-Other"},
+	} {
+		if w := serve(h, http.MethodOptions, "https://example.com", header...); w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", name, w.Code)
+		}
+	}
+	for _, origin := range []string{"https://kdeps.com", "http://api.kdeps.com", "https://example.com:8443", "https://evil.com"} {
+		if w := serve(h, http.MethodOptions, origin, "Access-Control-Request-Method", "GET"); w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", origin, w.Code)
+		}
+	}
+}
+
+func TestCORSRequest(t *testing.T) {
+	cfg := defaults.NewCORSConfig()
+	cfg.EnableCORS = true
+	cfg.AllowOrigins = list("*")
+	cfg.AllowCredentials = false
+	cfg.AllowMethods = list("GET")
+	cfg.ExposeHeaders = list("X-Request-ID")
+	cfg.MaxAge = pkl.Duration{}
+	h := corsHandler(t, cfg, "POST")
+
+	w := serve(h, http.MethodGet, "https://example.com")
+	if w.Code != http.StatusTeapot {
+		t.Fatalf("Expected the handler to run, got %d", w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "" || w.Header().Get("Vary") != "Origin" {
+		t.Errorf("Unexpected headers %v", w.Header())
+	}
+
+	w = serve(h, http.MethodOptions, "https://example.com", "Access-Control-Request-Method", "GET")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Methods") != "GET" || w.Header().Get("Access-Control-Max-Age") != "" {
+		t.Errorf("Unexpected preflight %d %v", w.Code, w.Header())
+	}
+	// AllowMethods replaces the methods of the route.
+	w = serve(h, http.MethodOptions, "https://example.com", "Access-Control-Request-Method", "POST")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", w.Code)
+	}
+	// Requests without an Origin, and plain OPTIONS requests, are not CORS.
+	if w := serve(h, http.MethodOptions, ""); w.Code != http.StatusTeapot || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Unexpected response %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	cfg := defaults.NewCORSConfig()
+	cfg.EnableCORS = true
+	w := serve(corsHandler(t, cfg, "GET"), http.MethodGet, "https://example.com")
+	if w.Code != http.StatusTeapot || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*apiserver.CORSConfig)
+	}{
+		{"credentials with *", func(c *apiserver.CORSConfig) { c.AllowOrigins = list("*") }},
+		{"method", func(c *apiserver.CORSConfig) { c.AllowMethods = list("TRACE") }},
+		{"origin path", func(c *apiserver.CORSConfig) { c.AllowOrigins = list("https://example.com/api") }},
+		{"origin scheme", func(c *apiserver.CORSConfig) { c.AllowOrigins = list("example.com") }},
+		{"inner wildcard", func(c *apiserver.CORSConfig) { c.AllowOrigins = list("https://api.*.com") }},
+		{"max age", func(c *apiserver.CORSConfig) { c.MaxAge = pkl.Duration{Value: -1, Unit: pkl.Second} }},
+	}
+	for _, tt := range tests {
+		cfg := defaults.NewCORSConfig()
+		cfg.EnableCORS = true
+		tt.modify(&cfg)
+		if _, err := CORS(cfg, []string{"GET"}); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	cfg := defaults.NewCORSConfig()
+	cfg.EnableCORS = true
+	cfg.AllowOrigins = list("*")
+	if _, err := CORS(cfg, nil); !errors.Is(err, ErrCredentialsWildcard) {
+		t.Errorf("Expected ErrCredentialsWildcard, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+// Package httpapi serves the API of a kdeps agent over net/http.
+//
+// FromHTTPRequest turns an *http.Request into the APIServerRequest the
+// resources of an agent read, and WriteResponse writes the APIServerResponse
+// they produce, negotiating JSON, YAML or XML from the Accept header. CORS
+// applies the CORSConfig of the API server to a route.
+package httpapi
@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const plistHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+`
+
+// jsonToPlist converts a JSON document to an XML property list, as Pkl's
+// PListRenderer writes it. Object keys keep their order. Null object members
+// are omitted, and null array elements are an error, since property lists
+// have no null.
+func jsonToPlist(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	buf.WriteString(plistHeader)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if err := writePlist(&buf, dec, tok, ""); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after the value")
+	}
+	buf.WriteString("</plist>\n")
+	return buf.Bytes(), nil
+}
+
+// writePlist writes the value starting with tok at indent.
+func writePlist(buf *bytes.Buffer, dec *json.Decoder, tok json.Token, indent string) error {
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			return writePlistDict(buf, dec, indent)
+		}
+		return writePlistArray(buf, dec, indent)
+	case nil:
+		return errors.New("cannot render null in a property list")
+	case bool:
+		fmt.Fprintf(buf, "%s<%t/>\n", indent, t)
+	case json.Number:
+		if strings.ContainsAny(t.String(), ".eE") {
+			fmt.Fprintf(buf, "%s<real>%s</real>\n", indent, t)
+		} else {
+			fmt.Fprintf(buf, "%s<integer>%s</integer>\n", indent, t)
+		}
+	case string:
+		buf.WriteString(indent + "<string>")
+		xml.EscapeText(buf, []byte(t))
+		buf.WriteString("</string>\n")
+	}
+	return nil
+}
+
+func writePlistDict(buf *bytes.Buffer, dec *json.Decoder, indent string) error {
+	var body bytes.Buffer
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		value, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			continue
+		}
+		body.WriteString(indent + "  <key>")
+		xml.EscapeText(&body, []byte(key.(string)))
+		body.WriteString("</key>\n")
+		if err := writePlist(&body, dec, value, indent+"  "); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	if body.Len() == 0 {
+		buf.WriteString(indent + "<dict/>\n")
+		return nil
+	}
+	buf.WriteString(indent + "<dict>\n")
+	buf.Write(body.Bytes())
+	buf.WriteString(indent + "</dict>\n")
+	return nil
+}
+
+func writePlistArray(buf *bytes.Buffer, dec *json.Decoder, indent string) error {
+	var body bytes.Buffer
+	for i := 0; dec.More(); i++ {
+		value, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := writePlist(&body, dec, value, indent+"  "); err != nil {
+			return fmt.Errorf("[%d]: %w", i, err)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	if body.Len() == 0 {
+		buf.WriteString(indent + "<array/>\n")
+		return nil
+	}
+	buf.WriteString(indent + "<array>\n")
+	buf.Write(body.Bytes())
+	buf.WriteString(indent + "</array>\n")
+	return nil
+}
@@ -0,0 +1,323 @@
+package httpapi
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kdeps/schema/clientip"
+	"github.com/kdeps/schema/gen/api_server_request"
+	"github.com/kdeps/schema/validate"
+)
+
+// Default limits of FromHTTPRequest.
+const (
+	DefaultMaxBodyBytes        = 10 << 20
+	DefaultMaxUploadBytes      = 32 << 20
+	DefaultMaxUploadFiles      = 32
+	DefaultMaxTotalUploadBytes = 128 << 20
+)
+
+var (
+	// ErrBodyTooLarge is returned when the request body, or the form values
+	// of a multipart request, exceed Options.MaxBodyBytes.
+	ErrBodyTooLarge = errors.New("request body too large")
+	// ErrUploadTooLarge is returned when an uploaded file exceeds
+	// Options.MaxUploadBytes, or the uploaded files together exceed
+	// Options.MaxTotalUploadBytes.
+	ErrUploadTooLarge = errors.New("uploaded file too large")
+	// ErrTooManyUploads is returned when a request has more files than
+	// Options.MaxUploadFiles.
+	ErrTooManyUploads = errors.New("too many uploaded files")
+	// ErrInvalidID is returned when Options.NewID returns an ID that cannot
+	// name the upload directory of the request.
+	ErrInvalidID = errors.New("invalid request ID")
+)
+
+// Options configures FromHTTPRequest. The zero value is usable.
+type Options struct {
+	// UploadDir is the directory uploaded files are saved under, in a
+	// subdirectory named after the request ID. It defaults to os.TempDir().
+	UploadDir string
+	// MaxBodyBytes caps the request body, or the form values of a multipart
+	// request. It defaults to DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+	// MaxUploadBytes caps each uploaded file. It defaults to
+	// DefaultMaxUploadBytes.
+	MaxUploadBytes int64
+	// MaxUploadFiles caps the number of uploaded files. It defaults to
+	// DefaultMaxUploadFiles.
+	MaxUploadFiles int
+	// MaxTotalUploadBytes caps the uploaded files together. It defaults to
+	// DefaultMaxTotalUploadBytes.
+	MaxTotalUploadBytes int64
+	// ClientIP resolves the IP of the client behind trusted proxies, as
+	// built by clientip.FromAPIServer. If nil, every proxy is trusted.
+	ClientIP *clientip.Resolver
+	// NewID returns the ID of a request. It defaults to a random UUID. The
+	// ID must not be empty or contain a path separator or "..".
+	NewID func() string
+}
+
+// FromHTTPRequest returns the APIServerRequest of r, in the form the
+// functions of APIServerRequest.pkl expect:
+//
+//   - Data is the base64-encoded body, or empty for a multipart request
+//   - Params holds the base64-encoded query parameters, and the form values
+//     of a multipart request; repeated values are joined with ","
+//   - Headers holds the base64-encoded headers under their canonical names;
+//     repeated values are joined with ", "
+//   - Files maps each multipart file field to the saved file and its sniffed
+//     MIME type; further files in the same field are keyed "field[1]",
+//     "field[2]", and so on
+//
+// Data, Params, Headers and Files are never nil. Uploaded files are saved
+// under Options.UploadDir and must be removed by the caller, for example with
+// RemoveUploads.
+func FromHTTPRequest(r *http.Request, opts Options) (apiserverrequest.APIServerRequestImpl, error) {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if opts.MaxUploadBytes <= 0 {
+		opts.MaxUploadBytes = DefaultMaxUploadBytes
+	}
+	if opts.MaxUploadFiles <= 0 {
+		opts.MaxUploadFiles = DefaultMaxUploadFiles
+	}
+	if opts.MaxTotalUploadBytes <= 0 {
+		opts.MaxTotalUploadBytes = DefaultMaxTotalUploadBytes
+	}
+	if opts.UploadDir == "" {
+		opts.UploadDir = os.TempDir()
+	}
+	if opts.NewID == nil {
+		opts.NewID = newUUID
+	}
+
+	method := strings.ToUpper(r.Method)
+	if err := validate.RequestMethod(method); err != nil {
+		return apiserverrequest.APIServerRequestImpl{}, fmt.Errorf("failed to read request: %w", err)
+	}
+	id := opts.NewID()
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return apiserverrequest.APIServerRequestImpl{}, fmt.Errorf("failed to read request: %w: %q", ErrInvalidID, id)
+	}
+
+	data := ""
+	params := make(map[string]string)
+	headers := make(map[string]string, len(r.Header))
+	files := make(map[string]apiserverrequest.APIServerRequestUploads)
+	req := apiserverrequest.APIServerRequestImpl{
+		Path:    r.URL.Path,
+		IP:      opts.ClientIP.ClientIP(r),
+		ID:      id,
+		Method:  method,
+		Data:    &data,
+		Params:  &params,
+		Headers: &headers,
+		Files:   &files,
+	}
+
+	for name, values := range r.URL.Query() {
+		params[name] = encode(strings.Join(values, ","))
+	}
+	for name, values := range r.Header {
+		headers[name] = encode(strings.Join(values, ", "))
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return req, nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return req, fmt.Errorf("failed to read request: %w", err)
+		}
+		if err := readMultipart(mr, &req, opts); err != nil {
+			RemoveUploads(req)
+			os.Remove(filepath.Join(opts.UploadDir, req.ID))
+			return req, fmt.Errorf("failed to read request: %w", err)
+		}
+		return req, nil
+	}
+
+	body, err := readLimited(r.Body, opts.MaxBodyBytes, ErrBodyTooLarge)
+	if err != nil {
+		return req, fmt.Errorf("failed to read request: %w", err)
+	}
+	data = base64.StdEncoding.EncodeToString(body)
+	return req, nil
+}
+
+func encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// readMultipart stores the form values of mr in req.Params and saves its
+// files under opts.UploadDir.
+func readMultipart(mr *multipart.Reader, req *apiserverrequest.APIServerRequestImpl, opts Options) error {
+	params, files := *req.Params, *req.Files
+	values := make(map[string][]string)
+	formBytes := opts.MaxBodyBytes
+	dir := filepath.Join(opts.UploadDir, req.ID)
+	counts := make(map[string]int)
+	uploadBytes := opts.MaxTotalUploadBytes
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := part.FormName()
+		if name == "" {
+			part.Close()
+			continue
+		}
+		if part.FileName() == "" {
+			value, err := readLimited(part, formBytes, ErrBodyTooLarge)
+			part.Close()
+			if err != nil {
+				return err
+			}
+			formBytes -= int64(len(value))
+			values[name] = append(values[name], string(value))
+			continue
+		}
+
+		if len(files) >= opts.MaxUploadFiles {
+			part.Close()
+			return ErrTooManyUploads
+		}
+		key := name
+		if n := counts[name]; n > 0 {
+			key = name + "[" + strconv.Itoa(n) + "]"
+		}
+		counts[name]++
+		upload, size, err := saveUpload(part, dir, min(opts.MaxUploadBytes, uploadBytes))
+		part.Close()
+		if err != nil {
+			return err
+		}
+		uploadBytes -= size
+		files[key] = upload
+	}
+
+	for name, v := range values {
+		if query, ok := params[name]; ok {
+			decoded, _ := base64.StdEncoding.DecodeString(query)
+			v = append([]string{string(decoded)}, v...)
+		}
+		params[name] = encode(strings.Join(v, ","))
+	}
+	return nil
+}
+
+// saveUpload writes the file part to dir, sniffs its MIME type and returns
+// the size of the file.
+func saveUpload(part *multipart.Part, dir string, limit int64) (_ apiserverrequest.APIServerRequestUploads, _ int64, err error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return apiserverrequest.APIServerRequestUploads{}, 0, err
+	}
+	name := filepath.Base(filepath.Clean("/" + strings.ReplaceAll(part.FileName(), `\`, "/")))
+	if name == "/" || name == "." {
+		name = "upload"
+	}
+	f, err := os.CreateTemp(dir, "*-"+name)
+	if err != nil {
+		return apiserverrequest.APIServerRequestUploads{}, 0, err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(part, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return apiserverrequest.APIServerRequestUploads{}, 0, err
+	}
+	head = head[:n]
+	written, err := io.Copy(f, io.MultiReader(bytes.NewReader(head), io.LimitReader(part, limit-int64(n)+1)))
+	if err != nil {
+		return apiserverrequest.APIServerRequestUploads{}, 0, err
+	}
+	if written > limit {
+		return apiserverrequest.APIServerRequestUploads{}, 0, fmt.Errorf("%w: %s", ErrUploadTooLarge, part.FileName())
+	}
+	return apiserverrequest.APIServerRequestUploads{
+		Filepath: f.Name(),
+		Filetype: filetype(head, part.Header.Get("Content-Type")),
+	}, written, nil
+}
+
+// filetype returns the MIME type of a file starting with head, without
+// parameters. The declared type is used when sniffing finds nothing better
+// than application/octet-stream.
+func filetype(head []byte, declared string) string {
+	sniffed := http.DetectContentType(head)
+	if sniffed == "application/octet-stream" && declared != "" {
+		sniffed = declared
+	}
+	if mediaType, _, err := mime.ParseMediaType(sniffed); err == nil {
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+func readLimited(r io.Reader, limit int64, tooLarge error) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
+// RemoveUploads removes the files saved for req by FromHTTPRequest, and the
+// directories holding them.
+func RemoveUploads(req apiserverrequest.APIServerRequestImpl) error {
+	if req.Files == nil {
+		return nil
+	}
+	dirs := make(map[string]bool)
+	var errs []error
+	for _, upload := range *req.Files {
+		if err := os.Remove(upload.Filepath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		dirs[filepath.Dir(upload.Filepath)] = true
+	}
+	for dir := range dirs {
+		// Only succeeds once the directory is empty.
+		os.Remove(dir)
+	}
+	return errors.Join(errs...)
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
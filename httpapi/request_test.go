@@ -0,0 +1,254 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kdeps/schema/clientip"
+)
+
+func decode(t *testing.T, s string) string {
+	t.Helper()
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Failed to decode %q: %v", s, err)
+	}
+	return string(b)
+}
+
+func TestFromHTTPRequest(t *testing.T) {
+	r := httptest.NewRequest("post", "/api/v1/whois?q=kdeps&tag=a&tag=b", strings.NewReader(`{"name":"kdeps"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Add("X-Tag", "a")
+	r.Header.Add("X-Tag", "b")
+
+	req, err := FromHTTPRequest(r, Options{NewID: func() string { return "id" }})
+	if err != nil {
+		t.Fatalf("Failed to read request: %v", err)
+	}
+	if req.Path != "/api/v1/whois" || req.Method != "POST" || req.ID != "id" || req.IP != "192.0.2.1" {
+		t.Errorf("Unexpected request %+v", req)
+	}
+	if got := decode(t, *req.Data); got != `{"name":"kdeps"}` {
+		t.Errorf("Data = %q", got)
+	}
+	params := *req.Params
+	if decode(t, params["q"]) != "kdeps" || decode(t, params["tag"]) != "a,b" {
+		t.Errorf("Unexpected params %v", params)
+	}
+	headers := *req.Headers
+	if decode(t, headers["X-Tag"]) != "a, b" || decode(t, headers["Content-Type"]) != "application/json" {
+		t.Errorf("Unexpected headers %v", headers)
+	}
+	if req.Files == nil || len(*req.Files) != 0 {
+		t.Errorf("Expected no files, got %v", req.Files)
+	}
+}
+
+func TestFromHTTPRequestEmpty(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	req, err := FromHTTPRequest(r, Options{})
+	if err != nil {
+		t.Fatalf("Failed to read request: %v", err)
+	}
+	if req.Data == nil || *req.Data != "" || req.Params == nil || req.Headers == nil || req.Files == nil {
+		t.Errorf("Expected empty non-nil fields, got %+v", req)
+	}
+	if len(req.ID) != 36 {
+		t.Errorf("Expected a UUID, got %q", req.ID)
+	}
+}
+
+func TestFromHTTPRequestMethod(t *testing.T) {
+	r := httptest.NewRequest("TRACE", "/", nil)
+	if _, err := FromHTTPRequest(r, Options{}); err == nil {
+		t.Error("Expected an error for TRACE")
+	}
+}
+
+func TestFromHTTPRequestBodyTooLarge(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789"))
+	if _, err := FromHTTPRequest(r, Options{MaxBodyBytes: 9}); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("Expected ErrBodyTooLarge, got %v", err)
+	}
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789"))
+	if _, err := FromHTTPRequest(r, Options{MaxBodyBytes: 10}); err != nil {
+		t.Errorf("Failed to read request: %v", err)
+	}
+}
+
+func TestFromHTTPRequestClientIP(t *testing.T) {
+	resolver, err := clientip.New(&[]string{"192.0.2.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-For", "198.51.100.7, 203.0.113.9")
+	for resolver, want := range map[*clientip.Resolver]string{nil: "198.51.100.7", resolver: "203.0.113.9"} {
+		req, err := FromHTTPRequest(r, Options{ClientIP: resolver})
+		if err != nil {
+			t.Fatalf("Failed to read request: %v", err)
+		}
+		if req.IP != want {
+			t.Errorf("IP = %q, want %q", req.IP, want)
+		}
+	}
+}
+
+type part struct {
+	field, filename, contentType, content string
+}
+
+func multipartRequest(t *testing.T, target string, parts ...part) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, p := range parts {
+		h := make(textproto.MIMEHeader)
+		disposition := `form-data; name="` + p.field + `"`
+		if p.filename != "" {
+			disposition += `; filename="` + p.filename + `"`
+		}
+		h.Set("Content-Disposition", disposition)
+		if p.contentType != "" {
+			h.Set("Content-Type", p.contentType)
+		}
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pw.Write([]byte(p.content))
+	}
+	w.Close()
+	r := httptest.NewRequest(http.MethodPost, target, &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	return r
+}
+
+func TestFromHTTPRequestMultipart(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 600)
+	r := multipartRequest(t, "/upload?note=q",
+		part{field: "note", content: "hello"},
+		part{field: "file", filename: "../../image.png", contentType: "application/octet-stream", content: png},
+		part{field: "file", filename: "data.bin", contentType: "application/x-custom; v=1", content: "\x00\x01"},
+		part{field: "doc", filename: `C:\docs\notes.txt`, content: "plain text"},
+	)
+	dir := t.TempDir()
+	req, err := FromHTTPRequest(r, Options{UploadDir: dir, NewID: func() string { return "id" }})
+	if err != nil {
+		t.Fatalf("Failed to read request: %v", err)
+	}
+
+	if *req.Data != "" {
+		t.Errorf("Expected no data for a multipart request, got %q", *req.Data)
+	}
+	if got := decode(t, (*req.Params)["note"]); got != "q,hello" {
+		t.Errorf("note = %q", got)
+	}
+
+	files := *req.Files
+	want := map[string]struct{ filetype, suffix, content string }{
+		"file":    {"image/png", "-image.png", png},
+		"file[1]": {"application/x-custom", "-data.bin", "\x00\x01"},
+		"doc":     {"text/plain", "-notes.txt", "plain text"},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("Unexpected files %v", files)
+	}
+	for key, w := range want {
+		upload := files[key]
+		if upload.Filetype != w.filetype {
+			t.Errorf("%s: Filetype = %q, want %q", key, upload.Filetype, w.filetype)
+		}
+		if filepath.Dir(upload.Filepath) != filepath.Join(dir, "id") || !strings.HasSuffix(upload.Filepath, w.suffix) {
+			t.Errorf("%s: unexpected Filepath %q", key, upload.Filepath)
+		}
+		if b, err := os.ReadFile(upload.Filepath); err != nil || string(b) != w.content {
+			t.Errorf("%s: unexpected content %q (%v)", key, b, err)
+		}
+	}
+
+	if err := RemoveUploads(req); err != nil {
+		t.Fatalf("Failed to remove uploads: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "id")); !os.IsNotExist(err) {
+		t.Errorf("Expected the upload directory to be removed, got %v", err)
+	}
+}
+
+func TestFromHTTPRequestUploadTooLarge(t *testing.T) {
+	r := multipartRequest(t, "/upload",
+		part{field: "a", filename: "a.txt", content: "small"},
+		part{field: "b", filename: "b.txt", content: strings.Repeat("x", 11)},
+	)
+	dir := t.TempDir()
+	_, err := FromHTTPRequest(r, Options{UploadDir: dir, MaxUploadBytes: 10, NewID: func() string { return "id" }})
+	if !errors.Is(err, ErrUploadTooLarge) {
+		t.Fatalf("Expected ErrUploadTooLarge, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "id")); !os.IsNotExist(err) {
+		t.Errorf("Expected uploads to be removed on error, got %v", err)
+	}
+}
+
+func TestFromHTTPRequestUploadLimits(t *testing.T) {
+	parts := []part{
+		{field: "a", filename: "a.txt", content: "12345"},
+		{field: "a", filename: "b.txt", content: "12345"},
+		{field: "c", filename: "c.txt", content: "12345"},
+	}
+	tests := []struct {
+		name string
+		opts Options
+		want error
+	}{
+		{"files", Options{MaxUploadFiles: 2}, ErrTooManyUploads},
+		{"total", Options{MaxTotalUploadBytes: 12}, ErrUploadTooLarge},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		tt.opts.UploadDir = dir
+		tt.opts.NewID = func() string { return "id" }
+		_, err := FromHTTPRequest(multipartRequest(t, "/upload", parts...), tt.opts)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "id")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected uploads to be removed on error, got %v", tt.name, err)
+		}
+	}
+
+	req, err := FromHTTPRequest(multipartRequest(t, "/upload", parts...), Options{
+		UploadDir: t.TempDir(), MaxUploadFiles: 3, MaxTotalUploadBytes: 15,
+	})
+	if err != nil {
+		t.Fatalf("FromHTTPRequest failed: %v", err)
+	}
+	defer RemoveUploads(req)
+	if len(*req.Files) != 3 {
+		t.Errorf("Expected 3 files, got %v", *req.Files)
+	}
+}
+
+func TestFromHTTPRequestInvalidID(t *testing.T) {
+	for _, id := range []string{"", "a/b", `a\b`, "..", "a..b"} {
+		dir := t.TempDir()
+		r := multipartRequest(t, "/upload", part{field: "a", filename: "a.txt", content: "a"})
+		_, err := FromHTTPRequest(r, Options{UploadDir: dir, NewID: func() string { return id }})
+		if !errors.Is(err, ErrInvalidID) {
+			t.Errorf("NewID %q: expected ErrInvalidID, got %v", id, err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("NewID %q: expected nothing to be written, got %v", id, entries)
+		}
+	}
+}
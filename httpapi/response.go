@@ -0,0 +1,229 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kdeps/schema/codec"
+	"github.com/kdeps/schema/gen/api_server_response"
+)
+
+// Response formats, as rendered by the JSONRenderDocument,
+// yamlRenderDocument and xmlRenderDocument functions of Document.pkl.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatXML  = "xml"
+)
+
+// contentTypes are the Content-Type of each format.
+var contentTypes = map[string]string{
+	FormatJSON: "application/json; charset=utf-8",
+	FormatYAML: "application/yaml; charset=utf-8",
+	FormatXML:  "application/xml; charset=utf-8",
+}
+
+// mediaTypes maps the media types of an Accept header to a format.
+var mediaTypes = map[string]string{
+	"application/json":   FormatJSON,
+	"text/json":          FormatJSON,
+	"application/yaml":   FormatYAML,
+	"application/x-yaml": FormatYAML,
+	"text/yaml":          FormatYAML,
+	"text/x-yaml":        FormatYAML,
+	"application/xml":    FormatXML,
+	"text/xml":           FormatXML,
+}
+
+// envelope is the body of a response: an APIServerResponse without the
+// Meta.Headers, which are sent as HTTP headers instead, and with the
+// Meta.Properties merged into the top level (see RenderResponse).
+type envelope struct {
+	Success  bool                                      `pkl:"Success"`
+	Meta     *meta                                     `pkl:"Meta"`
+	Response *apiserverresponse.APIServerResponseBlock `pkl:"Response"`
+	Errors   *[]apiserverresponse.APIServerErrorsBlock `pkl:"Errors"`
+}
+
+type meta struct {
+	RequestID *string `pkl:"RequestID"`
+}
+
+// reservedKeys are the top-level keys of envelope, which Meta.Properties
+// cannot override.
+var reservedKeys = map[string]bool{"Success": true, "Meta": true, "Response": true, "Errors": true}
+
+// Negotiate returns the response format preferred by the Accept header of r.
+// Media types are ranked by their q parameter, and then by their order in the
+// header. A missing header, a wildcard, or a header naming no supported type
+// selects JSON.
+func Negotiate(r *http.Request) string {
+	type candidate struct {
+		format string
+		q      float64
+	}
+	var candidates []candidate
+	for _, value := range r.Header.Values("Accept") {
+		for _, item := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if s, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(s, 64); err != nil {
+					continue
+				}
+			}
+			format, ok := mediaTypes[mediaType]
+			if mediaType == "*/*" || mediaType == "application/*" {
+				format, ok = FormatJSON, true
+			}
+			if ok && q > 0 {
+				candidates = append(candidates, candidate{format, q})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	if len(candidates) == 0 {
+		return FormatJSON
+	}
+	return candidates[0].format
+}
+
+// StatusCode returns the HTTP status of resp: the first error code that is a
+// valid HTTP status, or 200 for a successful response and 500 otherwise.
+func StatusCode(resp apiserverresponse.APIServerResponseImpl) int {
+	if resp.Errors != nil {
+		for _, e := range *resp.Errors {
+			if e.Code >= 100 && e.Code <= 599 {
+				return e.Code
+			}
+		}
+	}
+	if resp.Success {
+		return http.StatusOK
+	}
+	return http.StatusInternalServerError
+}
+
+// WriteResponse writes resp to w in the format negotiated from the Accept
+// header of r. The status code is StatusCode(resp), Meta.Headers are set on
+// w, and the body holds the Success, Meta, Response, Errors and
+// Meta.Properties of resp, as rendered by RenderResponse.
+// requestID is written as Meta.RequestID unless resp already has one.
+//
+// Meta.Headers cannot override the Content-Type of the negotiated format.
+func WriteResponse(w http.ResponseWriter, r *http.Request, resp apiserverresponse.APIServerResponseImpl, requestID string) error {
+	format := Negotiate(r)
+	body, err := RenderResponse(resp, requestID, format)
+	if err != nil {
+		return err
+	}
+
+	header := w.Header()
+	if resp.Meta != nil && resp.Meta.Headers != nil {
+		for name, value := range *resp.Meta.Headers {
+			header.Set(name, value)
+		}
+	}
+	header.Set("Content-Type", contentTypes[format])
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Add("Vary", "Accept")
+	w.WriteHeader(StatusCode(resp))
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
+
+// RenderResponse returns the body WriteResponse writes for resp in format.
+//
+// The body is an object holding Success, Meta with the RequestID, Response
+// and Errors, in that order, followed by the Meta.Properties of resp sorted
+// by name. A property named Success, Meta, Response or Errors collides with
+// the envelope and is dropped.
+func RenderResponse(resp apiserverresponse.APIServerResponseImpl, requestID, format string) ([]byte, error) {
+	env := envelope{Success: resp.Success, Response: resp.Response, Errors: resp.Errors}
+	if resp.Meta != nil && resp.Meta.RequestID != nil && *resp.Meta.RequestID != "" {
+		env.Meta = &meta{RequestID: resp.Meta.RequestID}
+	} else if requestID != "" {
+		env.Meta = &meta{RequestID: &requestID}
+	}
+	properties := make(map[string]string)
+	if resp.Meta != nil && resp.Meta.Properties != nil {
+		for name, value := range *resp.Meta.Properties {
+			if !reservedKeys[name] {
+				properties[name] = value
+			}
+		}
+	}
+
+	switch format {
+	case FormatJSON:
+		data, err := renderJSON(env, properties)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render response: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return nil, fmt.Errorf("failed to render response: %w", err)
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	case FormatYAML:
+		data, err := codec.MarshalYAML(env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render response: %w", err)
+		}
+		if len(properties) > 0 {
+			// Both are block mappings at the top level, so the properties
+			// simply continue the envelope.
+			extra, err := codec.MarshalYAML(properties)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render response: %w", err)
+			}
+			data = append(data, extra...)
+		}
+		return data, nil
+	case FormatXML:
+		data, err := renderJSON(env, properties)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render response: %w", err)
+		}
+		plist, err := jsonToPlist(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render response: %w", err)
+		}
+		return plist, nil
+	}
+	return nil, fmt.Errorf("failed to render response: unknown format %q", format)
+}
+
+// renderJSON returns the compact JSON object of env followed by properties.
+func renderJSON(env envelope, properties map[string]string) ([]byte, error) {
+	data, err := codec.MarshalJSON(env)
+	if err != nil {
+		return nil, err
+	}
+	if len(properties) == 0 {
+		return data, nil
+	}
+	extra, err := codec.MarshalJSON(properties)
+	if err != nil {
+		return nil, err
+	}
+	// Both are non-empty objects: join them into one.
+	data = bytes.TrimSuffix(bytes.TrimSpace(data), []byte("}"))
+	data = append(data, ',')
+	return append(data, bytes.TrimPrefix(bytes.TrimSpace(extra), []byte("{"))...), nil
+}
@@ -0,0 +1,238 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/gen/api_server_response"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept []string
+		want   string
+	}{
+		{nil, FormatJSON},
+		{[]string{"*/*"}, FormatJSON},
+		{[]string{"text/html"}, FormatJSON},
+		{[]string{"application/yaml"}, FormatYAML},
+		{[]string{"text/html, application/xml;q=0.9, */*;q=0.8"}, FormatXML},
+		{[]string{"application/json;q=0.5", "text/x-yaml"}, FormatYAML},
+		{[]string{"application/xml;q=0, application/x-yaml;q=0.1"}, FormatYAML},
+		{[]string{"application/json, application/yaml"}, FormatJSON},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, a := range tt.accept {
+			r.Header.Add("Accept", a)
+		}
+		if got := Negotiate(r); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	errs := func(codes ...int) *[]apiserverresponse.APIServerErrorsBlock {
+		var list []apiserverresponse.APIServerErrorsBlock
+		for _, c := range codes {
+			list = append(list, apiserverresponse.APIServerErrorsBlock{Code: c, Message: "failed"})
+		}
+		return &list
+	}
+	tests := []struct {
+		resp apiserverresponse.APIServerResponseImpl
+		want int
+	}{
+		{apiserverresponse.APIServerResponseImpl{Success: true}, http.StatusOK},
+		{apiserverresponse.APIServerResponseImpl{}, http.StatusInternalServerError},
+		{apiserverresponse.APIServerResponseImpl{Errors: errs(404, 500)}, http.StatusNotFound},
+		{apiserverresponse.APIServerResponseImpl{Errors: errs(42, 422)}, http.StatusUnprocessableEntity},
+		{apiserverresponse.APIServerResponseImpl{Errors: errs(42)}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := StatusCode(tt.resp); got != tt.want {
+			t.Errorf("StatusCode(%+v) = %d, want %d", tt.resp, got, tt.want)
+		}
+	}
+}
+
+func response() apiserverresponse.APIServerResponseImpl {
+	return apiserverresponse.APIServerResponseImpl{
+		Success: true,
+		Meta: &apiserverresponse.APIServerResponseMetaBlock{
+			Headers:    &map[string]string{"X-Agent": "demo", "Content-Type": "text/plain"},
+			Properties: &map[string]string{"model": "llama3.2"},
+		},
+		Response: &apiserverresponse.APIServerResponseBlock{
+			Data: []any{"ok", int64(2), 1.5, map[any]any{"a": true}, pkl.Object{Elements: []any{}}},
+		},
+	}
+}
+
+func TestWriteResponse(t *testing.T) {
+	tests := []struct {
+		accept, contentType, body string
+	}{
+		{"", "application/json; charset=utf-8", `{
+  "Success": true,
+  "Meta": {
+    "RequestID": "id"
+  },
+  "Response": {
+    "Data": [
+      "ok",
+      2,
+      1.5,
+      {
+        "a": true
+      },
+      []
+    ]
+  },
+  "model": "llama3.2"
+}
+`},
+		{"application/yaml", "application/yaml; charset=utf-8", `Success: true
+Meta:
+  RequestID: id
+Response:
+  Data:
+    - ok
+    - 2
+    - 1.5
+    - a: true
+    - []
+model: llama3.2
+`},
+		{"text/xml", "application/xml; charset=utf-8", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Success</key>
+  <true/>
+  <key>Meta</key>
+  <dict>
+    <key>RequestID</key>
+    <string>id</string>
+  </dict>
+  <key>Response</key>
+  <dict>
+    <key>Data</key>
+    <array>
+      <string>ok</string>
+      <integer>2</integer>
+      <real>1.5</real>
+      <dict>
+        <key>a</key>
+        <true/>
+      </dict>
+      <array/>
+    </array>
+  </dict>
+  <key>model</key>
+  <string>llama3.2</string>
+</dict>
+</plist>
+`},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		resp := response()
+		if err := WriteResponse(w, r, resp, "id"); err != nil {
+			t.Fatalf("Failed to write response: %v", err)
+		}
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d", tt.accept, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.accept, got, tt.contentType)
+		}
+		if got := w.Header().Get("X-Agent"); got != "demo" {
+			t.Errorf("%s: X-Agent = %q", tt.accept, got)
+		}
+		if got := w.Body.String(); got != tt.body {
+			t.Errorf("%s: unexpected body:\n%s\nwant\n%s", tt.accept, got, tt.body)
+		}
+		if resp.Meta.RequestID != nil {
+			t.Errorf("WriteResponse modified the response")
+		}
+	}
+}
+
+func TestWriteResponseErrors(t *testing.T) {
+	id := "given"
+	resp := apiserverresponse.APIServerResponseImpl{
+		Meta:   &apiserverresponse.APIServerResponseMetaBlock{RequestID: &id},
+		Errors: &[]apiserverresponse.APIServerErrorsBlock{{Code: 400, Message: `bad "input" <here>`}},
+	}
+	w := httptest.NewRecorder()
+	if err := WriteResponse(w, httptest.NewRequest(http.MethodPost, "/", nil), resp, "id"); err != nil {
+		t.Fatalf("Failed to write response: %v", err)
+	}
+	want := `{
+  "Success": false,
+  "Meta": {
+    "RequestID": "given"
+  },
+  "Errors": [
+    {
+      "Code": 400,
+      "Message": "bad \"input\" <here>"
+    }
+  ]
+}
+`
+	if w.Code != http.StatusBadRequest || w.Body.String() != want {
+		t.Errorf("Unexpected response %d:\n%s", w.Code, w.Body)
+	}
+}
+
+func TestRenderResponseProperties(t *testing.T) {
+	resp := apiserverresponse.APIServerResponseImpl{
+		Success: true,
+		Meta: &apiserverresponse.APIServerResponseMetaBlock{
+			Properties: &map[string]string{"Success": "no", "Meta": "x", "Response": "x", "Errors": "x", "b": "2", "a": "1"},
+		},
+	}
+	got, err := RenderResponse(resp, "", FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to render response: %v", err)
+	}
+	want := `{
+  "Success": true,
+  "a": "1",
+  "b": "2"
+}
+`
+	if string(got) != want {
+		t.Errorf("Unexpected body:\n%s\nwant\n%s", got, want)
+	}
+
+	got, err = RenderResponse(resp, "", FormatYAML)
+	if err != nil {
+		t.Fatalf("Failed to render response: %v", err)
+	}
+	if want := "Success: true\na: \"1\"\nb: \"2\"\n"; string(got) != want {
+		t.Errorf("Unexpected YAML body:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderResponseXMLNull(t *testing.T) {
+	resp := apiserverresponse.APIServerResponseImpl{
+		Success:  true,
+		Response: &apiserverresponse.APIServerResponseBlock{Data: []any{nil}},
+	}
+	if _, err := RenderResponse(resp, "", FormatXML); err == nil {
+		t.Error("Expected an error for a null element")
+	}
+	if _, err := RenderResponse(resp, "", "html"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,44 @@
+package pkldecl
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets/pklsyntax"
+)
+
+// ParseLiteral returns the value of a literal default such as `"127.0.0.1"`,
+// `3000`, `1.5`, `true` or `12.h`, as a string, int64, float64, bool or
+// pkl.Duration. It reports false for any other expression.
+func ParseLiteral(expr string) (any, bool) {
+	switch expr {
+	case "":
+		return nil, false
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	if expr[0] == '"' || expr[0] == '#' {
+		tokens, err := pklsyntax.Tokenize(expr)
+		if err != nil || len(tokens) != 1 || tokens[0].Kind != pklsyntax.String || !tokens[0].Constant {
+			return nil, false
+		}
+		return tokens[0].Value, true
+	}
+	if n, err := strconv.ParseInt(strings.ReplaceAll(expr, "_", ""), 0, 64); err == nil {
+		return n, true
+	}
+	if i := strings.LastIndexByte(expr, '.'); i > 0 {
+		if unit, err := pkl.ToDurationUnit(expr[i+1:]); err == nil {
+			if v, err := strconv.ParseFloat(expr[:i], 64); err == nil {
+				return pkl.Duration{Value: v, Unit: unit}, true
+			}
+		}
+	}
+	if f, err := strconv.ParseFloat(expr, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
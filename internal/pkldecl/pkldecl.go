@@ -0,0 +1,567 @@
+// Package pkldecl reads the declarations of the kdeps Pkl modules without
+// evaluating them: classes and their properties with type, default value and
+// doc comment, and typealiases. It lets Go code derive metadata the generated
+// types do not carry.
+//
+// It is not a full Pkl parser. The jsonschema tests cross-check its output
+// against an independent scan of deps/pkl, so a declaration it misreads
+// fails the build rather than silently dropping out of the schemas.
+package pkldecl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/kdeps/schema/assets"
+	"github.com/kdeps/schema/assets/pklsyntax"
+)
+
+// Module is a parsed Pkl module.
+type Module struct {
+	// File is the asset name, e.g. "APIServer.pkl".
+	File string
+	// Name is the declared module name, e.g. "org.kdeps.pkl.APIServer".
+	Name string
+	// GoPackage is the import path given by the @go.Package annotation.
+	GoPackage string
+	// Doc is the module doc comment.
+	Doc string
+	// Classes holds the classes by name. The module itself is the class
+	// with the empty name.
+	Classes map[string]*Class
+	// Aliases holds the typealiases by name.
+	Aliases map[string]*Alias
+}
+
+// ShortName returns the last segment of the module name, e.g. "APIServer".
+func (m *Module) ShortName() string {
+	return m.Name[strings.LastIndexByte(m.Name, '.')+1:]
+}
+
+// Class is a class declaration, or the properties declared at module level.
+type Class struct {
+	// Name is the class name, or empty for the module class.
+	Name string
+	// Doc is the class doc comment.
+	Doc string
+	// Properties are the properties in declaration order. Hidden and local
+	// properties are omitted.
+	Properties []*Property
+}
+
+// Property returns the property called name, or nil.
+func (c *Class) Property(name string) *Property {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Property is a typed property declaration.
+type Property struct {
+	Name string
+	Doc  string
+	Type *Type
+	// Default is the source of the default value on the declaration line,
+	// such as `"127.0.0.1"` or `60.s`, or empty if there is none.
+	Default string
+}
+
+// Alias is a typealias declaration.
+type Alias struct {
+	Name string
+	Doc  string
+	Type *Type
+}
+
+// Type is a Pkl type annotation.
+type Type struct {
+	// Name is the type name as written, e.g. "String", "Listing" or
+	// "APIServer.APIServerSettings". It is empty for string literal and
+	// union types.
+	Name string
+	// Args are the type arguments, e.g. the element type of a Listing.
+	Args []*Type
+	// Constraints are the sources of the type constraints, e.g.
+	// "isValidHTTPMethod" for String(isValidHTTPMethod).
+	Constraints []string
+	// Nullable reports a type followed by "?".
+	Nullable bool
+	// Literal is the value of a string literal type.
+	Literal string
+	// Members are the alternatives of a union type.
+	Members []*Type
+}
+
+// IsLiteral reports whether t is a string literal type.
+func (t *Type) IsLiteral() bool {
+	return t.Name == "" && t.Members == nil
+}
+
+// StringLiterals returns the values of a string literal type or of a union
+// of string literal types.
+func (t *Type) StringLiterals() ([]string, bool) {
+	if t.IsLiteral() {
+		return []string{t.Literal}, true
+	}
+	if t.Members == nil {
+		return nil, false
+	}
+	var values []string
+	for _, m := range t.Members {
+		if !m.IsLiteral() || m.Nullable {
+			return nil, false
+		}
+		values = append(values, m.Literal)
+	}
+	return values, true
+}
+
+func (t *Type) String() string {
+	var s string
+	switch {
+	case t.Members != nil:
+		parts := make([]string, len(t.Members))
+		for i, m := range t.Members {
+			parts[i] = m.String()
+		}
+		s = strings.Join(parts, "|")
+		if t.Nullable || len(t.Constraints) > 0 {
+			s = "(" + s + ")"
+		}
+	case t.IsLiteral():
+		s = fmt.Sprintf("%q", t.Literal)
+	default:
+		s = t.Name
+		if len(t.Args) > 0 {
+			args := make([]string, len(t.Args))
+			for i, a := range t.Args {
+				args[i] = a.String()
+			}
+			s += "<" + strings.Join(args, ", ") + ">"
+		}
+	}
+	if len(t.Constraints) > 0 {
+		s += "(" + strings.Join(t.Constraints, ", ") + ")"
+	}
+	if t.Nullable {
+		s += "?"
+	}
+	return s
+}
+
+// Modules is a set of parsed modules.
+type Modules []*Module
+
+// ByFile returns the module parsed from file, or nil.
+func (ms Modules) ByFile(file string) *Module {
+	for _, m := range ms {
+		if m.File == file {
+			return m
+		}
+	}
+	return nil
+}
+
+// ByGoPackage returns the module generated into pkgPath or, for the enum
+// subpackages such as gen/kdeps/gpu, into a parent of pkgPath.
+func (ms Modules) ByGoPackage(pkgPath string) *Module {
+	var best *Module
+	for _, m := range ms {
+		if m.GoPackage == "" || (pkgPath != m.GoPackage && !strings.HasPrefix(pkgPath, m.GoPackage+"/")) {
+			continue
+		}
+		if best == nil || len(m.GoPackage) > len(best.GoPackage) {
+			best = m
+		}
+	}
+	return best
+}
+
+// ClassOf returns the module and class a generated struct type was
+// generated from. The module class is generated as <Module> or <Module>Impl,
+// e.g. resource.Resource or workflow.WorkflowImpl.
+func (ms Modules) ClassOf(t reflect.Type) (*Module, *Class, error) {
+	m := ms.ByGoPackage(t.PkgPath())
+	if m == nil {
+		return nil, nil, fmt.Errorf("no Pkl module for %s", t)
+	}
+	name := t.Name()
+	if name == m.ShortName() || name == m.ShortName()+"Impl" {
+		name = ""
+	}
+	class := m.Classes[name]
+	if class == nil {
+		return nil, nil, fmt.Errorf("no Pkl class %s in %s", name, m.File)
+	}
+	return m, class, nil
+}
+
+var (
+	loadOnce    sync.Once
+	loadModules Modules
+	loadErr     error
+)
+
+// Load parses the embedded kdeps modules, the .pkl files at the top of the
+// assets. The result is cached and must not be modified.
+func Load() (Modules, error) {
+	loadOnce.Do(func() {
+		loadModules, loadErr = load()
+	})
+	return loadModules, loadErr
+}
+
+func load() (Modules, error) {
+	files, err := assets.ListPKLFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list modules: %w", err)
+	}
+	var modules Modules
+	for _, file := range files {
+		if strings.Contains(file, "/") || !strings.HasSuffix(file, ".pkl") {
+			continue
+		}
+		data, err := assets.GetPKLFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		m, err := Parse(file, string(data))
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
+
+// modifiers may precede a declaration.
+var modifiers = map[string]bool{
+	"abstract": true,
+	"const":    true,
+	"external": true,
+	"fixed":    true,
+	"hidden":   true,
+	"local":    true,
+	"open":     true,
+}
+
+type scope struct {
+	class *Class
+	depth int
+}
+
+// Parse parses the declarations of the module source src read from file.
+func Parse(file, src string) (*Module, error) {
+	tokens, err := pklsyntax.Tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	p := &parser{src: src, tokens: tokens}
+	m := &Module{
+		File:    file,
+		Classes: map[string]*Class{"": {}},
+		Aliases: make(map[string]*Alias),
+	}
+
+	var (
+		scopes = []scope{{class: m.Classes[""]}}
+		depth  int
+		doc    string
+	)
+	for i, tok := range tokens {
+		if tok.Doc != "" {
+			doc = tok.Doc
+		}
+		if tok.Kind == pklsyntax.Punct {
+			switch tok.Text {
+			case "{":
+				depth++
+			case "}":
+				if len(scopes) > 1 && scopes[len(scopes)-1].depth == depth {
+					scopes = scopes[:len(scopes)-1]
+				}
+				depth--
+			}
+		}
+		// Declarations start a line in the body of the module or a class.
+		if depth != scopes[len(scopes)-1].depth || (i > 0 && tokens[i-1].Pos.Line == tok.Pos.Line) {
+			continue
+		}
+		if tok.Kind == pklsyntax.Punct && tok.Text == "@" {
+			// An annotation between a doc comment and its declaration.
+			if pkg, ok := p.goPackage(i); ok {
+				m.GoPackage = pkg
+			}
+			continue
+		}
+		if tok.Kind != pklsyntax.Ident {
+			doc = ""
+			continue
+		}
+
+		j, hidden := i, false
+		for j < len(tokens) && tokens[j].Kind == pklsyntax.Ident && !tokens[j].Quoted && modifiers[tokens[j].Text] {
+			hidden = hidden || tokens[j].Text == "hidden" || tokens[j].Text == "local"
+			j++
+		}
+		declDoc := doc
+		doc = ""
+		switch p.text(j) {
+		case "module":
+			m.Doc = declDoc
+			m.Name = p.qualifiedName(j + 1)
+		case "class":
+			c := &Class{Name: p.text(j + 1), Doc: declDoc}
+			m.Classes[c.Name] = c
+			if p.bodyOnLine(j) {
+				scopes = append(scopes, scope{class: c, depth: depth + 1})
+			}
+		case "typealias":
+			if p.text(j+2) != "=" {
+				continue
+			}
+			t, _, err := p.parseType(j+3, p.typeEnd(j+3))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: typealias %s: %w", file, p.text(j+1), err)
+			}
+			m.Aliases[p.text(j+1)] = &Alias{Name: p.text(j + 1), Doc: declDoc, Type: t}
+		case "import", "amends", "extends", "function":
+		default:
+			if p.text(j+1) != ":" || hidden || j >= len(tokens) || tokens[j].Kind != pklsyntax.Ident {
+				continue
+			}
+			end := p.typeEnd(j + 2)
+			t, _, err := p.parseType(j+2, end)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: property %s: %w", file, p.text(j), err)
+			}
+			prop := &Property{Name: strings.Trim(tokens[j].Text, "`"), Doc: declDoc, Type: t}
+			if p.text(end) == "=" {
+				prop.Default = p.restOfLine(tokens[end].End)
+			}
+			class := scopes[len(scopes)-1].class
+			class.Properties = append(class.Properties, prop)
+		}
+	}
+	return m, nil
+}
+
+type parser struct {
+	src    string
+	tokens []pklsyntax.Token
+}
+
+func (p *parser) text(i int) string {
+	if i < len(p.tokens) {
+		return p.tokens[i].Text
+	}
+	return ""
+}
+
+func (p *parser) isPunct(i int, s string) bool {
+	return i < len(p.tokens) && p.tokens[i].Kind == pklsyntax.Punct && p.tokens[i].Text == s
+}
+
+// qualifiedName reads a dotted name starting at token i.
+func (p *parser) qualifiedName(i int) string {
+	name := p.text(i)
+	for p.isPunct(i+1, ".") && i+2 < len(p.tokens) && p.tokens[i+2].Kind == pklsyntax.Ident {
+		name += "." + p.text(i+2)
+		i += 2
+	}
+	return name
+}
+
+// bodyOnLine reports whether a "{" follows token i on the same line.
+func (p *parser) bodyOnLine(i int) bool {
+	line := p.tokens[i].Pos.Line
+	for j := i + 1; j < len(p.tokens) && p.tokens[j].Pos.Line == line; j++ {
+		if p.isPunct(j, "{") {
+			return true
+		}
+	}
+	return false
+}
+
+// goPackage reads `@go.Package { name = "<path>" }` at token i.
+func (p *parser) goPackage(i int) (string, bool) {
+	if p.text(i+1) != "go" || !p.isPunct(i+2, ".") || p.text(i+3) != "Package" || !p.isPunct(i+4, "{") {
+		return "", false
+	}
+	for j := i + 5; j+2 < len(p.tokens) && !p.isPunct(j, "}"); j++ {
+		if p.text(j) == "name" && p.isPunct(j+1, "=") && p.tokens[j+2].Kind == pklsyntax.String {
+			return p.tokens[j+2].Value, true
+		}
+	}
+	return "", false
+}
+
+// restOfLine returns the trimmed source from offset to the end of its line.
+func (p *parser) restOfLine(offset int) string {
+	end := strings.IndexByte(p.src[offset:], '\n')
+	if end < 0 {
+		end = len(p.src) - offset
+	}
+	return strings.TrimSpace(p.src[offset : offset+end])
+}
+
+// typeEnd returns the index of the token ending the type that starts at i:
+// a "=" or "{" outside brackets, or the first token of a later line once
+// all brackets are closed. Angle brackets only count outside constraints,
+// which may compare with < and >.
+func (p *parser) typeEnd(i int) int {
+	parens, angles := 0, 0
+	for j := i; j < len(p.tokens); j++ {
+		tok := p.tokens[j]
+		if parens == 0 && angles == 0 && j > i && tok.Pos.Line != p.tokens[j-1].Pos.Line {
+			return j
+		}
+		if tok.Kind != pklsyntax.Punct {
+			continue
+		}
+		switch tok.Text {
+		case "(":
+			parens++
+		case ")":
+			parens--
+		case "<":
+			if parens == 0 {
+				angles++
+			}
+		case ">":
+			if parens == 0 {
+				angles--
+			}
+		case "=", "{":
+			if parens == 0 && angles == 0 {
+				return j
+			}
+		}
+	}
+	return len(p.tokens)
+}
+
+// parseType parses the type in tokens [i, end) and returns the index after it.
+func (p *parser) parseType(i, end int) (*Type, int, error) {
+	first, i, err := p.parseNode(i, end)
+	if err != nil {
+		return nil, i, err
+	}
+	if !p.isPunct(i, "|") || i >= end {
+		return first, i, nil
+	}
+	union := &Type{Members: []*Type{first}}
+	for i < end && p.isPunct(i, "|") {
+		var member *Type
+		member, i, err = p.parseNode(i+1, end)
+		if err != nil {
+			return nil, i, err
+		}
+		union.Members = append(union.Members, member)
+	}
+	return union, i, nil
+}
+
+func (p *parser) parseNode(i, end int) (*Type, int, error) {
+	// A "*" marks the default member of a union.
+	if i < end && p.isPunct(i, "*") {
+		i++
+	}
+	if i >= end {
+		return nil, i, fmt.Errorf("missing type")
+	}
+
+	var t *Type
+	tok := p.tokens[i]
+	switch {
+	case tok.Kind == pklsyntax.String:
+		t = &Type{Literal: tok.Value}
+		i++
+	case p.isPunct(i, "("):
+		inner, next, err := p.parseType(i+1, end)
+		if err != nil {
+			return nil, next, err
+		}
+		if !p.isPunct(next, ")") {
+			return nil, next, fmt.Errorf("expected ) at %s", p.tokens[min(next, len(p.tokens)-1)].Pos)
+		}
+		t, i = inner, next+1
+	case tok.Kind == pklsyntax.Ident:
+		t = &Type{Name: p.qualifiedName(i)}
+		i += 2*strings.Count(t.Name, ".") + 1
+		if i < end && p.isPunct(i, "<") {
+			for {
+				arg, next, err := p.parseType(i+1, end)
+				if err != nil {
+					return nil, next, err
+				}
+				t.Args = append(t.Args, arg)
+				i = next
+				if !p.isPunct(i, ",") {
+					break
+				}
+			}
+			if !p.isPunct(i, ">") {
+				return nil, i, fmt.Errorf("expected > after the arguments of %s", t.Name)
+			}
+			i++
+		}
+	default:
+		return nil, i, fmt.Errorf("unexpected %q at %s", tok.Text, tok.Pos)
+	}
+
+	if i < end && p.isPunct(i, "(") {
+		var err error
+		t.Constraints, i, err = p.constraints(i, end)
+		if err != nil {
+			return nil, i, err
+		}
+	}
+	if i < end && p.isPunct(i, "?") {
+		t.Nullable = true
+		i++
+	}
+	return t, i, nil
+}
+
+// constraints splits the constraint list opened by the "(" at token i on
+// top-level commas.
+func (p *parser) constraints(i, end int) ([]string, int, error) {
+	var (
+		list  []string
+		start = i + 1
+		depth = 0
+	)
+	for j := i; j < end; j++ {
+		switch {
+		case p.isPunct(j, "("), p.isPunct(j, "["), p.isPunct(j, "{"):
+			depth++
+		case p.isPunct(j, ")"), p.isPunct(j, "]"), p.isPunct(j, "}"):
+			depth--
+		case p.isPunct(j, ",") && depth == 1:
+			list = append(list, p.source(start, j))
+			start = j + 1
+			continue
+		default:
+			continue
+		}
+		if depth == 0 {
+			list = append(list, p.source(start, j))
+			return list, j + 1, nil
+		}
+	}
+	return nil, end, fmt.Errorf("unterminated type constraint at %s", p.tokens[i].Pos)
+}
+
+// source returns the source of tokens [i, j).
+func (p *parser) source(i, j int) string {
+	if i >= j {
+		return ""
+	}
+	return strings.TrimSpace(p.src[p.tokens[i].Pos.Offset:p.tokens[j-1].End])
+}
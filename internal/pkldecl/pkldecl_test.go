@@ -0,0 +1,133 @@
+package pkldecl
+
+import (
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+const source = `/// Example module.
+@ModuleInfo { minPklVersion = "0.30.2" }
+
+@go.Package { name = "example.com/gen/example" }
+
+open module org.example.Example
+
+import "external/pkl-go/codegen/src/go.pkl"
+
+/// Kinds of things.
+typealias Kind = "a" | *"b"
+
+hidden NameRegex = Regex(#"^\w+$"#)
+hidden isValidName = (str) -> if (str.matches(NameRegex)) true else throw("bad name")
+
+/// The name.
+Name: String(isValidName)
+
+/// The settings.
+Settings: Settings
+
+/// Settings of the example.
+class Settings {
+        /// The port.
+        Port: UInt16 = 3000
+
+        /// The timeout.
+        Timeout: Duration? = 60.s
+
+        /// The arguments.
+        Args: Mapping<String(isValidName), Listing<String(length < 10)>>?
+
+        Kind: (Kind|"c")? = "b"
+
+        Object: Listing<String> = new Listing {
+                "x"
+                /// Not a property.
+                y = 1
+        }
+
+        hidden Secret: String = "s"
+}
+
+function helper(x: String): String = x
+`
+
+func TestParse(t *testing.T) {
+	m, err := Parse("Example.pkl", source)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if m.Name != "org.example.Example" || m.ShortName() != "Example" || m.GoPackage != "example.com/gen/example" || m.Doc != "Example module." {
+		t.Errorf("Unexpected module %q %q %q", m.Name, m.GoPackage, m.Doc)
+	}
+
+	kind := m.Aliases["Kind"]
+	if values, ok := kind.Type.StringLiterals(); !ok || len(values) != 2 || values[1] != "b" || kind.Doc != "Kinds of things." {
+		t.Errorf("Unexpected alias %s %q", kind.Type, kind.Doc)
+	}
+
+	module := m.Classes[""]
+	if len(module.Properties) != 2 || module.Property("Name").Type.Constraints[0] != "isValidName" {
+		t.Errorf("Unexpected module properties %+v", module.Properties)
+	}
+
+	settings := m.Classes["Settings"]
+	if settings == nil || settings.Doc != "Settings of the example." {
+		t.Fatalf("Missing class Settings")
+	}
+	want := []struct{ name, typ, def, doc string }{
+		{"Port", "UInt16", "3000", "The port."},
+		{"Timeout", "Duration?", "60.s", "The timeout."},
+		{"Args", "Mapping<String(isValidName), Listing<String(length < 10)>>?", "", "The arguments."},
+		{"Kind", `(Kind|"c")?`, `"b"`, ""},
+		{"Object", "Listing<String>", "new Listing {", ""},
+	}
+	if len(settings.Properties) != len(want) {
+		t.Fatalf("Expected %d properties, got %d", len(want), len(settings.Properties))
+	}
+	for i, w := range want {
+		p := settings.Properties[i]
+		if p.Name != w.name || p.Type.String() != w.typ || p.Default != w.def || p.Doc != w.doc {
+			t.Errorf("Property %d: got %s: %s = %q (%q)", i, p.Name, p.Type, p.Default, p.Doc)
+		}
+	}
+}
+
+func TestParseLiteral(t *testing.T) {
+	tests := []struct {
+		expr string
+		want any
+		ok   bool
+	}{
+		{`"127.0.0.1"`, "127.0.0.1", true},
+		{`#"raw\n"#`, `raw\n`, true},
+		{"3000", int64(3000), true},
+		{"1_000", int64(1000), true},
+		{"1.5", 1.5, true},
+		{"false", false, true},
+		{"12.h", pkl.Duration{Value: 12, Unit: pkl.Hour}, true},
+		{"1.5.min", pkl.Duration{Value: 1.5, Unit: pkl.Minute}, true},
+		{`"a" + "b"`, nil, false},
+		{"new Listing {", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseLiteral(tt.expr)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseLiteral(%q) = %v, %v; want %v, %v", tt.expr, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	modules, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if m := modules.ByGoPackage("github.com/kdeps/schema/gen/kdeps/gpu"); m == nil || m.File != "Kdeps.pkl" {
+		t.Errorf("Expected Kdeps.pkl for the gpu package, got %v", m)
+	}
+	settings := modules.ByFile("APIServer.pkl").Classes["APIServerSettings"]
+	if p := settings.Property("PortNum"); p == nil || p.Default != "3000" {
+		t.Errorf("Unexpected PortNum %+v", p)
+	}
+}
@@ -0,0 +1,352 @@
+package jsonschema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/internal/pkldecl"
+	"github.com/kdeps/schema/validate"
+)
+
+// DurationPattern matches a Pkl Duration written as in Pkl source, such as
+// "60.s" or "1.5.h".
+const DurationPattern = `^-?\d+(\.\d+)?\.(ns|us|ms|s|min|h|d)$`
+
+// DataSizePattern matches a Pkl DataSize written as in Pkl source, such as "5.mb".
+const DataSizePattern = `^-?\d+(\.\d+)?\.(b|kb|kib|mb|mib|gb|gib|tb|tib|pb|pib)$`
+
+var (
+	durationType = reflect.TypeOf(pkl.Duration{})
+	dataSizeType = reflect.TypeOf(pkl.DataSize{})
+	objectType   = reflect.TypeOf(pkl.Object{})
+)
+
+// Generate returns a schema document for t, a struct type generated from a
+// Pkl class or module. Nested classes are placed in $defs.
+func Generate(t reflect.Type) (*Schema, error) {
+	modules, err := pkldecl.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Pkl modules: %w", err)
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("failed to generate schema: %s is not a struct", t)
+	}
+
+	g := &generator{
+		modules: modules,
+		rules:   make(map[ruleKey]*validate.Rule),
+		defs:    make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+		refs:    make(map[string]int),
+	}
+	for _, r := range validate.Rules() {
+		g.rules[ruleKey{r.Module, r.Class, r.Function}] = r
+	}
+
+	g.schema(t, nil, scope{})
+	if g.err != nil {
+		return nil, g.err
+	}
+	name := g.names[t]
+	root := *g.defs[name]
+	// Keep the definition only if the type refers to itself.
+	if g.refs[name] == 1 {
+		delete(g.defs, name)
+	}
+	root.Schema = Draft
+	root.Title = name
+	if len(g.defs) > 0 {
+		root.Defs = g.defs
+	}
+	return &root, nil
+}
+
+type ruleKey struct {
+	module, class, function string
+}
+
+// scope is the module and class declaring a type annotation, which scope
+// the validation functions named in its constraints.
+type scope struct {
+	module *pkldecl.Module
+	class  string
+}
+
+type generator struct {
+	modules pkldecl.Modules
+	rules   map[ruleKey]*validate.Rule
+	defs    map[string]*Schema
+	names   map[reflect.Type]string
+	refs    map[string]int
+	err     error
+}
+
+// schema returns the schema of t, declared in Pkl as pt if known.
+func (g *generator) schema(t reflect.Type, pt *pkldecl.Type, sc scope) *Schema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case durationType:
+		return &Schema{Type: "string", Pattern: DurationPattern}
+	case dataSizeType:
+		return &Schema{Type: "string", Pattern: DataSizePattern}
+	case objectType:
+		return &Schema{Type: "object"}
+	}
+
+	s := &Schema{}
+	switch t.Kind() {
+	case reflect.Interface:
+		// Any and Dynamic accept every value.
+		return s
+	case reflect.Bool:
+		s.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s.Type = "integer"
+		if bits := t.Bits(); bits < 64 {
+			lo, hi := int64(-1)<<(bits-1), int64(1)<<(bits-1)-1
+			s.Minimum, s.Maximum = &lo, &hi
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s.Type = "integer"
+		lo := int64(0)
+		s.Minimum = &lo
+		if bits := t.Bits(); bits < 64 {
+			hi := int64(1)<<bits - 1
+			s.Maximum = &hi
+		}
+	case reflect.Float32, reflect.Float64:
+		s.Type = "number"
+	case reflect.String:
+		s.Type = "string"
+		g.stringConstraints(s, t, pt, sc)
+	case reflect.Slice, reflect.Array:
+		s.Type = "array"
+		s.Items = g.schema(t.Elem(), typeArg(pt, 0, 1), sc)
+	case reflect.Map:
+		s.Type = "object"
+		if key := typeArg(pt, 0, 2); key != nil {
+			names := &Schema{}
+			g.stringConstraints(names, t.Key(), key, sc)
+			if names.Pattern != "" || names.Enum != nil {
+				s.PropertyNames = names
+			}
+		}
+		s.AdditionalProperties = g.schema(t.Elem(), typeArg(pt, 1, 2), sc)
+	case reflect.Struct:
+		return g.ref(t)
+	default:
+		g.fail(fmt.Errorf("failed to generate schema: unsupported type %s", t))
+	}
+	return s
+}
+
+// typeArg returns argument i of pt if pt is a generic type with n arguments,
+// such as Listing<E> or Mapping<K, V>.
+func typeArg(pt *pkldecl.Type, i, n int) *pkldecl.Type {
+	if pt == nil || len(pt.Args) != n {
+		return nil
+	}
+	return pt.Args[i]
+}
+
+// stringConstraints adds the enum of a string literal union, the format of a
+// Uri and the pattern of a validation function.
+func (g *generator) stringConstraints(s *Schema, t reflect.Type, pt *pkldecl.Type, sc scope) {
+	if values, ok := g.enum(t, pt); ok {
+		for _, v := range values {
+			s.Enum = append(s.Enum, v)
+		}
+	}
+	if pt == nil {
+		return
+	}
+	if pt.Name == "Uri" {
+		s.Format = "uri"
+	}
+	if sc.module == nil {
+		return
+	}
+	for _, c := range pt.Constraints {
+		if r := g.rules[ruleKey{sc.module.File, sc.class, c}]; r != nil {
+			s.Pattern = Pattern(r.Pattern)
+			break
+		}
+	}
+}
+
+// enum returns the values of an enum type generated from a typealias, or of
+// a string literal union declared inline.
+func (g *generator) enum(t reflect.Type, pt *pkldecl.Type) ([]string, bool) {
+	if pt != nil {
+		if values, ok := pt.StringLiterals(); ok {
+			return values, true
+		}
+	}
+	if t.PkgPath() == "" {
+		return nil, false
+	}
+	if m := g.modules.ByGoPackage(t.PkgPath()); m != nil {
+		if alias := m.Aliases[t.Name()]; alias != nil {
+			return alias.Type.StringLiterals()
+		}
+	}
+	return nil, false
+}
+
+// ref returns a reference to the definition of the struct type t, adding the
+// definition on first use.
+func (g *generator) ref(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = g.define(t)
+	}
+	g.refs[name]++
+	return &Schema{Ref: "#/$defs/" + name}
+}
+
+func (g *generator) define(t reflect.Type) string {
+	m, class, err := g.modules.ClassOf(t)
+	if err != nil {
+		g.fail(fmt.Errorf("failed to generate schema: %w", err))
+		return "invalid"
+	}
+	className := class.Name
+
+	name := className
+	if name == "" {
+		name = m.ShortName()
+	}
+	if _, taken := g.defs[name]; taken {
+		name = m.ShortName() + "." + name
+	}
+	def := &Schema{
+		Type:                 "object",
+		Description:          class.Doc,
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: false,
+	}
+	if className == "" {
+		def.Description = m.Doc
+	}
+	g.names[t] = name
+	g.defs[name] = def
+
+	sc := scope{module: m, class: className}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := f.Tag.Get("pkl")
+		if !f.IsExported() || key == "" || key == "-" {
+			continue
+		}
+		prop := class.Property(key)
+		var pt *pkldecl.Type
+		if prop != nil {
+			pt = prop.Type
+		}
+		s := g.schema(f.Type, pt, sc)
+		if prop != nil {
+			s.Description = prop.Doc
+			if v, ok := defaultValue(prop.Default); ok {
+				s.Default = v
+			}
+		}
+		def.Properties[key] = s
+		if f.Type.Kind() != reflect.Pointer && (prop == nil || prop.Default == "") && scalar(f.Type) {
+			def.Required = append(def.Required, key)
+		}
+	}
+	return name
+}
+
+// scalar reports whether a property of type t has no implicit default in
+// Pkl. Listings, mappings and class instances default to empty values.
+func scalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Interface:
+		return false
+	case reflect.Struct:
+		return t == durationType || t == dataSizeType
+	}
+	return true
+}
+
+// defaultValue converts a literal Pkl default to its JSON form. Durations
+// are written as in Pkl source, e.g. "60.s".
+func defaultValue(expr string) (any, bool) {
+	v, ok := pkldecl.ParseLiteral(expr)
+	if !ok {
+		return nil, false
+	}
+	if d, ok := v.(pkl.Duration); ok {
+		return strconv.FormatFloat(d.Value, 'f', -1, 64) + "." + d.Unit.String(), true
+	}
+	return v, true
+}
+
+func (g *generator) fail(err error) {
+	if g.err == nil {
+		g.err = err
+	}
+}
+
+// Pattern converts a Pkl regular expression to a JSON Schema pattern. JSON
+// Schema patterns are unanchored ECMA-262 expressions, whereas String.matches
+// in Pkl must match the whole string, so the result is anchored. Inline
+// case-insensitive groups (?i:...), which ECMA-262 lacks, are expanded into
+// character classes.
+func Pattern(pkl string) string {
+	return "^(?:" + expandCaseInsensitive(pkl) + ")$"
+}
+
+func expandCaseInsensitive(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if !strings.HasPrefix(p[i:], "(?i:") {
+			if p[i] == '\\' && i+1 < len(p) {
+				b.WriteString(p[i : i+2])
+				i++
+				continue
+			}
+			b.WriteByte(p[i])
+			continue
+		}
+		b.WriteString("(?:")
+		i += len("(?i:")
+		depth, class := 1, false
+		for ; i < len(p) && depth > 0; i++ {
+			c := p[i]
+			switch {
+			case c == '\\' && i+1 < len(p):
+				b.WriteString(p[i : i+2])
+				i++
+				continue
+			case class:
+				class = c != ']'
+			case c == '[':
+				class = true
+			case c == '(':
+				depth++
+			case c == ')':
+				depth--
+			case isASCIILetter(c):
+				b.WriteString("[" + string(c) + string(c^0x20) + "]")
+				continue
+			}
+			b.WriteByte(c)
+		}
+		i--
+	}
+	return b.String()
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
@@ -0,0 +1,142 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/kdeps/schema/gen/kdeps"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+func generate(t *testing.T, typ reflect.Type) *Schema {
+	t.Helper()
+	s, err := Generate(typ)
+	if err != nil {
+		t.Fatalf("Generate(%s) failed: %v", typ, err)
+	}
+	return s
+}
+
+func TestWorkflowSchema(t *testing.T) {
+	s := generate(t, reflect.TypeOf(workflow.WorkflowImpl{}))
+
+	if s.Schema != Draft || s.Title != "Workflow" || s.Type != "object" || s.AdditionalProperties != false {
+		t.Errorf("Unexpected document header %+v", s)
+	}
+	if !slices.Equal(s.Required, []string{"AgentID", "Description", "TargetActionID"}) {
+		t.Errorf("Unexpected required properties %v", s.Required)
+	}
+
+	version := s.Properties["Version"]
+	if version.Default != "1.0.0" || version.Description == "" {
+		t.Errorf("Unexpected Version schema %+v", version)
+	}
+	if website := s.Properties["Website"]; website.Format != "uri" {
+		t.Errorf("Expected Website to be a uri, got %+v", website)
+	}
+	if ref := s.Properties["Settings"].Ref; ref != "#/$defs/Settings" {
+		t.Errorf("Unexpected Settings reference %q", ref)
+	}
+
+	api := s.Defs["APIServerSettings"]
+	if api == nil {
+		t.Fatal("Missing APIServerSettings definition")
+	}
+	port := api.Properties["PortNum"]
+	if port.Type != "integer" || port.Default != int64(3000) || *port.Minimum != 0 || *port.Maximum != 65535 {
+		t.Errorf("Unexpected PortNum schema %+v", port)
+	}
+	if maxAge := s.Defs["CORSConfig"].Properties["MaxAge"]; maxAge.Default != "12.h" || maxAge.Pattern != DurationPattern {
+		t.Errorf("Unexpected MaxAge schema %+v", maxAge)
+	}
+	args := s.Defs["DockerSettings"].Properties["Args"]
+	if args.PropertyNames == nil || args.PropertyNames.Pattern != `^(?:^[a-zA-Z_]\w*$)$` {
+		t.Errorf("Expected the param name pattern on Args keys, got %+v", args.PropertyNames)
+	}
+	if serverType := s.Defs["WebServerRoutes"].Properties["ServerType"]; !reflect.DeepEqual(serverType.Enum, []any{"static", "app"}) {
+		t.Errorf("Unexpected ServerType enum %v", serverType.Enum)
+	}
+}
+
+func TestPatternsMatchLikePkl(t *testing.T) {
+	s := generate(t, reflect.TypeOf(workflow.WorkflowImpl{}))
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{s.Properties["AgentID"].Pattern, "myAgent", true},
+		{s.Properties["AgentID"].Pattern, "my-agent", false},
+		{s.Properties["TargetActionID"].Pattern, "@agent/action:1.0.0", true},
+		{s.Properties["Workflows"].Items.Pattern, "agent", false},
+		{s.Defs["APIServerRoutes"].Properties["Methods"].Items.Pattern, "get", true},
+		{s.Defs["APIServerRoutes"].Properties["Methods"].Items.Pattern, "GETS", false},
+		{s.Defs["APIServerRoutes"].Properties["Methods"].Items.Pattern, "FETCH", false},
+	}
+	for _, tt := range tests {
+		// The generated patterns use the common subset of RE2 and ECMA-262.
+		if got := regexp.MustCompile(tt.pattern).MatchString(tt.value); got != tt.want {
+			t.Errorf("%s matching %q = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	tests := map[string]string{
+		`^\w+$`:           `^(?:^\w+$)$`,
+		`^(?i:(GET|PUT))`: `^(?:^(?:([Gg][Ee][Tt]|[Pp][Uu][Tt])))$`,
+		`(?i:a[b-c]\d)x`:  `^(?:(?:[aA][b-c]\d)x)$`,
+		`\(?i:a)`:         `^(?:\(?i:a))$`,
+	}
+	for in, want := range tests {
+		if got := Pattern(in); got != want {
+			t.Errorf("Pattern(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestKdepsSchemaEnums(t *testing.T) {
+	s := generate(t, reflect.TypeOf(kdeps.Kdeps{}))
+	mode := s.Properties["RunMode"]
+	if !reflect.DeepEqual(mode.Enum, []any{"docker", "local"}) || mode.Default != "docker" {
+		t.Errorf("Unexpected RunMode schema %+v", mode)
+	}
+	if len(s.Required) != 0 || s.Defs != nil {
+		t.Errorf("Expected no required properties or definitions, got %v %v", s.Required, s.Defs)
+	}
+}
+
+// TestDocuments checks that every published document generates and that
+// every property is documented, which fails when a Pkl property is renamed
+// without regenerating the Go types.
+func TestDocuments(t *testing.T) {
+	for _, d := range Documents() {
+		s, ok, err := GenerateDocument(d.Name)
+		if err != nil || !ok {
+			t.Fatalf("GenerateDocument(%s) = %v, %v", d.Name, ok, err)
+		}
+		if s.Title != d.Name {
+			t.Errorf("Expected title %s, got %s", d.Name, s.Title)
+		}
+		if _, err := json.Marshal(s); err != nil {
+			t.Errorf("%s: failed to encode: %v", d.Name, err)
+		}
+		defs := map[string]*Schema{"": s}
+		for name, def := range s.Defs {
+			defs[name] = def
+		}
+		for name, def := range defs {
+			for key, prop := range def.Properties {
+				if prop.Description == "" {
+					t.Errorf("%s: %s.%s has no description", d.Name, name, key)
+				}
+			}
+		}
+	}
+	if _, ok, _ := GenerateDocument("Unknown"); ok {
+		t.Error("Expected an unknown document to be reported")
+	}
+}
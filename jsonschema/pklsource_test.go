@@ -0,0 +1,160 @@
+package jsonschema
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sourceProperty is a property declared in a Pkl source file, as found by
+// scanSources.
+type sourceProperty struct {
+	file, module, class, name string
+	documented                bool
+	// def is the literal default, or "" if there is none or it is not a
+	// literal.
+	def string
+}
+
+var (
+	moduleDecl   = regexp.MustCompile(`^(?:open\s+|abstract\s+)*module\s+([\w.]+)`)
+	classDecl    = regexp.MustCompile(`^(?:open\s+|abstract\s+|external\s+)*class\s+(\w+)`)
+	propertyDecl = regexp.MustCompile(`^(?:fixed\s+|const\s+)*([A-Za-z_]\w*)\s*:`)
+	literalDef   = regexp.MustCompile(`=\s*("(?:[^"\\]|\\.)*"|-?\d+(?:\.\d+)?(?:\.[a-z]+)?|true|false)\s*$`)
+	stringLit    = regexp.MustCompile(`#"(?:[^"]|"[^#])*"#|"(?:[^"\\]|\\.)*"`)
+)
+
+// scanSources lists the properties declared in the Pkl modules of dir with
+// a line scanner that is deliberately independent of package pkldecl, so
+// that the two cross-check each other.
+func scanSources(t *testing.T, dir string) []sourceProperty {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.pkl"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Failed to list %s: %v", dir, err)
+	}
+	var props []sourceProperty
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		module := strings.TrimSuffix(filepath.Base(file), ".pkl")
+		class := ""
+		depth := 0
+		documented := false
+		for _, line := range strings.Split(string(data), "\n") {
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(trimmed, "///"):
+				documented = true
+				continue
+			case strings.HasPrefix(trimmed, "@"):
+				// Annotations may sit between a doc comment and its property.
+				continue
+			}
+
+			if depth == 0 {
+				if m := moduleDecl.FindStringSubmatch(trimmed); m != nil {
+					module = m[1][strings.LastIndexByte(m[1], '.')+1:]
+				}
+				if m := classDecl.FindStringSubmatch(trimmed); m != nil {
+					class = m[1]
+				}
+			}
+			bodyDepth := 0
+			if class != "" {
+				bodyDepth = 1
+			}
+			if depth == bodyDepth {
+				if m := propertyDecl.FindStringSubmatch(trimmed); m != nil {
+					p := sourceProperty{file: filepath.Base(file), module: module, class: class, name: m[1], documented: documented}
+					if d := literalDef.FindStringSubmatch(trimmed); d != nil {
+						p.def = d[1]
+					}
+					props = append(props, p)
+				}
+			}
+			documented = false
+
+			code := stringLit.ReplaceAllString(trimmed, `""`)
+			if i := strings.Index(code, "//"); i >= 0 {
+				code = code[:i]
+			}
+			depth += strings.Count(code, "{") - strings.Count(code, "}")
+			if depth == 0 {
+				class = ""
+			}
+		}
+	}
+	return props
+}
+
+// sameDefault reports whether the default of a schema is the Pkl literal.
+func sameDefault(schemaDefault any, literal string) bool {
+	switch v := schemaDefault.(type) {
+	case string:
+		if strings.HasPrefix(literal, `"`) {
+			s, err := strconv.Unquote(literal)
+			return err == nil && s == v
+		}
+		return v == literal // a duration such as 12.h
+	case bool:
+		return strconv.FormatBool(v) == literal
+	case int64:
+		return strconv.FormatInt(v, 10) == literal
+	case float64:
+		f, err := strconv.ParseFloat(literal, 64)
+		return err == nil && math.Abs(f-v) < 1e-9
+	}
+	return false
+}
+
+// TestSchemaCoversPklSources fails when a property, doc comment or literal
+// default declared in deps/pkl is missing from the generated schemas.
+func TestSchemaCoversPklSources(t *testing.T) {
+	props := scanSources(t, filepath.Join("..", "deps", "pkl"))
+
+	checked := 0
+	for _, d := range Documents() {
+		s := generate(t, d.Type)
+		defs := map[string]*Schema{s.Title: s}
+		for name, def := range s.Defs {
+			defs[name] = def
+		}
+		for _, p := range props {
+			owner := p.class
+			if owner == "" {
+				owner = p.module
+			}
+			def, ok := defs[p.module+"."+owner]
+			if !ok {
+				def, ok = defs[owner]
+			}
+			if !ok {
+				// The class is not part of this document.
+				continue
+			}
+			checked++
+			where := d.Name + ": " + p.file + " " + owner + "." + p.name
+			prop, ok := def.Properties[p.name]
+			if !ok {
+				t.Errorf("%s is missing from the schema", where)
+				continue
+			}
+			if p.documented && prop.Description == "" {
+				t.Errorf("%s is documented in Pkl but has no description", where)
+			}
+			if p.def != "" && !sameDefault(prop.Default, p.def) {
+				t.Errorf("%s defaults to %s in Pkl, but the schema default is %v", where, p.def, prop.Default)
+			}
+		}
+	}
+	if checked < 50 {
+		t.Errorf("Only %d properties were checked; the Pkl scanner is likely broken", checked)
+	}
+}
@@ -0,0 +1,90 @@
+// Package jsonschema generates JSON Schema (draft 2020-12) documents for the
+// kdeps Pkl modules, for tooling that does not speak Pkl such as editors and
+// yaml-language-server.
+//
+// The structure of a schema follows the generated Go types; descriptions,
+// defaults, enums and the regular expression constraints come from the
+// embedded Pkl sources and the validate package.
+//
+//	s, err := jsonschema.Generate(reflect.TypeOf(workflow.WorkflowImpl{}))
+package jsonschema
+
+import (
+	"reflect"
+
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/api_server_request"
+	"github.com/kdeps/schema/gen/api_server_response"
+	"github.com/kdeps/schema/gen/docker"
+	"github.com/kdeps/schema/gen/kdeps"
+	"github.com/kdeps/schema/gen/project"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/web_server"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+// Draft is the JSON Schema dialect of the generated documents.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document or subschema.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type    string `json:"type,omitempty"`
+	Format  string `json:"format,omitempty"`
+	Enum    []any  `json:"enum,omitempty"`
+	Default any    `json:"default,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Minimum *int64 `json:"minimum,omitempty"`
+	Maximum *int64 `json:"maximum,omitempty"`
+
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Document names a type for which a schema document is published.
+type Document struct {
+	// Name is the document title and file name stem, e.g. "Workflow".
+	Name string
+	// Type is the generated Go type.
+	Type reflect.Type
+}
+
+// Documents returns the published documents: the files users write, the
+// settings classes they nest, and the API server request and response.
+func Documents() []Document {
+	return []Document{
+		{"Workflow", reflect.TypeOf(workflow.WorkflowImpl{})},
+		{"Resource", reflect.TypeOf(resource.Resource{})},
+		{"Settings", reflect.TypeOf(project.Settings{})},
+		{"APIServerSettings", reflect.TypeOf(apiserver.APIServerSettings{})},
+		{"WebServerSettings", reflect.TypeOf(webserver.WebServerSettings{})},
+		{"DockerSettings", reflect.TypeOf(docker.DockerSettings{})},
+		{"Kdeps", reflect.TypeOf(kdeps.Kdeps{})},
+		{"APIServerRequest", reflect.TypeOf(apiserverrequest.APIServerRequestImpl{})},
+		{"APIServerResponse", reflect.TypeOf(apiserverresponse.APIServerResponseImpl{})},
+	}
+}
+
+// GenerateDocument returns the schema of the document called name.
+func GenerateDocument(name string) (*Schema, bool, error) {
+	for _, d := range Documents() {
+		if d.Name == name {
+			s, err := Generate(d.Type)
+			if s != nil {
+				s.Title = d.Name
+			}
+			return s, true, err
+		}
+	}
+	return nil, false, nil
+}
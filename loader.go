@@ -0,0 +1,212 @@
+// Package schema loads kdeps Pkl modules into the generated Go types through
+// one long-lived evaluator, instead of starting an evaluator per module as the
+// generated LoadFromPath functions do.
+//
+//	loader := schema.NewLoader(schema.WithTimeout(30 * time.Second))
+//	defer loader.Close()
+//
+//	wf, err := schema.LoadFromPath(ctx, loader, "workflow.pkl", workflow.Load)
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets"
+)
+
+// ErrLoaderClosed is returned when evaluating with a Loader after Close.
+var ErrLoaderClosed = errors.New("schema loader is closed")
+
+// LoadFunc is the signature of the generated Load functions, such as
+// workflow.Load or resource.Load.
+type LoadFunc[T any] func(ctx context.Context, evaluator pkl.Evaluator, source *pkl.ModuleSource) (T, error)
+
+// Loader evaluates Pkl modules with a single pooled evaluator owned by a
+// pkl.EvaluatorManager. The evaluator is started on first use and shared by
+// all evaluations; a Loader is safe for concurrent use.
+//
+// By default the evaluator uses pkl.PreconfiguredOptions and resolves the
+// embedded kdeps, pkl-go and pantry packages offline via
+// assets.WithEmbeddedPackages.
+type Loader struct {
+	manager     pkl.EvaluatorManager
+	ownsManager bool
+	options     []func(*pkl.EvaluatorOptions)
+	timeout     time.Duration
+
+	mu        sync.Mutex
+	evaluator pkl.Evaluator
+	closed    bool
+}
+
+// Option configures a Loader.
+type Option func(*Loader)
+
+// WithTimeout bounds each evaluation. Zero, the default, means no timeout
+// beyond the caller's context.
+func WithTimeout(d time.Duration) Option {
+	return func(l *Loader) {
+		l.timeout = d
+	}
+}
+
+// WithEvaluatorOptions appends options applied when the evaluator is created,
+// after the defaults.
+func WithEvaluatorOptions(opts ...func(*pkl.EvaluatorOptions)) Option {
+	return func(l *Loader) {
+		l.options = append(l.options, opts...)
+	}
+}
+
+// WithEvaluatorManager makes the Loader use manager instead of starting its
+// own. The Loader does not close a manager passed this way.
+func WithEvaluatorManager(manager pkl.EvaluatorManager) Option {
+	return func(l *Loader) {
+		l.manager = manager
+		l.ownsManager = false
+	}
+}
+
+// NewLoader returns a Loader. No Pkl process is started until the first
+// evaluation.
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{
+		options: []func(*pkl.EvaluatorOptions){pkl.PreconfiguredOptions, assets.WithEmbeddedPackages},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.manager == nil {
+		l.manager = pkl.NewEvaluatorManager()
+		l.ownsManager = true
+	}
+	return l
+}
+
+var (
+	defaultLoader     *Loader
+	defaultLoaderOnce sync.Once
+)
+
+// Default returns a process-wide Loader with the default configuration. It
+// is never closed.
+func Default() *Loader {
+	defaultLoaderOnce.Do(func() {
+		defaultLoader = NewLoader()
+	})
+	return defaultLoader
+}
+
+// Evaluator returns the pooled evaluator, creating it if needed. An
+// evaluator that has been closed, for example because the Pkl process
+// exited, is replaced.
+func (l *Loader) Evaluator(ctx context.Context) (pkl.Evaluator, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil, ErrLoaderClosed
+	}
+	if l.evaluator != nil && !l.evaluator.Closed() {
+		return l.evaluator, nil
+	}
+	evaluator, err := l.manager.NewEvaluator(ctx, l.options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create evaluator: %w", err)
+	}
+	l.evaluator = evaluator
+	return evaluator, nil
+}
+
+// Evaluate evaluates the module at source into out, which must be a pointer
+// to a generated Impl struct or another type pkl.Unmarshal accepts.
+// Evaluation failures are returned as *EvalError.
+func (l *Loader) Evaluate(ctx context.Context, source *pkl.ModuleSource, out any) error {
+	return l.do(ctx, source, func(ctx context.Context, evaluator pkl.Evaluator) error {
+		return evaluator.EvaluateModule(ctx, source, out)
+	})
+}
+
+// EvaluateFile evaluates the module at path into out.
+func (l *Loader) EvaluateFile(ctx context.Context, path string, out any) error {
+	return l.Evaluate(ctx, pkl.FileSource(path), out)
+}
+
+// Close closes the evaluator and, unless it was supplied with
+// WithEvaluatorManager, the evaluator manager. Evaluations after Close fail
+// with ErrLoaderClosed.
+func (l *Loader) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+
+	var errs []error
+	if l.evaluator != nil {
+		errs = append(errs, l.evaluator.Close())
+		l.evaluator = nil
+	}
+	if l.ownsManager {
+		errs = append(errs, l.manager.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// do runs fn with the pooled evaluator under the per-evaluation timeout and
+// converts evaluation failures of source into *EvalError.
+func (l *Loader) do(ctx context.Context, source *pkl.ModuleSource, fn func(context.Context, pkl.Evaluator) error) error {
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	evaluator, err := l.Evaluator(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(ctx, evaluator)
+	// The evaluator abandons a request when ctx is done and reports no error
+	// of its own, so the context error takes precedence.
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return wrapEvalError(source.Uri.String(), err)
+}
+
+// Load evaluates source with the loader's pooled evaluator using a generated
+// Load function:
+//
+//	res, err := schema.Load(ctx, loader, pkl.TextSource(text), resource.Load)
+func Load[T any](ctx context.Context, l *Loader, source *pkl.ModuleSource, load LoadFunc[T]) (T, error) {
+	var ret T
+	err := l.do(ctx, source, func(ctx context.Context, evaluator pkl.Evaluator) error {
+		var err error
+		ret, err = load(ctx, evaluator, source)
+		return err
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return ret, nil
+}
+
+// LoadFromPath is Load for the module at path. It replaces the generated
+// LoadFromPath functions:
+//
+//	wf, err := schema.LoadFromPath(ctx, loader, "workflow.pkl", workflow.Load)
+func LoadFromPath[T any](ctx context.Context, l *Loader, path string, load LoadFunc[T]) (T, error) {
+	return Load(ctx, l, pkl.FileSource(path), load)
+}
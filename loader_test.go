@@ -0,0 +1,240 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+// fakeManager hands out fakeEvaluators and counts how many were created.
+type fakeManager struct {
+	created atomic.Int32
+	closed  atomic.Bool
+	delay   time.Duration
+}
+
+func (m *fakeManager) Close() error {
+	m.closed.Store(true)
+	return nil
+}
+
+func (m *fakeManager) GetVersion() (string, error) {
+	return "0.28.0", nil
+}
+
+func (m *fakeManager) NewEvaluator(ctx context.Context, opts ...func(*pkl.EvaluatorOptions)) (pkl.Evaluator, error) {
+	m.created.Add(1)
+	return &fakeEvaluator{delay: m.delay}, nil
+}
+
+func (m *fakeManager) NewProjectEvaluator(ctx context.Context, _ *url.URL, opts ...func(*pkl.EvaluatorOptions)) (pkl.Evaluator, error) {
+	return m.NewEvaluator(ctx, opts...)
+}
+
+// fakeEvaluator fills a WorkflowImpl from the module text, or blocks for
+// delay while honouring ctx like the real evaluator.
+type fakeEvaluator struct {
+	delay  time.Duration
+	calls  atomic.Int32
+	closed atomic.Bool
+}
+
+func (e *fakeEvaluator) EvaluateModule(ctx context.Context, source *pkl.ModuleSource, out any) error {
+	e.calls.Add(1)
+	if e.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(e.delay):
+		}
+	}
+	if wf, ok := out.(*workflow.WorkflowImpl); ok {
+		wf.AgentID = source.Contents
+	}
+	return nil
+}
+
+func (e *fakeEvaluator) EvaluateOutputText(context.Context, *pkl.ModuleSource) (string, error) {
+	return "", nil
+}
+
+func (e *fakeEvaluator) EvaluateOutputBytes(context.Context, *pkl.ModuleSource) ([]byte, error) {
+	return nil, nil
+}
+
+func (e *fakeEvaluator) EvaluateOutputValue(context.Context, *pkl.ModuleSource, any) error {
+	return nil
+}
+
+func (e *fakeEvaluator) EvaluateOutputFiles(context.Context, *pkl.ModuleSource) (map[string]string, error) {
+	return nil, nil
+}
+
+func (e *fakeEvaluator) EvaluateOutputFilesBytes(context.Context, *pkl.ModuleSource) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func (e *fakeEvaluator) EvaluateExpression(context.Context, *pkl.ModuleSource, string, any) error {
+	return nil
+}
+
+func (e *fakeEvaluator) EvaluateExpressionRaw(context.Context, *pkl.ModuleSource, string) ([]byte, error) {
+	return nil, nil
+}
+
+func (e *fakeEvaluator) Close() error {
+	e.closed.Store(true)
+	return nil
+}
+
+func (e *fakeEvaluator) Closed() bool {
+	return e.closed.Load()
+}
+
+func TestLoaderPoolsEvaluator(t *testing.T) {
+	manager := &fakeManager{}
+	loader := NewLoader(WithEvaluatorManager(manager))
+	defer loader.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wf, err := Load(context.Background(), loader, pkl.TextSource("agent"), workflow.Load)
+			if err != nil {
+				t.Errorf("Load failed: %v", err)
+				return
+			}
+			if wf.GetAgentID() != "agent" {
+				t.Errorf("Unexpected AgentID %q", wf.GetAgentID())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := manager.created.Load(); n != 1 {
+		t.Errorf("Expected one evaluator for 30 loads, got %d", n)
+	}
+}
+
+func TestLoaderReplacesClosedEvaluator(t *testing.T) {
+	manager := &fakeManager{}
+	loader := NewLoader(WithEvaluatorManager(manager))
+	defer loader.Close()
+
+	first, err := loader.Evaluator(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+	second, err := loader.Evaluator(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second || manager.created.Load() != 2 {
+		t.Error("Closed evaluator was not replaced")
+	}
+}
+
+func TestLoaderTimeout(t *testing.T) {
+	manager := &fakeManager{delay: time.Minute}
+	loader := NewLoader(WithEvaluatorManager(manager), WithTimeout(20*time.Millisecond))
+	defer loader.Close()
+
+	start := time.Now()
+	_, err := Load(context.Background(), loader, pkl.TextSource("slow"), workflow.Load)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("Timeout was not applied")
+	}
+}
+
+func TestLoaderCancellation(t *testing.T) {
+	manager := &fakeManager{delay: time.Minute}
+	loader := NewLoader(WithEvaluatorManager(manager))
+	defer loader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	var out workflow.WorkflowImpl
+	if err := loader.Evaluate(ctx, pkl.TextSource("slow"), &out); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	if err := loader.Evaluate(ctx, pkl.TextSource("again"), &out); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled for a done context, got %v", err)
+	}
+}
+
+func TestLoaderClose(t *testing.T) {
+	manager := &fakeManager{}
+	loader := NewLoader(WithEvaluatorManager(manager))
+	evaluator, err := loader.Evaluator(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loader.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !evaluator.Closed() {
+		t.Error("Evaluator was not closed")
+	}
+	if manager.closed.Load() {
+		t.Error("Loader closed a manager it does not own")
+	}
+	if _, err := Load(context.Background(), loader, pkl.TextSource("x"), workflow.Load); !errors.Is(err, ErrLoaderClosed) {
+		t.Errorf("Expected ErrLoaderClosed, got %v", err)
+	}
+	if err := loader.Close(); err != nil {
+		t.Errorf("Second Close failed: %v", err)
+	}
+}
+
+func TestLoaderEvaluatesEmbeddedModules(t *testing.T) {
+	if _, err := exec.LookPath("pkl"); err != nil && os.Getenv("PKL_EXEC") == "" {
+		t.Skip("pkl binary not available")
+	}
+
+	path := filepath.Join(t.TempDir(), "workflow.pkl")
+	src := `amends "package://schema.kdeps.com/core@0.5.0#/Workflow.pkl"
+
+AgentID = "demo"
+Description = "Demo agent"
+TargetActionID = "main"
+Settings {
+  AgentSettings {
+    Models {}
+  }
+}
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := NewLoader(WithTimeout(time.Minute))
+	defer loader.Close()
+	wf, err := LoadFromPath(context.Background(), loader, path, workflow.Load)
+	if err != nil {
+		t.Fatalf("LoadFromPath failed: %v", err)
+	}
+	if wf.GetAgentID() != "demo" || wf.GetVersion() != "1.0.0" {
+		t.Errorf("Unexpected workflow: %+v", wf)
+	}
+}
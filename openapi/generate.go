@@ -0,0 +1,235 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/kdeps/schema/actionref"
+	"github.com/kdeps/schema/agent"
+	"github.com/kdeps/schema/gen/api_server_response"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/graph"
+	"github.com/kdeps/schema/jsonschema"
+	"github.com/kdeps/schema/router"
+)
+
+// ErrNoAPIServer is returned for an agent without API server settings.
+var ErrNoAPIServer = errors.New("agent has no API server settings")
+
+// ResponseSchema is the name of the APIServerResponse envelope schema in the
+// components of a generated document.
+const ResponseSchema = "APIServerResponse"
+
+// bodyMethods are the methods documented with a request body.
+var bodyMethods = map[string]bool{"POST": true, "PUT": true, "PATCH": true}
+
+// Generate returns the OpenAPI document of the API server of a.
+func Generate(a *agent.Agent) (*Document, error) {
+	settings := a.Settings.APIServer
+	if settings == nil {
+		return nil, ErrNoAPIServer
+	}
+
+	envelope, err := jsonschema.Generate(reflect.TypeOf(apiserverresponse.APIServerResponseImpl{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the response schema: %w", err)
+	}
+	components := &Components{Schemas: make(map[string]*jsonschema.Schema)}
+	hoist(components.Schemas, ResponseSchema, envelope)
+
+	wf := a.Workflow
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       wf.GetAgentID(),
+			Description: wf.GetDescription(),
+			Version:     wf.GetVersion(),
+		},
+		JSONSchemaDialect: jsonschema.Draft,
+		Servers: []Server{{
+			URL: "http://" + net.JoinHostPort(settings.HostIP, strconv.Itoa(int(settings.PortNum))),
+		}},
+		Tags:       []Tag{{Name: wf.GetAgentID(), Description: wf.GetDescription()}},
+		Paths:      make(map[string]PathItem),
+		Components: components,
+	}
+
+	resources := targetResources(a)
+	for _, route := range settings.Routes {
+		pattern, err := router.ParsePattern(route.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to document route: %w", err)
+		}
+		path := pattern.OpenAPI()
+		item := doc.Paths[path]
+		if item == nil {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		for _, m := range route.Methods {
+			method := strings.ToUpper(m)
+			if _, ok := item[strings.ToLower(method)]; ok {
+				continue
+			}
+			item[strings.ToLower(method)] = operation(wf.GetAgentID(), method, pattern, router.Allowed(resources, method, route.Path))
+		}
+	}
+	return doc, nil
+}
+
+// targetResources returns the resources the workflow target depends on,
+// sorted by ActionID, or every resource if the target is not a local action.
+func targetResources(a *agent.Agent) []*resource.Resource {
+	ids := a.ActionIDs()
+	if ref, err := actionref.Parse(a.Workflow.GetTargetActionID()); err == nil && (ref.IsLocal() || ref.Agent == a.Workflow.GetAgentID()) {
+		if reached := graph.New(a.Resources).Reachable(ref.Action); len(reached) > 0 {
+			ids = reached
+		}
+	}
+	resources := make([]*resource.Resource, len(ids))
+	for i, id := range ids {
+		resources[i] = a.Resources[id]
+	}
+	return resources
+}
+
+// ignoredHeaders may not be described as header parameters.
+var ignoredHeaders = map[string]bool{"accept": true, "content-type": true, "authorization": true}
+
+func operation(tag, method string, pattern router.Pattern, resources []*resource.Resource) *Operation {
+	op := &Operation{
+		OperationID: operationID(method, pattern.String()),
+		Summary:     method + " " + pattern.String(),
+		Tags:        []string{tag},
+		Responses: map[string]*Response{
+			"200": {
+				Description: "The request succeeded. Success is true and Response holds the data.",
+				Content:     jsonContent(&jsonschema.Schema{Ref: componentRef(ResponseSchema)}),
+			},
+			"default": {
+				Description: "The request failed. Success is false and Errors describes the failures.",
+				Content:     jsonContent(&jsonschema.Schema{Ref: componentRef(ResponseSchema)}),
+			},
+		},
+	}
+
+	ids := make([]string, len(resources))
+	params := make(map[string]bool)
+	headers := make(map[string]bool)
+	for i, r := range resources {
+		ids[i] = r.ActionID
+		if r.Run.AllowedParams != nil {
+			for _, p := range *r.Run.AllowedParams {
+				params[p] = true
+			}
+		}
+		if r.Run.AllowedHeaders != nil {
+			for _, h := range *r.Run.AllowedHeaders {
+				if !ignoredHeaders[strings.ToLower(h)] {
+					headers[h] = true
+				}
+			}
+		}
+	}
+	if len(ids) > 0 {
+		op.Description = "Runs the resources " + strings.Join(ids, ", ") + "."
+	}
+	for _, name := range pattern.Params() {
+		op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: &jsonschema.Schema{Type: "string"}})
+	}
+	for _, name := range sortedKeys(params) {
+		op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "query", Schema: &jsonschema.Schema{Type: "string"}})
+	}
+	for _, name := range sortedKeys(headers) {
+		op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "header", Schema: &jsonschema.Schema{Type: "string"}})
+	}
+
+	if bodyMethods[method] {
+		op.RequestBody = &RequestBody{
+			Description: "The request body, available to resources as the request data, or files to upload.",
+			Content: map[string]*MediaType{
+				"application/json": {Schema: &jsonschema.Schema{}},
+				"multipart/form-data": {Schema: &jsonschema.Schema{
+					Type:        "object",
+					Description: "Each file field becomes an entry of the request Files.",
+					AdditionalProperties: &jsonschema.Schema{
+						Type:  "array",
+						Items: &jsonschema.Schema{Type: "string", Format: "binary"},
+					},
+				}},
+			},
+		}
+	}
+	return op
+}
+
+func jsonContent(s *jsonschema.Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: s}}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// operationID derives an identifier such as "postApiV1Whois" from method and path.
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	words := strings.FieldsFunc(path, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return id + "Root"
+	}
+	for _, w := range words {
+		r := []rune(w)
+		id += string(unicode.ToUpper(r[0])) + string(r[1:])
+	}
+	return id
+}
+
+func componentRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+// hoist adds the schema document s to schemas under name, moving its $defs
+// into schemas and rewriting the references to them.
+func hoist(schemas map[string]*jsonschema.Schema, name string, s *jsonschema.Schema) {
+	defs := s.Defs
+	s.Defs = nil
+	s.Schema = ""
+	schemas[name] = s
+	for defName, def := range defs {
+		schemas[defName] = def
+	}
+	for _, def := range schemas {
+		rewriteRefs(def)
+	}
+}
+
+func rewriteRefs(s *jsonschema.Schema) {
+	if s == nil {
+		return
+	}
+	if name, ok := strings.CutPrefix(s.Ref, "#/$defs/"); ok {
+		s.Ref = componentRef(name)
+	}
+	rewriteRefs(s.Items)
+	rewriteRefs(s.PropertyNames)
+	if additional, ok := s.AdditionalProperties.(*jsonschema.Schema); ok {
+		rewriteRefs(additional)
+	}
+	for _, p := range s.Properties {
+		rewriteRefs(p)
+	}
+}
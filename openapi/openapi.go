@@ -0,0 +1,120 @@
+// Package openapi generates an OpenAPI 3.1 document describing the HTTP API
+// of a loaded agent.
+//
+// Every method of every route in APIServerSettings.Routes becomes an
+// operation, with route parameters such as ":id" documented as path
+// parameters of the path template "{id}". The query parameters and headers
+// of an operation are the AllowedParams and AllowedHeaders of the resources
+// that handle it: the resources the workflow target depends on, minus those
+// excluded by their RestrictToRoutes and RestrictToHTTPMethods, as selected
+// by the router package. Responses use the
+// APIServerResponse envelope, whose schema is generated by the jsonschema
+// package.
+//
+//	doc, err := openapi.Generate(a)
+//	http.Handle("/openapi.json", openapi.Handler(doc))
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/kdeps/schema/jsonschema"
+)
+
+// Version is the OpenAPI version of the generated documents.
+const Version = "3.1.0"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI           string              `json:"openapi"`
+	Info              Info                `json:"info"`
+	JSONSchemaDialect string              `json:"jsonSchemaDialect,omitempty"`
+	Servers           []Server            `json:"servers,omitempty"`
+	Tags              []Tag               `json:"tags,omitempty"`
+	Paths             map[string]PathItem `json:"paths"`
+	Components        *Components         `json:"components,omitempty"`
+}
+
+// Info is the metadata of the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server is a base URL of the API.
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations.
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path, indexed by lower-case method.
+type PathItem map[string]*Operation
+
+// Operation is a method on a path.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a query, header or path parameter.
+type Parameter struct {
+	Name        string             `json:"name"`
+	In          string             `json:"in"`
+	Description string             `json:"description,omitempty"`
+	Required    bool               `json:"required,omitempty"`
+	Schema      *jsonschema.Schema `json:"schema"`
+}
+
+// RequestBody describes the accepted request bodies.
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+// Response describes a response.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one media type.
+type MediaType struct {
+	Schema *jsonschema.Schema `json:"schema"`
+}
+
+// Components holds the schemas referenced from operations.
+type Components struct {
+	Schemas map[string]*jsonschema.Schema `json:"schemas,omitempty"`
+}
+
+// Handler serves doc as JSON.
+func Handler(doc *Document) http.Handler {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(doc)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "failed to encode OpenAPI document: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(buf.Bytes())
+	})
+}
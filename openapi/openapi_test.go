@@ -0,0 +1,187 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kdeps/schema/agent"
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+func list(s ...string) *[]string {
+	return &s
+}
+
+func testAgent() *agent.Agent {
+	resources := map[string]*resource.Resource{
+		"fetch": {
+			ActionID: "fetch",
+			Run: resource.ResourceAction{
+				AllowedParams:  list("q"),
+				AllowedHeaders: list("X-Token", "Authorization"),
+			},
+		},
+		"upload": {
+			ActionID: "upload",
+			Requires: list("fetch"),
+			Run: resource.ResourceAction{
+				AllowedParams:         list("name"),
+				RestrictToRoutes:      list("/api/v1/files"),
+				RestrictToHTTPMethods: list("post"),
+			},
+		},
+		"respond": {
+			ActionID: "respond",
+			Requires: list("upload"),
+		},
+		"unused": {
+			ActionID: "unused",
+			Run:      resource.ResourceAction{AllowedParams: list("ignored")},
+		},
+	}
+	wf := workflow.WorkflowImpl{
+		AgentID:        "files",
+		Description:    "Stores files.",
+		Version:        "1.2.0",
+		TargetActionID: "respond",
+	}
+	wf.Settings.APIServerMode = true
+	wf.Settings.APIServer = &apiserver.APIServerSettings{
+		HostIP:  "127.0.0.1",
+		PortNum: 3000,
+		Routes: []apiserver.APIServerRoutes{
+			{Path: "/api/v1/files", Methods: []string{"GET", "post", "POST"}},
+			{Path: "/", Methods: []string{"GET"}},
+		},
+	}
+	return &agent.Agent{Workflow: wf, Resources: resources, Settings: wf.Settings}
+}
+
+func TestGenerate(t *testing.T) {
+	doc, err := Generate(testAgent())
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if doc.OpenAPI != Version || doc.Info.Title != "files" || doc.Info.Version != "1.2.0" || doc.Servers[0].URL != "http://127.0.0.1:3000" {
+		t.Errorf("Unexpected document header %+v %+v", doc.Info, doc.Servers)
+	}
+
+	files := doc.Paths["/api/v1/files"]
+	if len(files) != 2 {
+		t.Fatalf("Expected get and post on /api/v1/files, got %v", files)
+	}
+	post := files["post"]
+	if post.OperationID != "postApiV1Files" || post.Description != "Runs the resources fetch, respond, upload." {
+		t.Errorf("Unexpected post operation %+v", post)
+	}
+	if got := params(post); got != "query:name query:q header:X-Token" {
+		t.Errorf("Unexpected post parameters %s", got)
+	}
+	if post.RequestBody == nil || post.RequestBody.Content["multipart/form-data"] == nil {
+		t.Error("Expected a multipart request body on post")
+	}
+
+	get := files["get"]
+	if got := params(get); got != "query:q header:X-Token" {
+		t.Errorf("Unexpected get parameters %s", got)
+	}
+	if get.RequestBody != nil {
+		t.Error("Expected no request body on get")
+	}
+	if root := doc.Paths["/"]["get"]; root == nil || root.OperationID != "getRoot" {
+		t.Errorf("Unexpected root operation %+v", root)
+	}
+}
+
+func TestGeneratePathParameters(t *testing.T) {
+	a := testAgent()
+	a.Settings.APIServer.Routes = []apiserver.APIServerRoutes{
+		{Path: "/users/:id/files/*path", Methods: []string{"GET"}},
+	}
+	a.Resources["fetch"].Run.RestrictToRoutes = list("/users/:id/files/*path")
+	doc, err := Generate(a)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	get := doc.Paths["/users/{id}/files/{path}"]["get"]
+	if get == nil {
+		t.Fatalf("Expected a templated path, got %v", doc.Paths)
+	}
+	if got := params(get); got != "path:id path:path query:q header:X-Token" {
+		t.Errorf("Unexpected parameters %s", got)
+	}
+	if !get.Parameters[0].Required || get.OperationID != "getUsersIdFilesPath" {
+		t.Errorf("Unexpected operation %+v", get)
+	}
+
+	a.Settings.APIServer.Routes = []apiserver.APIServerRoutes{{Path: "/files/*/more", Methods: []string{"GET"}}}
+	if _, err := Generate(a); err == nil {
+		t.Error("Expected an error for an invalid route")
+	}
+}
+
+func params(op *Operation) string {
+	var s []string
+	for _, p := range op.Parameters {
+		s = append(s, p.In+":"+p.Name)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestResponseComponents(t *testing.T) {
+	doc, err := Generate(testAgent())
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	envelope := doc.Components.Schemas[ResponseSchema]
+	if envelope == nil || envelope.Schema != "" || envelope.Defs != nil {
+		t.Fatalf("Unexpected envelope schema %+v", envelope)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if strings.Contains(string(data), "#/$defs/") {
+		t.Error("Found a reference that was not rewritten to the components")
+	}
+	// Every reference must resolve to a component.
+	for _, ref := range strings.Split(string(data), `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.IndexByte(ref, '"')]
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("Unresolved reference to %s", name)
+		}
+	}
+	if doc.Components.Schemas["APIServerErrorsBlock"] == nil {
+		t.Error("Expected the error block schema in the components")
+	}
+}
+
+func TestGenerateWithoutAPIServer(t *testing.T) {
+	a := testAgent()
+	a.Settings.APIServer = nil
+	if _, err := Generate(a); !errors.Is(err, ErrNoAPIServer) {
+		t.Errorf("Expected ErrNoAPIServer, got %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	doc, err := Generate(testAgent())
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	Handler(doc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Unexpected response %d %s", rec.Code, rec.Header())
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil || decoded["openapi"] != Version {
+		t.Errorf("Unexpected body: %v", err)
+	}
+}
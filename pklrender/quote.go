@@ -0,0 +1,86 @@
+package pklrender
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// keywords are the Pkl keywords and reserved words, which must be
+// backtick-quoted when used as property names.
+var keywords = map[string]bool{
+	"abstract": true, "amends": true, "as": true, "case": true, "class": true,
+	"const": true, "delete": true, "else": true, "extends": true, "external": true,
+	"false": true, "fixed": true, "for": true, "function": true, "hidden": true,
+	"if": true, "import": true, "in": true, "is": true, "let": true,
+	"local": true, "module": true, "new": true, "nothing": true, "null": true,
+	"open": true, "out": true, "outer": true, "override": true, "protected": true,
+	"read": true, "record": true, "super": true, "switch": true, "this": true,
+	"throw": true, "trace": true, "true": true, "typealias": true, "unknown": true,
+	"vararg": true, "when": true,
+}
+
+// identifier returns name as a Pkl identifier, backtick-quoting it if needed.
+func identifier(name string) string {
+	if name == "" || keywords[name] {
+		return "`" + name + "`"
+	}
+	for i, r := range name {
+		if r == '_' || r == '$' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return "`" + name + "`"
+	}
+	return name
+}
+
+// quote returns s as a single-line Pkl string literal.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&b, `\u{%x}`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// quoteIndented returns s as a Pkl string literal for a value at indent. A
+// string spanning several lines is written as a multi-line string, whose
+// lines and closing delimiter are indented to indent.
+func quoteIndented(s, indent string) string {
+	if !strings.Contains(s, "\n") || strings.ContainsFunc(s, func(r rune) bool {
+		return r != '\n' && r != '\t' && unicode.IsControl(r)
+	}) {
+		return quote(s)
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"""`, `""\"`)
+	var b strings.Builder
+	b.WriteString(`"""` + "\n")
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" {
+			b.WriteString(indent + line)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(indent + `"""`)
+	return b.String()
+}
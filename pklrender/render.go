@@ -0,0 +1,371 @@
+// Package pklrender renders values of the generated kdeps types as Pkl source.
+//
+// Render emits a module that amends the kdeps module the value's type was
+// generated from, for example
+//
+//	amends "package://schema.kdeps.com/core@0.5.0#/Workflow.pkl"
+//
+//	AgentID = "myAgent"
+//	...
+//
+// Evaluating the output yields a value equal to the rendered one: properties
+// equal to their Pkl default are omitted, nil pointers to properties whose
+// default is not null are written as null, and everything else is written
+// explicitly. Output is deterministic; mapping entries are sorted by key.
+package pklrender
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets"
+	"github.com/kdeps/schema/internal/pkldecl"
+)
+
+const indentUnit = "  "
+
+var (
+	durationType = reflect.TypeOf(pkl.Duration{})
+	dataSizeType = reflect.TypeOf(pkl.DataSize{})
+	objectType   = reflect.TypeOf(pkl.Object{})
+)
+
+type options struct {
+	version string
+	amends  string
+}
+
+// Option configures Render.
+type Option func(*options)
+
+// WithSchemaVersion sets the version of the schema package in the amends
+// URI. It defaults to assets.SchemaVersion.
+func WithSchemaVersion(version string) Option {
+	return func(o *options) {
+		o.version = version
+	}
+}
+
+// WithAmends sets the amends URI, for example a relative path to a local
+// copy of the module, instead of the schema package URI.
+func WithAmends(uri string) Option {
+	return func(o *options) {
+		o.amends = uri
+	}
+}
+
+// ModuleURI returns the package URI of the kdeps module file, such as
+// "Workflow.pkl", in the given schema version.
+func ModuleURI(version, file string) string {
+	return fmt.Sprintf("package://schema.kdeps.com/core@%s#/%s", version, file)
+}
+
+// Render returns Pkl source for v, a value or pointer to a value of a type
+// generated from a kdeps module, such as workflow.WorkflowImpl or
+// resource.Resource.
+func Render(v any, opts ...Option) ([]byte, error) {
+	o := options{version: assets.SchemaVersion}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, fmt.Errorf("failed to render: nil value")
+	}
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("failed to render: nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("failed to render: %s is not a struct", rv.Type())
+	}
+
+	modules, err := pkldecl.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Pkl modules: %w", err)
+	}
+	m, class, err := modules.ClassOf(rv.Type())
+	if err != nil {
+		return nil, fmt.Errorf("failed to render: %w", err)
+	}
+	if class.Name != "" {
+		return nil, fmt.Errorf("failed to render: %s is the class %s, not a module", rv.Type(), class.Name)
+	}
+	amends := o.amends
+	if amends == "" {
+		amends = ModuleURI(o.version, m.File)
+	}
+
+	r := &renderer{modules: modules}
+	r.buf.WriteString("amends " + quote(amends) + "\n")
+	body := &renderer{modules: modules}
+	body.properties(rv, class, "")
+	if body.err != nil {
+		return nil, body.err
+	}
+	if body.buf.Len() > 0 {
+		r.buf.WriteString("\n")
+		r.buf.Write(body.buf.Bytes())
+	}
+	return r.buf.Bytes(), nil
+}
+
+type renderer struct {
+	modules pkldecl.Modules
+	buf     bytes.Buffer
+	err     error
+}
+
+func (r *renderer) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// properties writes the property assignments of the struct v, generated from
+// class, at the given indentation.
+func (r *renderer) properties(v reflect.Value, class *pkldecl.Class, indent string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("pkl")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		var prop *pkldecl.Property
+		if class != nil {
+			prop = class.Property(name)
+		}
+		r.property(identifier(name), v.Field(i), prop, indent)
+	}
+}
+
+// property writes `name = value`, or `name { ... }` to amend the default of a
+// non-nullable object, unless the value equals the Pkl default.
+func (r *renderer) property(name string, v reflect.Value, prop *pkldecl.Property, indent string) {
+	def := ""
+	if prop != nil {
+		def = prop.Default
+	}
+
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if def != "" && def != "null" {
+				r.buf.WriteString(indent + name + " = null\n")
+			}
+			return
+		}
+		if v.Kind() == reflect.Pointer {
+			if object(v.Elem().Type()) {
+				// A nullable object defaults to null, so it is created with new.
+				r.buf.WriteString(indent + name + " = ")
+				r.value(v.Elem(), indent)
+				r.buf.WriteString("\n")
+				return
+			}
+			v = v.Elem()
+		}
+	}
+
+	if object(v.Type()) && v.Type() != objectType {
+		// Amend the default: an empty Listing, Mapping or class instance.
+		body := r.body(v, indent+indentUnit)
+		if body != "" {
+			r.buf.WriteString(indent + name + " {\n" + body + indent + "}\n")
+		}
+		return
+	}
+
+	sub := &renderer{modules: r.modules}
+	sub.value(v, indent)
+	r.fail(sub.err)
+	if def != "" && sub.buf.String() == def {
+		return
+	}
+	r.buf.WriteString(indent + name + " = ")
+	r.buf.Write(sub.buf.Bytes())
+	r.buf.WriteString("\n")
+}
+
+// object reports whether values of t are rendered as object bodies.
+func object(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	case reflect.Struct:
+		return t != durationType && t != dataSizeType
+	}
+	return false
+}
+
+// body returns the members of the Listing, Mapping or object v.
+func (r *renderer) body(v reflect.Value, indent string) string {
+	sub := &renderer{modules: r.modules}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			sub.buf.WriteString(indent)
+			sub.value(v.Index(i), indent)
+			sub.buf.WriteString("\n")
+		}
+	case reflect.Map:
+		for _, key := range sortedKeys(v) {
+			sub.buf.WriteString(indent + "[")
+			sub.value(key, indent)
+			sub.buf.WriteString("] = ")
+			sub.value(v.MapIndex(key), indent)
+			sub.buf.WriteString("\n")
+		}
+	case reflect.Struct:
+		if v.Type() == objectType {
+			sub.dynamic(v.Interface().(pkl.Object), indent)
+			break
+		}
+		_, class, err := r.modules.ClassOf(v.Type())
+		if err != nil {
+			sub.fail(fmt.Errorf("failed to render: %w", err))
+			break
+		}
+		sub.properties(v, class, indent)
+	}
+	r.fail(sub.err)
+	return sub.buf.String()
+}
+
+// dynamic writes the members of a pkl.Object.
+func (r *renderer) dynamic(o pkl.Object, indent string) {
+	names := make([]string, 0, len(o.Properties))
+	for name := range o.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.buf.WriteString(indent + identifier(name) + " = ")
+		r.value(reflect.ValueOf(o.Properties[name]), indent)
+		r.buf.WriteString("\n")
+	}
+	entries := reflect.ValueOf(o.Entries)
+	for _, key := range sortedKeys(entries) {
+		r.buf.WriteString(indent + "[")
+		r.value(key, indent)
+		r.buf.WriteString("] = ")
+		r.value(entries.MapIndex(key), indent)
+		r.buf.WriteString("\n")
+	}
+	for _, e := range o.Elements {
+		r.buf.WriteString(indent)
+		r.value(reflect.ValueOf(e), indent)
+		r.buf.WriteString("\n")
+	}
+}
+
+// value writes the expression for v. Objects are written as `new { ... }`,
+// with the type inferred from the declaration, ending at indent.
+func (r *renderer) value(v reflect.Value, indent string) {
+	if !v.IsValid() {
+		r.buf.WriteString("null")
+		return
+	}
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			r.buf.WriteString("null")
+			return
+		}
+		r.value(v.Elem(), indent)
+		return
+	}
+
+	switch t := v.Type(); {
+	case t == durationType:
+		d := v.Interface().(pkl.Duration)
+		r.buf.WriteString(number(d.Value) + "." + d.Unit.String())
+		return
+	case t == dataSizeType:
+		d := v.Interface().(pkl.DataSize)
+		r.buf.WriteString(number(d.Value) + "." + d.Unit.String())
+		return
+	case t == objectType:
+		o := v.Interface().(pkl.Object)
+		body := r.body(v, indent+indentUnit)
+		r.buf.WriteString("new " + dynamicType(o) + " {" + block(body, indent) + "}")
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		r.buf.WriteString(quoteIndented(v.String(), indent))
+	case reflect.Bool:
+		r.buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		r.buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		r.buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		r.buf.WriteString(float(v.Float()))
+	case reflect.Slice, reflect.Array:
+		r.buf.WriteString("new Listing {" + block(r.body(v, indent+indentUnit), indent) + "}")
+	case reflect.Map:
+		r.buf.WriteString("new Mapping {" + block(r.body(v, indent+indentUnit), indent) + "}")
+	case reflect.Struct:
+		r.buf.WriteString("new {" + block(r.body(v, indent+indentUnit), indent) + "}")
+	default:
+		r.fail(fmt.Errorf("failed to render: unsupported type %s", v.Type()))
+	}
+}
+
+func block(body, indent string) string {
+	if body == "" {
+		return ""
+	}
+	return "\n" + body + indent
+}
+
+// dynamicType returns the class to instantiate for a pkl.Object.
+func dynamicType(o pkl.Object) string {
+	if o.Name == "Listing" || o.Name == "Mapping" {
+		return o.Name
+	}
+	return "Dynamic"
+}
+
+func sortedKeys(m reflect.Value) []reflect.Value {
+	if !m.IsValid() || m.Len() == 0 {
+		return nil
+	}
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+// number formats the value of a Duration or DataSize, e.g. 60 or 1.5.
+func number(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// float formats a Float literal, which always has a fraction or exponent.
+func float(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,207 @@
+package pklrender
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema"
+	"github.com/kdeps/schema/assets/pklsyntax"
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/web_server"
+	"github.com/kdeps/schema/gen/web_server/webservertype"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func testWorkflow() workflow.WorkflowImpl {
+	wf := workflow.WorkflowImpl{
+		AgentID:        "demo",
+		Description:    "Line one\nsays \"hi\" \\ bye\n\nend",
+		Website:        ptr("https://kdeps.com"),
+		Authors:        &[]string{"Alice", "Bob"},
+		Version:        "1.0.0",
+		TargetActionID: "respond",
+		Workflows:      []string{},
+	}
+	wf.Settings.APIServerMode = true
+	wf.Settings.APIServer = &apiserver.APIServerSettings{
+		HostIP:  "0.0.0.0",
+		PortNum: 3000,
+		Routes: []apiserver.APIServerRoutes{
+			{Path: "/api/v1/whois", Methods: []string{"GET", "POST"}},
+		},
+		CORS: apiserver.CORSConfig{
+			EnableCORS:       true,
+			AllowOrigins:     &[]string{},
+			AllowCredentials: true,
+			MaxAge:           pkl.Duration{Value: 1.5, Unit: pkl.Minute},
+		},
+	}
+	wf.Settings.WebServer = &webserver.WebServerSettings{
+		HostIP:  "127.0.0.1",
+		PortNum: 8080,
+		Routes: []webserver.WebServerRoutes{
+			{Path: "/ui", ServerType: webservertype.App, PublicPath: "/web", Command: ptr("npm start")},
+		},
+	}
+	wf.Settings.AgentSettings.Timezone = "Etc/UTC"
+	wf.Settings.AgentSettings.OllamaImageTag = "0.6.5"
+	wf.Settings.AgentSettings.Models = []string{"llama3.2"}
+	wf.Settings.AgentSettings.Env = &map[string]string{"b": "2", "a": "1"}
+	return wf
+}
+
+const wantWorkflow = `amends "package://schema.kdeps.com/core@0.5.0#/Workflow.pkl"
+
+AgentID = "demo"
+Description = """
+Line one
+says "hi" \\ bye
+
+end
+"""
+Website = "https://kdeps.com"
+Authors = new Listing {
+  "Alice"
+  "Bob"
+}
+TargetActionID = "respond"
+Settings {
+  APIServerMode = true
+  APIServer = new {
+    HostIP = "0.0.0.0"
+    Routes {
+      new {
+        Path = "/api/v1/whois"
+        Methods {
+          "GET"
+          "POST"
+        }
+      }
+    }
+    CORS {
+      EnableCORS = true
+      AllowOrigins = new Listing {}
+      MaxAge = 1.5.min
+    }
+  }
+  WebServer = new {
+    Routes {
+      new {
+        Path = "/ui"
+        AppPort = null
+        ServerType = "app"
+        Command = "npm start"
+      }
+    }
+  }
+  AgentSettings {
+    Models {
+      "llama3.2"
+    }
+    Env = new Mapping {
+      ["a"] = "1"
+      ["b"] = "2"
+    }
+  }
+}
+`
+
+func TestRender(t *testing.T) {
+	wf := testWorkflow()
+	got, err := Render(&wf, WithSchemaVersion("0.5.0"))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if string(got) != wantWorkflow {
+		t.Errorf("Unexpected output:\n%s", got)
+	}
+	if _, err := pklsyntax.Tokenize(string(got)); err != nil {
+		t.Errorf("Output does not tokenize: %v", err)
+	}
+
+	again, err := Render(wf, WithSchemaVersion("0.5.0"))
+	if err != nil || string(again) != string(got) {
+		t.Errorf("Expected deterministic output, got %v", err)
+	}
+}
+
+func TestRenderOptions(t *testing.T) {
+	got, err := Render(workflow.WorkflowImpl{AgentID: "a", Version: "1.0.0"}, WithAmends("Workflow.pkl"))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.HasPrefix(string(got), "amends \"Workflow.pkl\"\n\nAgentID = \"a\"\nDescription = \"\"\n") {
+		t.Errorf("Unexpected output:\n%s", got)
+	}
+}
+
+func TestRenderRejectsNonModules(t *testing.T) {
+	for _, v := range []any{nil, (*workflow.WorkflowImpl)(nil), "text", apiserver.CORSConfig{}, struct{}{}} {
+		if _, err := Render(v); err == nil {
+			t.Errorf("Expected an error rendering %T", v)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{"tab\there", `"tab\there"`},
+		{"bell\a\nline", `"bell\u{7}\nline"`},
+		{"a\n\"\"\"", "\"\"\"\na\n\"\"\\\"\n\"\"\""},
+		{2.0, "2.0"},
+		{1e21, "1e+21"},
+		{[]any{int64(1), nil, true}, "new Listing {\n  1\n  null\n  true\n}"},
+		{pkl.DataSize{Value: 2, Unit: pkl.Megabytes}, "2.mb"},
+		{pkl.Object{Properties: map[string]any{"class": "x"}, Elements: []any{"y"}}, "new Dynamic {\n  `class` = \"x\"\n  \"y\"\n}"},
+	}
+	for _, tt := range tests {
+		r := &renderer{}
+		r.value(reflect.ValueOf(tt.value), "")
+		if r.err != nil || r.buf.String() != tt.want {
+			t.Errorf("value(%#v) = %q, %v, want %q", tt.value, r.buf.String(), r.err, tt.want)
+		}
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := map[string]string{"AgentID": "AgentID", "_x$1": "_x$1", "new": "`new`", "1a": "`1a`", "a-b": "`a-b`", "": "``"}
+	for in, want := range tests {
+		if got := identifier(in); got != want {
+			t.Errorf("identifier(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("pkl"); err != nil && os.Getenv("PKL_EXEC") == "" {
+		t.Skip("pkl binary not available")
+	}
+
+	want := testWorkflow()
+	src, err := Render(want)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	loader := schema.NewLoader(schema.WithTimeout(time.Minute))
+	defer loader.Close()
+	got, err := schema.Load(context.Background(), loader, pkl.TextSource(string(src)), workflow.Load)
+	if err != nil {
+		t.Fatalf("Load failed: %v\n%s", err, src)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Round trip changed the workflow:\n got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,377 @@
+// Package router matches requests against the routes of an API server and
+// selects the resources that run for them.
+//
+// Route paths are made of slash-separated segments, each either literal, a
+// parameter such as ":id" matching one segment, or, as the last segment only,
+// a wildcard "*" or "*name" matching the rest of the path:
+//
+//	/api/v1/whois
+//	/users/:id/posts/:post
+//	/files/*path
+//
+// When several routes match a path, the most specific one wins: segments are
+// compared from the left, and a literal beats a parameter, which beats a
+// wildcard. Trailing slashes are ignored.
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/kdeps/schema/agent"
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/validate"
+)
+
+var (
+	// ErrNotFound is returned for a path that no route matches.
+	ErrNotFound = errors.New("no route matches the path")
+	// ErrMethodNotAllowed is matched by a *MethodNotAllowedError.
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	// ErrNoAPIServer is returned by FromAgent for an agent without API
+	// server settings.
+	ErrNoAPIServer = errors.New("agent has no API server settings")
+)
+
+// MethodNotAllowedError is returned for a path that routes match, but not
+// with the method of the request.
+type MethodNotAllowedError struct {
+	Method string
+	// Allowed are the methods of the routes matching the path, sorted.
+	Allowed []string
+}
+
+func (e *MethodNotAllowedError) Error() string {
+	return fmt.Sprintf("method %s not allowed, use one of: %s", e.Method, strings.Join(e.Allowed, ", "))
+}
+
+// Is reports whether target is ErrMethodNotAllowed.
+func (e *MethodNotAllowedError) Is(target error) bool {
+	return target == ErrMethodNotAllowed
+}
+
+// segment kinds, in increasing order of generality.
+const (
+	literal = iota
+	param
+	wildcard
+)
+
+type segment struct {
+	kind int
+	// value is the literal text, or the name of a parameter or wildcard.
+	value string
+}
+
+// Pattern is a parsed route path.
+type Pattern struct {
+	path     string
+	segments []segment
+}
+
+type route struct {
+	route   apiserver.APIServerRoutes
+	pattern Pattern
+	methods map[string]bool
+}
+
+// Router matches requests against a list of routes.
+type Router struct {
+	routes    []*route
+	resources []*resource.Resource
+}
+
+// Match is the outcome of a successful match.
+type Match struct {
+	// Route is the matching route, as configured.
+	Route apiserver.APIServerRoutes
+	// Params holds the values of the path parameters, and of the wildcard
+	// under its name, or "*" if it has none.
+	Params map[string]string
+	// Resources are the resources allowed to run for the request by their
+	// RestrictToRoutes and RestrictToHTTPMethods, in the order given to New.
+	Resources []*resource.Resource
+}
+
+// New returns a Router for routes, selecting among resources. It fails for
+// an invalid path, an unsupported method, or two routes of the same shape
+// sharing a method.
+func New(routes []apiserver.APIServerRoutes, resources []*resource.Resource) (*Router, error) {
+	r := &Router{resources: resources}
+	var errs []error
+	seen := make(map[string]string)
+	for _, rt := range routes {
+		p, err := ParsePattern(rt.Path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		entry := &route{route: rt, pattern: p, methods: make(map[string]bool)}
+		for _, m := range rt.Methods {
+			if err := validate.RouteMethod(m); err != nil {
+				errs = append(errs, fmt.Errorf("route %s: %w", rt.Path, err))
+				continue
+			}
+			method := strings.ToUpper(m)
+			key := method + " " + p.shape()
+			if other, ok := seen[key]; ok {
+				errs = append(errs, fmt.Errorf("routes %s and %s both match %s requests to the same paths", other, rt.Path, method))
+				continue
+			}
+			seen[key] = rt.Path
+			entry.methods[method] = true
+		}
+		r.routes = append(r.routes, entry)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to build router: %w", errors.Join(errs...))
+	}
+	return r, nil
+}
+
+// FromAgent returns the Router of the API server routes of a, selecting among
+// its resources sorted by ActionID.
+func FromAgent(a *agent.Agent) (*Router, error) {
+	if a.Settings.APIServer == nil {
+		return nil, ErrNoAPIServer
+	}
+	ids := a.ActionIDs()
+	resources := make([]*resource.Resource, len(ids))
+	for i, id := range ids {
+		resources[i] = a.Resources[id]
+	}
+	return New(a.Settings.APIServer.Routes, resources)
+}
+
+// Match returns the route matching method and path, with the resources that
+// run for it. It returns ErrNotFound if no route matches path, and a
+// *MethodNotAllowedError if routes match path but none allows method.
+func (r *Router) Match(method, path string) (*Match, error) {
+	method = strings.ToUpper(method)
+	segments := split(path)
+
+	var best *route
+	var bestParams map[string]string
+	allowed := make(map[string]bool)
+	for _, rt := range r.routes {
+		params, ok := rt.pattern.match(segments)
+		if !ok {
+			continue
+		}
+		for m := range rt.methods {
+			allowed[m] = true
+		}
+		if rt.methods[method] && (best == nil || rt.pattern.moreSpecific(best.pattern)) {
+			best, bestParams = rt, params
+		}
+	}
+	if best == nil {
+		if len(allowed) == 0 {
+			return nil, ErrNotFound
+		}
+		methods := make([]string, 0, len(allowed))
+		for m := range allowed {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		return nil, &MethodNotAllowedError{Method: method, Allowed: methods}
+	}
+	return &Match{
+		Route:     best.route,
+		Params:    bestParams,
+		Resources: Allowed(r.resources, method, best.route.Path),
+	}, nil
+}
+
+// Allowed returns the resources that run for a request with method matched
+// by the route routePath. An entry of RestrictToRoutes selects the request if
+// it is routePath, ignoring a trailing slash; an entry of
+// RestrictToHTTPMethods selects it if it is method, ignoring case. Unset or
+// empty restrictions select every request.
+func Allowed(resources []*resource.Resource, method, routePath string) []*resource.Resource {
+	var ret []*resource.Resource
+	for _, res := range resources {
+		if routes := res.Run.RestrictToRoutes; routes != nil && len(*routes) > 0 && !anyRoute(*routes, routePath) {
+			continue
+		}
+		if methods := res.Run.RestrictToHTTPMethods; methods != nil && len(*methods) > 0 && !anyMethod(*methods, method) {
+			continue
+		}
+		ret = append(ret, res)
+	}
+	return ret
+}
+
+func anyRoute(routes []string, routePath string) bool {
+	routePath = trimSlash(routePath)
+	for _, rt := range routes {
+		if trimSlash(rt) == routePath {
+			return true
+		}
+	}
+	return false
+}
+
+func trimSlash(path string) string {
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return trimmed
+	}
+	return path
+}
+
+func anyMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePattern parses a route path.
+func ParsePattern(path string) (Pattern, error) {
+	if !strings.HasPrefix(path, "/") {
+		return Pattern{}, fmt.Errorf("invalid route %q: the path must start with /", path)
+	}
+	p := Pattern{path: path}
+	names := make(map[string]bool)
+	parts := split(path)
+	for i, part := range parts {
+		var s segment
+		switch {
+		case strings.HasPrefix(part, ":"):
+			s = segment{kind: param, value: part[1:]}
+			if s.value == "" {
+				return Pattern{}, fmt.Errorf("invalid route %q: empty parameter name", path)
+			}
+		case strings.HasPrefix(part, "*"):
+			s = segment{kind: wildcard, value: part[1:]}
+			if s.value == "" {
+				s.value = "*"
+			}
+			if i != len(parts)-1 {
+				return Pattern{}, fmt.Errorf("invalid route %q: a wildcard must be the last segment", path)
+			}
+		default:
+			s = segment{kind: literal, value: part}
+		}
+		if s.kind != literal {
+			if names[s.value] {
+				return Pattern{}, fmt.Errorf("invalid route %q: duplicate parameter %q", path, s.value)
+			}
+			names[s.value] = true
+		}
+		p.segments = append(p.segments, s)
+	}
+	return p, nil
+}
+
+// String returns the path p was parsed from.
+func (p Pattern) String() string {
+	return p.path
+}
+
+// Params returns the names of the parameters of p, and of its wildcard, in
+// order.
+func (p Pattern) Params() []string {
+	var names []string
+	for _, s := range p.segments {
+		if s.kind != literal {
+			names = append(names, s.value)
+		}
+	}
+	return names
+}
+
+// OpenAPI returns p in OpenAPI path template syntax, with parameters and the
+// wildcard written as "{name}".
+func (p Pattern) OpenAPI() string {
+	if len(p.segments) == 0 {
+		return "/"
+	}
+	var b strings.Builder
+	for _, s := range p.segments {
+		b.WriteByte('/')
+		if s.kind == literal {
+			b.WriteString(s.value)
+		} else {
+			b.WriteString("{" + s.value + "}")
+		}
+	}
+	return b.String()
+}
+
+// shape returns p with parameter names erased, so that patterns matching the
+// same paths have the same shape.
+func (p Pattern) shape() string {
+	var b strings.Builder
+	for _, s := range p.segments {
+		switch s.kind {
+		case literal:
+			b.WriteString("/" + s.value)
+		case param:
+			b.WriteString("/:")
+		case wildcard:
+			b.WriteString("/*")
+		}
+	}
+	return b.String()
+}
+
+func (p Pattern) match(segments []string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for i, s := range p.segments {
+		if s.kind == wildcard {
+			params[s.value] = strings.Join(segments[i:], "/")
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		switch s.kind {
+		case literal:
+			if segments[i] != s.value {
+				return nil, false
+			}
+		case param:
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[s.value] = segments[i]
+		}
+	}
+	if len(segments) != len(p.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// moreSpecific reports whether p is preferred to q for a path both match.
+func (p Pattern) moreSpecific(q Pattern) bool {
+	for i := 0; i < len(p.segments) && i < len(q.segments); i++ {
+		if p.segments[i].kind != q.segments[i].kind {
+			return p.segments[i].kind < q.segments[i].kind
+		}
+	}
+	return len(p.segments) > len(q.segments)
+}
+
+// split returns the unescaped segments of path, ignoring leading and
+// trailing slashes.
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if unescaped, err := url.PathUnescape(part); err == nil {
+			parts[i] = unescaped
+		}
+	}
+	return parts
+}
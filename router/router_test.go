@@ -0,0 +1,179 @@
+package router
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kdeps/schema/agent"
+	"github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+func list(s ...string) *[]string {
+	return &s
+}
+
+func routes() []apiserver.APIServerRoutes {
+	return []apiserver.APIServerRoutes{
+		{Path: "/api/v1/whois", Methods: []string{"GET", "post"}},
+		{Path: "/users/:id", Methods: []string{"GET", "DELETE"}},
+		{Path: "/users/me", Methods: []string{"GET"}},
+		{Path: "/users/:id/posts/:post", Methods: []string{"GET"}},
+		{Path: "/files/*path", Methods: []string{"GET", "PUT"}},
+		{Path: "/files/:name", Methods: []string{"GET"}},
+		{Path: "/static/*", Methods: []string{"GET"}},
+		{Path: "/", Methods: []string{"GET"}},
+	}
+}
+
+func resources() []*resource.Resource {
+	return []*resource.Resource{
+		{ActionID: "any"},
+		{ActionID: "users", Run: resource.ResourceAction{RestrictToRoutes: list("/users/:id")}},
+		{ActionID: "me", Run: resource.ResourceAction{RestrictToRoutes: list("/users/me")}},
+		{ActionID: "deletes", Run: resource.ResourceAction{RestrictToHTTPMethods: list("delete")}},
+		{ActionID: "files", Run: resource.ResourceAction{RestrictToRoutes: list("/files/*path/"), RestrictToHTTPMethods: list("PUT")}},
+		{ActionID: "empty", Run: resource.ResourceAction{RestrictToRoutes: list(), RestrictToHTTPMethods: list()}},
+	}
+}
+
+func ids(rs []*resource.Resource) string {
+	var s []string
+	for _, r := range rs {
+		s = append(s, r.ActionID)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestMatch(t *testing.T) {
+	r, err := New(routes(), resources())
+	if err != nil {
+		t.Fatalf("Failed to build router: %v", err)
+	}
+	tests := []struct {
+		method, path string
+		route        string
+		params       map[string]string
+		resources    string
+	}{
+		{"POST", "/api/v1/whois", "/api/v1/whois", map[string]string{}, "any empty"},
+		{"get", "/api/v1/whois/", "/api/v1/whois", map[string]string{}, "any empty"},
+		{"GET", "/users/42", "/users/:id", map[string]string{"id": "42"}, "any users empty"},
+		{"DELETE", "/users/42", "/users/:id", map[string]string{"id": "42"}, "any users deletes empty"},
+		{"GET", "/users/me", "/users/me", map[string]string{}, "any me empty"},
+		// The literal route does not allow DELETE, so the parameter route does.
+		{"DELETE", "/users/me", "/users/:id", map[string]string{"id": "me"}, "any users deletes empty"},
+		{"GET", "/users/a%2Fb/posts/7", "/users/:id/posts/:post", map[string]string{"id": "a/b", "post": "7"}, "any empty"},
+		{"GET", "/files/report.pdf", "/files/:name", map[string]string{"name": "report.pdf"}, "any empty"},
+		{"GET", "/files/a/b.txt", "/files/*path", map[string]string{"path": "a/b.txt"}, "any empty"},
+		{"PUT", "/files/report.pdf", "/files/*path", map[string]string{"path": "report.pdf"}, "any files empty"},
+		{"GET", "/static", "/static/*", map[string]string{"*": ""}, "any empty"},
+		{"GET", "/", "/", map[string]string{}, "any empty"},
+	}
+	for _, tt := range tests {
+		m, err := r.Match(tt.method, tt.path)
+		if err != nil {
+			t.Errorf("%s %s: %v", tt.method, tt.path, err)
+			continue
+		}
+		if m.Route.Path != tt.route {
+			t.Errorf("%s %s: matched %s, want %s", tt.method, tt.path, m.Route.Path, tt.route)
+		}
+		if !reflect.DeepEqual(m.Params, tt.params) {
+			t.Errorf("%s %s: params %v, want %v", tt.method, tt.path, m.Params, tt.params)
+		}
+		if got := ids(m.Resources); got != tt.resources {
+			t.Errorf("%s %s: resources %q, want %q", tt.method, tt.path, got, tt.resources)
+		}
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	r, err := New(routes(), resources())
+	if err != nil {
+		t.Fatalf("Failed to build router: %v", err)
+	}
+	for _, path := range []string{"/api", "/api/v1/whois/extra", "/users", "/users/42/posts"} {
+		if _, err := r.Match("GET", path); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GET %s: expected ErrNotFound, got %v", path, err)
+		}
+	}
+
+	_, err = r.Match("PATCH", "/users/me")
+	var notAllowed *MethodNotAllowedError
+	if !errors.As(err, &notAllowed) || !errors.Is(err, ErrMethodNotAllowed) {
+		t.Fatalf("Expected a MethodNotAllowedError, got %v", err)
+	}
+	if want := []string{"DELETE", "GET"}; !reflect.DeepEqual(notAllowed.Allowed, want) {
+		t.Errorf("Allowed = %v, want %v", notAllowed.Allowed, want)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []apiserver.APIServerRoutes
+	}{
+		{"relative", []apiserver.APIServerRoutes{{Path: "api", Methods: []string{"GET"}}}},
+		{"empty param", []apiserver.APIServerRoutes{{Path: "/users/:", Methods: []string{"GET"}}}},
+		{"duplicate param", []apiserver.APIServerRoutes{{Path: "/a/:id/b/:id", Methods: []string{"GET"}}}},
+		{"inner wildcard", []apiserver.APIServerRoutes{{Path: "/a/*/b", Methods: []string{"GET"}}}},
+		{"method", []apiserver.APIServerRoutes{{Path: "/a", Methods: []string{"TRACE"}}}},
+		{"ambiguous", []apiserver.APIServerRoutes{
+			{Path: "/users/:id", Methods: []string{"GET"}},
+			{Path: "/users/:name/", Methods: []string{"get"}},
+		}},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.routes, nil); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	// The same path with other methods is not a conflict.
+	if _, err := New([]apiserver.APIServerRoutes{
+		{Path: "/users/:id", Methods: []string{"GET"}},
+		{Path: "/users/:id", Methods: []string{"POST"}},
+	}, nil); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	p, err := ParsePattern("/users/:id/files/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.OpenAPI(); got != "/users/{id}/files/{*}" {
+		t.Errorf("OpenAPI() = %q", got)
+	}
+	if got := p.Params(); !reflect.DeepEqual(got, []string{"id", "*"}) {
+		t.Errorf("Params() = %v", got)
+	}
+	if root, _ := ParsePattern("/"); root.OpenAPI() != "/" || root.String() != "/" {
+		t.Errorf("Unexpected root pattern %v", root)
+	}
+}
+
+func TestFromAgent(t *testing.T) {
+	wf := workflow.WorkflowImpl{}
+	a := &agent.Agent{
+		Workflow:  wf,
+		Resources: map[string]*resource.Resource{"b": {ActionID: "b"}, "a": {ActionID: "a"}},
+	}
+	if _, err := FromAgent(a); !errors.Is(err, ErrNoAPIServer) {
+		t.Errorf("Expected ErrNoAPIServer, got %v", err)
+	}
+	a.Settings.APIServer = &apiserver.APIServerSettings{Routes: routes()}
+	r, err := FromAgent(a)
+	if err != nil {
+		t.Fatalf("Failed to build router: %v", err)
+	}
+	m, err := r.Match("GET", "/")
+	if err != nil || ids(m.Resources) != "a b" {
+		t.Errorf("Unexpected match %+v, %v", m, err)
+	}
+}
@@ -0,0 +1,156 @@
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kdeps/schema/assets"
+	"github.com/kdeps/schema/assets/pklsyntax"
+)
+
+// pklRule is a validation function found in a Pkl module.
+type pklRule struct {
+	module, class, function string
+	regex, pattern, message string
+}
+
+func (r pklRule) key() string {
+	return fmt.Sprintf("%s %s.%s", r.module, r.class, r.function)
+}
+
+// scanRules finds every `hidden f = (str) -> if (str.matches(R)) true else throw("...")`
+// in module together with the pattern of the Regex property R of the same scope.
+func scanRules(t *testing.T, module string) []pklRule {
+	t.Helper()
+	data, err := assets.GetPKLFile(module)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", module, err)
+	}
+	tokens, err := pklsyntax.Tokenize(string(data))
+	if err != nil {
+		t.Fatalf("Failed to tokenize %s: %v", module, err)
+	}
+
+	type scope struct {
+		class string
+		depth int
+	}
+	var (
+		scopes   = []scope{{}}
+		depth    int
+		patterns = make(map[string]string)
+		rules    []pklRule
+	)
+	text := func(i int) string {
+		if i < len(tokens) {
+			return tokens[i].Text
+		}
+		return ""
+	}
+	for i, tok := range tokens {
+		switch {
+		case tok.Kind == pklsyntax.Punct && tok.Text == "{":
+			depth++
+			continue
+		case tok.Kind == pklsyntax.Punct && tok.Text == "}":
+			if len(scopes) > 1 && scopes[len(scopes)-1].depth == depth {
+				scopes = scopes[:len(scopes)-1]
+			}
+			depth--
+			continue
+		case tok.Text == "class" && tok.Kind == pklsyntax.Ident:
+			// The class body opens at the next brace.
+			scopes = append(scopes, scope{class: text(i + 1), depth: depth + 1})
+			continue
+		case tok.Text != "hidden" || text(i+2) != "=":
+			continue
+		}
+
+		class := scopes[len(scopes)-1].class
+		name := text(i + 1)
+		if text(i+3) == "Regex" && text(i+4) == "(" && i+5 < len(tokens) {
+			patterns[class+"."+name] = tokens[i+5].Value
+			continue
+		}
+
+		// hidden f = ( str ) -> if ( str . matches ( R ) ) true else throw ( "msg" )
+		body := tokens[i+3:]
+		var regex, message string
+		for j := 0; j+3 < len(body) && body[j].Text != "hidden"; j++ {
+			if body[j].Text == "matches" && body[j+1].Text == "(" {
+				regex = body[j+2].Text
+			}
+			if body[j].Text == "throw" && body[j+1].Text == "(" && body[j+2].Kind == pklsyntax.String {
+				message = body[j+2].Value
+				break
+			}
+		}
+		if regex == "" || message == "" {
+			continue
+		}
+		rules = append(rules, pklRule{
+			module:   module,
+			class:    class,
+			function: name,
+			regex:    regex,
+			message:  message,
+		})
+	}
+	for i := range rules {
+		rules[i].pattern = patterns[rules[i].class+"."+rules[i].regex]
+	}
+	return rules
+}
+
+func TestRulesMatchPklModules(t *testing.T) {
+	modules, err := assets.ListPKLFiles()
+	if err != nil {
+		t.Fatalf("Failed to list modules: %v", err)
+	}
+
+	found := make(map[string]pklRule)
+	for _, module := range modules {
+		if strings.Contains(module, "/") || !strings.HasSuffix(module, ".pkl") {
+			continue
+		}
+		for _, r := range scanRules(t, module) {
+			found[r.key()] = r
+		}
+	}
+	if len(found) == 0 {
+		t.Fatal("No validation functions found in the Pkl modules")
+	}
+
+	covered := make(map[string]bool)
+	for _, rule := range Rules() {
+		key := pklRule{module: rule.Module, class: rule.Class, function: rule.Function}.key()
+		covered[key] = true
+		pkl, ok := found[key]
+		if !ok {
+			t.Errorf("%s: %s no longer exists in the Pkl modules", rule.Name, key)
+			continue
+		}
+		if pkl.regex != rule.Regex {
+			t.Errorf("%s: Pkl matches %s, Go declares %s", rule.Name, pkl.regex, rule.Regex)
+		}
+		if pkl.pattern != rule.Pattern {
+			t.Errorf("%s: Pkl pattern %q, Go pattern %q", rule.Name, pkl.pattern, rule.Pattern)
+		}
+		if pkl.message != rule.Message {
+			t.Errorf("%s: Pkl message %q, Go message %q", rule.Name, pkl.message, rule.Message)
+		}
+	}
+
+	var missing []string
+	for key := range found {
+		if !covered[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		t.Errorf("Pkl validation function %s has no Go rule", key)
+	}
+}
@@ -0,0 +1,266 @@
+// Package validate checks values against the constraints that the kdeps Pkl
+// modules enforce with regular expressions, without evaluating Pkl.
+//
+// Every Rule mirrors one hidden isValid* function of a Pkl module: the same
+// pattern and, on failure, an *Error whose message is the text of the Pkl
+// throw. The conformance test keeps both in lockstep with the embedded modules.
+package validate
+
+import (
+	"regexp"
+)
+
+// Rule is a constraint declared in a Pkl module as
+//
+//	hidden <Regex> = Regex(#"<Pattern>"#)
+//	hidden <Function> = (str) -> if (str.matches(<Regex>)) true else throw("<Message>")
+type Rule struct {
+	// Name identifies the rule, e.g. "ActionID".
+	Name string
+	// Module is the Pkl module declaring the rule, e.g. "Resource.pkl".
+	Module string
+	// Class is the class declaring the rule, or empty for module-level rules.
+	Class string
+	// Regex is the name of the Pkl Regex property.
+	Regex string
+	// Pattern is the Pkl regular expression source.
+	Pattern string
+	// Function is the name of the Pkl validation function.
+	Function string
+	// Message is the message thrown by the Pkl validation function.
+	Message string
+
+	re *regexp.Regexp
+}
+
+func newRule(r Rule) *Rule {
+	// String.matches in Pkl must match the entire string, unlike Go's MatchString.
+	r.re = regexp.MustCompile(`^(?:` + r.Pattern + `)$`)
+	return &r
+}
+
+// Match reports whether s satisfies the rule.
+func (r *Rule) Match(s string) bool {
+	return r.re.MatchString(s)
+}
+
+// Check returns nil if s satisfies the rule and an *Error otherwise.
+func (r *Rule) Check(s string) error {
+	if r.Match(s) {
+		return nil
+	}
+	return &Error{Rule: r, Value: s}
+}
+
+// Error is returned for a value that violates a Rule. Its message is the
+// message of the corresponding Pkl throw.
+type Error struct {
+	Rule  *Rule
+	Value string
+}
+
+func (e *Error) Error() string {
+	return e.Rule.Message
+}
+
+const (
+	actionPattern = `^(\w+|@\w+(/[\w-]+)(:[\w.]+)?)$`
+	envPattern    = `^[a-zA-Z_]\w*$`
+)
+
+var (
+	// ActionIDRule validates Resource.ActionID.
+	ActionIDRule = newRule(Rule{
+		Name:     "ActionID",
+		Module:   "Resource.pkl",
+		Regex:    "ActionStringRegex",
+		Pattern:  actionPattern,
+		Function: "isValidActionID",
+		Message:  "Error: Invalid id name: The id contains invalid characters. Please ensure it only includes alphanumeric characters (letters and numbers) and is not empty.",
+	})
+
+	// DependencyRule validates each entry of Resource.Requires.
+	DependencyRule = newRule(Rule{
+		Name:     "Dependency",
+		Module:   "Resource.pkl",
+		Regex:    "ActionStringRegex",
+		Pattern:  actionPattern,
+		Function: "isValidDependency",
+		Message:  "Action must be either a simple alphanumeric string or start with `@`, followed by `/action` and an optional `:version` (e.g., `@agent/action:1.0.0`).",
+	})
+
+	// AgentIDRule validates Workflow.AgentID.
+	AgentIDRule = newRule(Rule{
+		Name:     "AgentID",
+		Module:   "Workflow.pkl",
+		Regex:    "NameStringRegex",
+		Pattern:  `(^\w+$)`,
+		Function: "isValidName",
+		Message:  "Error: Invalid name: The name contains invalid characters. Please ensure it only includes alphanumeric characters (letters and numbers) and is not empty.",
+	})
+
+	// TargetActionIDRule validates Workflow.TargetActionID.
+	TargetActionIDRule = newRule(Rule{
+		Name:     "TargetActionID",
+		Module:   "Workflow.pkl",
+		Regex:    "ActionStringRegex",
+		Pattern:  actionPattern,
+		Function: "isValidAction",
+		Message:  "Default action must be either a simple alphanumeric string or start with `@`, followed by `/action` and an optional `:version` (e.g., `@agent/action:1.0.0`).",
+	})
+
+	// WorkflowRule validates each entry of Workflow.Workflows.
+	WorkflowRule = newRule(Rule{
+		Name:     "Workflow",
+		Module:   "Workflow.pkl",
+		Regex:    "WorkflowStringRegex",
+		Pattern:  `^@[\w-]+(/[\w-]+)?(:[\w.]+)?$`,
+		Function: "isValidWorkflow",
+		Message:  "External workflows must start with `@`, followed by a package name, with an optional `/action` path segment and an optional `:version` (e.g., `@example`, `@example/action`, or `@example/action:1.0.0`). Ensure your input matches this format.",
+	})
+
+	// VersionRule validates Workflow.Version.
+	VersionRule = newRule(Rule{
+		Name:     "Version",
+		Module:   "Workflow.pkl",
+		Regex:    "VersionStringRegex",
+		Pattern:  `^(\d+\.)?(\d+\.)?(\*|\d+)$`,
+		Function: "isValidVersion",
+		Message:  "Error: Invalid version format. Expected format: major.minor.patch or major.minor.",
+	})
+
+	// ExecEnvRule validates the keys of ResourceExec.Env.
+	ExecEnvRule = newRule(Rule{
+		Name:     "ExecEnv",
+		Module:   "Exec.pkl",
+		Class:    "ResourceExec",
+		Regex:    "EnvStringRegex",
+		Pattern:  envPattern,
+		Function: "isValidEnv",
+		Message:  "Error: Invalid env name: The env name contains invalid characters. Please ensure it only includes alphanumeric characters (letters and numbers), does not start with a number, and is not empty.",
+	})
+
+	// PythonEnvRule validates the keys of ResourcePython.Env.
+	PythonEnvRule = newRule(Rule{
+		Name:     "PythonEnv",
+		Module:   "Python.pkl",
+		Class:    "ResourcePython",
+		Regex:    "EnvStringRegex",
+		Pattern:  envPattern,
+		Function: "isValidEnv",
+		Message:  "Error: Invalid environment variable name. Ensure it includes only alphanumeric characters or underscores, starts with a letter or underscore, and is not empty.",
+	})
+
+	// DockerParamRule validates the keys of DockerSettings.Args and DockerSettings.Env.
+	DockerParamRule = newRule(Rule{
+		Name:     "DockerParam",
+		Module:   "Docker.pkl",
+		Class:    "DockerSettings",
+		Regex:    "ParamStringRegex",
+		Pattern:  envPattern,
+		Function: "isValidParams",
+		Message:  "Error: Invalid params name: The params name contains invalid characters. Please ensure it only includes alphanumeric characters (letters and numbers), does not start with a number, and is not empty.",
+	})
+
+	// RouteMethodRule validates each entry of APIServerRoutes.Methods.
+	RouteMethodRule = newRule(Rule{
+		Name:     "RouteMethod",
+		Module:   "APIServer.pkl",
+		Class:    "APIServerRoutes",
+		Regex:    "APIServerMethodRegex",
+		Pattern:  `^(?i:(GET|POST|PUT|PATCH|OPTIONS|DELETE|HEAD))`,
+		Function: "isValidHTTPMethod",
+		Message:  "Error: Unsupported HTTP method. The provided HTTP method is not supported. Please use one of the following methods: GET, POST, PUT, PATCH, DELETE, OPTIONS, or HEAD.",
+	})
+
+	// CORSMethodRule validates each entry of CORSConfig.AllowMethods.
+	CORSMethodRule = newRule(Rule{
+		Name:     "CORSMethod",
+		Module:   "APIServer.pkl",
+		Class:    "CORSConfig",
+		Regex:    "MethodRegex",
+		Pattern:  `^(?i:(GET|POST|PUT|PATCH|OPTIONS|DELETE|HEAD))`,
+		Function: "isValidHTTPMethod",
+		Message:  "Unsupported HTTP method. Use: GET, POST, PUT, PATCH, DELETE, OPTIONS, HEAD",
+	})
+
+	// RequestMethodRule validates APIServerRequest.Method.
+	RequestMethodRule = newRule(Rule{
+		Name:     "RequestMethod",
+		Module:   "APIServerRequest.pkl",
+		Regex:    "apiMethodRegex",
+		Pattern:  `^(?i:(GET|POST|PUT|PATCH|OPTIONS|DELETE|HEAD))`,
+		Function: "isValidHTTPMethod",
+		Message:  "Error: Invalid HTTP method. The provided HTTP method is not supported. Please use one of the following methods: GET, POST, PUT, PATCH, DELETE, OPTIONS, or HEAD.",
+	})
+
+	// HTTPClientMethodRule validates ResourceHTTPClient.Method. Unlike the
+	// API server rules it does not accept OPTIONS.
+	HTTPClientMethodRule = newRule(Rule{
+		Name:     "HTTPClientMethod",
+		Module:   "HTTP.pkl",
+		Class:    "ResourceHTTPClient",
+		Regex:    "ApiMethodRegex",
+		Pattern:  `^(?i:(GET|POST|PUT|PATCH|DELETE|HEAD))`,
+		Function: "isValidHTTPMethod",
+		Message:  "Error: Invalid HTTP method. The provided HTTP method is not supported. Please use one of the following methods: GET, POST, PUT, PATCH, DELETE, or HEAD.",
+	})
+)
+
+// Rules returns every rule.
+func Rules() []*Rule {
+	return []*Rule{
+		ActionIDRule,
+		DependencyRule,
+		AgentIDRule,
+		TargetActionIDRule,
+		WorkflowRule,
+		VersionRule,
+		ExecEnvRule,
+		PythonEnvRule,
+		DockerParamRule,
+		RouteMethodRule,
+		CORSMethodRule,
+		RequestMethodRule,
+		HTTPClientMethodRule,
+	}
+}
+
+// ActionID checks a Resource.ActionID.
+func ActionID(s string) error { return ActionIDRule.Check(s) }
+
+// Dependency checks an entry of Resource.Requires.
+func Dependency(s string) error { return DependencyRule.Check(s) }
+
+// AgentID checks a Workflow.AgentID.
+func AgentID(s string) error { return AgentIDRule.Check(s) }
+
+// TargetActionID checks a Workflow.TargetActionID.
+func TargetActionID(s string) error { return TargetActionIDRule.Check(s) }
+
+// Workflow checks an entry of Workflow.Workflows.
+func Workflow(s string) error { return WorkflowRule.Check(s) }
+
+// Version checks a Workflow.Version.
+func Version(s string) error { return VersionRule.Check(s) }
+
+// ExecEnv checks an environment variable name of an exec resource.
+func ExecEnv(s string) error { return ExecEnvRule.Check(s) }
+
+// PythonEnv checks an environment variable name of a python resource.
+func PythonEnv(s string) error { return PythonEnvRule.Check(s) }
+
+// DockerParam checks a Docker build argument or environment variable name.
+func DockerParam(s string) error { return DockerParamRule.Check(s) }
+
+// RouteMethod checks an HTTP method of an API server route.
+func RouteMethod(s string) error { return RouteMethodRule.Check(s) }
+
+// CORSMethod checks an HTTP method allowed by the CORS configuration.
+func CORSMethod(s string) error { return CORSMethodRule.Check(s) }
+
+// RequestMethod checks the HTTP method of an API server request.
+func RequestMethod(s string) error { return RequestMethodRule.Check(s) }
+
+// HTTPClientMethod checks the HTTP method of an HTTP client resource.
+func HTTPClientMethod(s string) error { return HTTPClientMethodRule.Check(s) }
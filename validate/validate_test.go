@@ -0,0 +1,53 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   func(string) error
+		valid   []string
+		invalid []string
+	}{
+		{"ActionID", ActionID, []string{"fetch", "fetch_data", "@agent/action", "@agent/my-action:1.0.0"}, []string{"", "fetch-data", "@agent", "@agent/action:", "has space"}},
+		{"Dependency", Dependency, []string{"fetch", "@agent/action:1.2"}, []string{"@agent/action/extra", "a.b"}},
+		{"AgentID", AgentID, []string{"myAgent", "agent_1"}, []string{"", "my-agent", "my agent"}},
+		{"TargetActionID", TargetActionID, []string{"respond", "@other/respond:2.0.0"}, []string{"@other"}},
+		{"Workflow", Workflow, []string{"@example", "@my-example/action", "@example/action:1.0.0"}, []string{"example", "@", "@example/action:"}},
+		{"Version", Version, []string{"1", "1.0", "1.0.0", "1.0.*", "*"}, []string{"", "1.0.0.0", "v1.0.0", "1.0.0-beta", "1..0"}},
+		{"ExecEnv", ExecEnv, []string{"PATH", "_private", "my_var2"}, []string{"", "2FAST", "MY-VAR"}},
+		{"PythonEnv", PythonEnv, []string{"PYTHONPATH"}, []string{"1X"}},
+		{"DockerParam", DockerParam, []string{"BUILD_ARG"}, []string{"BUILD-ARG"}},
+		{"RouteMethod", RouteMethod, []string{"GET", "post", "Options"}, []string{"", "CONNECT", "GETX", "GET POST"}},
+		{"CORSMethod", CORSMethod, []string{"DELETE", "head"}, []string{"TRACE"}},
+		{"RequestMethod", RequestMethod, []string{"PATCH"}, []string{"FETCH"}},
+		{"HTTPClientMethod", HTTPClientMethod, []string{"GET", "put"}, []string{"OPTIONS"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, s := range tt.valid {
+				if err := tt.check(s); err != nil {
+					t.Errorf("%q: unexpected error %v", s, err)
+				}
+			}
+			for _, s := range tt.invalid {
+				err := tt.check(s)
+				var validationErr *Error
+				if !errors.As(err, &validationErr) {
+					t.Errorf("%q: expected *Error, got %v", s, err)
+					continue
+				}
+				if validationErr.Value != s || validationErr.Rule.Name != tt.name {
+					t.Errorf("%q: unexpected error %+v", s, validationErr)
+				}
+				if err.Error() != validationErr.Rule.Message {
+					t.Errorf("%q: message %q differs from the Pkl message", s, err.Error())
+				}
+			}
+		})
+	}
+}